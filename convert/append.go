@@ -0,0 +1,130 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// appendFile calls `write` to append the output to `outputFile`, as
+// WriteOutputFile does when Options.Append is set.
+func appendFile(
+	outputFile string,
+	opts Options,
+	write func(io.Writer) (Stats, error),
+) (Stats, error) {
+	if opts.NoClobber {
+		return Stats{}, errors.New("Append may not be used with NoClobber")
+	}
+	if opts.NoTrailingNewline {
+		return Stats{}, errors.New("Append may not be used with NoTrailingNewline")
+	}
+	if _, ok := compressionFor(outputFile); ok {
+		return Stats{}, fmt.Errorf(
+			"appending to output file (%s): compressed outputs may not be appended to",
+			outputFile,
+		)
+	}
+	if _, ok := storageFor(outputFile); ok {
+		return Stats{}, fmt.Errorf("appending to output file (%s): Append is not supported by its storage", outputFile)
+	}
+
+	//nolint:gosec // These are the same permissions os.Create uses.
+	outFile, err := os.OpenFile(filepath.Clean(outputFile), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o666)
+	if err != nil {
+		return Stats{}, fmt.Errorf("opening output file (%s): %w", outputFile, err)
+	}
+
+	header, err := existingHeader(outFile, opts)
+	if err != nil {
+		outFile.Close()
+		return Stats{}, fmt.Errorf("appending to output file (%s): %w", outputFile, err)
+	}
+	if header == "" {
+		return writeAndClose(outFile, outputFile, opts.NoSync, write)
+	}
+
+	return writeAndClose(outFile, outputFile, opts.NoSync, func(output io.Writer) (Stats, error) {
+		return write(&appendWriter{writer: output, outputFile: outputFile, header: header})
+	})
+}
+
+// existingHeader returns the first line of `f`, including its line ending,
+// if the output has a header and `f` is not empty. Otherwise, it returns
+// "" and the header is written as usual. It is an error for a non-empty
+// `f` not to end with a newline as the first appended record would
+// otherwise be joined to its last line.
+func existingHeader(f *os.File, opts Options) (string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() || info.Size() == 0 {
+		return "", nil
+	}
+
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return "", err
+	}
+	if last[0] != '\n' {
+		return "", errors.New("the file does not end with a newline")
+	}
+
+	if !opts.Format.HasColumns() || opts.NoHeader {
+		return "", nil
+	}
+
+	// Reads start at the beginning of the file even though writes are
+	// appended.
+	header, err := bufio.NewReader(io.NewSectionReader(f, 0, info.Size())).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return header, nil
+}
+
+// appendWriter writes all but the header, the first line, to `writer`. It
+// is an error for the header not to be `header`, the existing header of the
+// output being appended to.
+type appendWriter struct {
+	writer     io.Writer
+	outputFile string
+	header     string
+	line       []byte
+	checked    bool
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	if w.checked {
+		return w.writer.Write(p)
+	}
+
+	i := bytes.IndexByte(p, '\n')
+	if i < 0 {
+		w.line = append(w.line, p...)
+		return len(p), nil
+	}
+
+	w.line = append(w.line, p[:i+1]...)
+	if string(w.line) != w.header {
+		return 0, fmt.Errorf(
+			"appending to output file (%s): its header is %q rather than %q",
+			w.outputFile,
+			strings.TrimRight(w.header, "\r\n"),
+			strings.TrimRight(string(w.line), "\r\n"),
+		)
+	}
+	w.checked = true
+	w.line = nil
+
+	if _, err := w.writer.Write(p[i+1:]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,86 @@
+package convert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppend(t *testing.T) {
+	dir := t.TempDir()
+	outputFile := filepath.Join(dir, "output.csv")
+
+	for i, input := range []string{
+		"network,geoname_id\n1.0.0.0/24,1\n",
+		"network,geoname_id\n",
+		"network,geoname_id\n2.0.0.0/24,2\n3.0.0.0/24,3\n",
+	} {
+		inputFile := filepath.Join(dir, "input.csv")
+		require.NoError(t, os.WriteFile(inputFile, []byte(input), 0o600))
+
+		_, err := ConvertFileWithOptions(inputFile, outputFile, Options{CIDR: true, Append: true, NoSync: true})
+		require.NoError(t, err, "input %d", i)
+	}
+
+	b, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "network,geoname_id\n1.0.0.0/24,1\n2.0.0.0/24,2\n3.0.0.0/24,3\n", string(b))
+}
+
+func TestAppendEmptyFile(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "input.csv")
+	outputFile := filepath.Join(dir, "output.csv")
+	require.NoError(t, os.WriteFile(inputFile, []byte("network,geoname_id\n1.0.0.0/24,1\n"), 0o600))
+	require.NoError(t, os.WriteFile(outputFile, nil, 0o600))
+
+	_, err := ConvertFileWithOptions(inputFile, outputFile, Options{IPRange: true, Append: true})
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "network_start_ip,network_last_ip,geoname_id\n1.0.0.0,1.0.0.255,1\n", string(b))
+}
+
+func TestAppendErrors(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "input.csv")
+	outputFile := filepath.Join(dir, "output.csv")
+	require.NoError(t, os.WriteFile(inputFile, []byte("network,geoname_id\n1.0.0.0/24,1\n"), 0o600))
+
+	existing := "network,geoname_id\n1.0.0.0/24,1\n"
+	require.NoError(t, os.WriteFile(outputFile, []byte(existing), 0o600))
+
+	_, err := ConvertFileWithOptions(inputFile, outputFile, Options{IntRange: true, Append: true})
+	assert.EqualError(
+		t,
+		err,
+		"flushing CSV: appending to output file ("+outputFile+`): its header is "network,geoname_id"`+
+			` rather than "network_start_integer,network_last_integer,geoname_id"`,
+	)
+
+	b, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, existing, string(b), "nothing is appended with a different header")
+
+	require.NoError(t, os.WriteFile(outputFile, []byte("network,geoname_id\n1.0.0.0/24,1"), 0o600))
+	_, err = ConvertFileWithOptions(inputFile, outputFile, Options{CIDR: true, Append: true})
+	assert.EqualError(
+		t,
+		err,
+		"appending to output file ("+outputFile+"): the file does not end with a newline",
+	)
+
+	_, err = ConvertFileWithOptions(inputFile, outputFile, Options{CIDR: true, Append: true, NoClobber: true})
+	assert.EqualError(t, err, "Append may not be used with NoClobber")
+
+	_, err = ConvertFileWithOptions(
+		inputFile,
+		outputFile,
+		Options{CIDR: true, Append: true, NoTrailingNewline: true},
+	)
+	assert.EqualError(t, err, "Append may not be used with NoTrailingNewline")
+}
@@ -0,0 +1,84 @@
+package convert
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+)
+
+const asnColumnName = "autonomous_system_number"
+
+// ASNFormat specifies how the autonomous system number is written in ASN
+// mode.
+type ASNFormat int
+
+const (
+	// ASNFormatPlain writes the number as it appears in the input, e.g.,
+	// "13335".
+	ASNFormatPlain ASNFormat = iota
+	// ASNFormatPadded zero pads the number to 10 digits, the length of the
+	// largest 32-bit number, e.g., "0000013335".
+	ASNFormatPadded
+	// ASNFormatPrefixed prefixes the number with "AS", e.g., "AS13335".
+	ASNFormatPrefixed
+)
+
+// ParseASNFormat parses the name of an ASNFormat: "plain", "padded", or
+// "prefixed".
+func ParseASNFormat(name string) (ASNFormat, error) {
+	switch name {
+	case "plain":
+		return ASNFormatPlain, nil
+	case "padded":
+		return ASNFormatPadded, nil
+	case "prefixed":
+		return ASNFormatPrefixed, nil
+	default:
+		return 0, fmt.Errorf("unknown ASN format %q", name)
+	}
+}
+
+func (f ASNFormat) format(asn uint32) string {
+	switch f {
+	case ASNFormatPadded:
+		return fmt.Sprintf("%010d", asn)
+	case ASNFormatPrefixed:
+		return "AS" + strconv.FormatUint(uint64(asn), 10)
+	default:
+		return strconv.FormatUint(uint64(asn), 10)
+	}
+}
+
+func (o Options) asnMode() bool {
+	return o.ASN || o.ASNFormat != ASNFormatPlain || len(o.ASNFilter) > 0
+}
+
+// asnFilter returns a rowFilter that validates and formats the autonomous
+// system number in the column at index `column` and excludes records not
+// matching opts.ASNFilter.
+func asnFilter(opts Options, column int) rowFilter {
+	var allowed map[uint32]struct{}
+	if len(opts.ASNFilter) > 0 {
+		allowed = make(map[uint32]struct{}, len(opts.ASNFilter))
+		for _, asn := range opts.ASNFilter {
+			allowed[asn] = struct{}{}
+		}
+	}
+
+	return func(_ netip.Prefix, rest []string) (bool, error) {
+		value := field(rest, column)
+		asn, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return false, fmt.Errorf("parsing %s (%s): %w", asnColumnName, value, err)
+		}
+
+		if allowed != nil {
+			if _, ok := allowed[uint32(asn)]; !ok {
+				return false, nil
+			}
+		}
+
+		rest[column] = opts.ASNFormat.format(uint32(asn))
+		return true, nil
+	}
+}
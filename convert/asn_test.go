@@ -0,0 +1,95 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const asnInput = `network,autonomous_system_number,autonomous_system_organization
+1.0.0.0/24,13335,CLOUDFLARENET
+1.0.4.0/22,38803,"Wirefreebroadband Pty Ltd"
+8.8.8.0/24,15169,GOOGLE
+`
+
+func TestASNFormat(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{"plain", "network,autonomous_system_number,autonomous_system_organization\n" +
+			"1.0.0.0/24,13335,CLOUDFLARENET\n" +
+			"1.0.4.0/22,38803,Wirefreebroadband Pty Ltd\n" +
+			"8.8.8.0/24,15169,GOOGLE\n"},
+		{"padded", "network,autonomous_system_number,autonomous_system_organization\n" +
+			"1.0.0.0/24,0000013335,CLOUDFLARENET\n" +
+			"1.0.4.0/22,0000038803,Wirefreebroadband Pty Ltd\n" +
+			"8.8.8.0/24,0000015169,GOOGLE\n"},
+		{"prefixed", "network,autonomous_system_number,autonomous_system_organization\n" +
+			"1.0.0.0/24,AS13335,CLOUDFLARENET\n" +
+			"1.0.4.0/22,AS38803,Wirefreebroadband Pty Ltd\n" +
+			"8.8.8.0/24,AS15169,GOOGLE\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			format, err := ParseASNFormat(test.format)
+			require.NoError(t, err)
+
+			var outbuf bytes.Buffer
+			_, err = ConvertWithOptions(
+				strings.NewReader(asnInput),
+				&outbuf,
+				Options{CIDR: true, ASN: true, ASNFormat: format},
+			)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, outbuf.String())
+		})
+	}
+
+	_, err := ParseASNFormat("hex")
+	require.EqualError(t, err, `unknown ASN format "hex"`)
+}
+
+func TestASNFilter(t *testing.T) {
+	var outbuf bytes.Buffer
+	stats, err := ConvertWithOptions(
+		strings.NewReader(asnInput),
+		&outbuf,
+		Options{IPRange: true, ASNFilter: []uint32{13335, 15169}},
+	)
+	require.NoError(t, err)
+
+	expected := `network_start_ip,network_last_ip,autonomous_system_number,autonomous_system_organization
+1.0.0.0,1.0.0.255,13335,CLOUDFLARENET
+8.8.8.0,8.8.8.255,15169,GOOGLE
+`
+	assert.Equal(t, expected, outbuf.String())
+	assert.Equal(t, 2, stats.RecordsProcessed)
+	assert.Equal(t, 1, stats.FilteredRecords)
+}
+
+func TestASNValidation(t *testing.T) {
+	input := `network,autonomous_system_number
+1.0.0.0/24,13335
+1.0.4.0/22,4294967296
+`
+
+	_, err := ConvertWithOptions(strings.NewReader(input), &bytes.Buffer{}, Options{CIDR: true, ASN: true})
+	require.EqualError(
+		t,
+		err,
+		`filtering record on line 3: parsing autonomous_system_number (4294967296): `+
+			`strconv.ParseUint: parsing "4294967296": value out of range`,
+	)
+
+	_, err = ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n"),
+		&bytes.Buffer{},
+		Options{CIDR: true, ASN: true},
+	)
+	require.EqualError(t, err, `column "autonomous_system_number" not found in header`)
+}
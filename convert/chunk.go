@@ -0,0 +1,107 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// writeChunks writes the records converted by `rows` to the chunks returned
+// by Options.NewChunkWriter. A chunk is only started once it has a record,
+// except for the first one, which is started even if there are none so that
+// the header is written as it is without chunking.
+func writeChunks(rows *RowConverter, opts Options) (Stats, error) {
+	if opts.ChunkRows < 0 {
+		return rows.stats, errors.New("ChunkRows must not be negative")
+	}
+	if rows.empty {
+		return rows.stats, nil
+	}
+
+	c := &chunks{opts: opts, header: rows.header}
+	if err := c.start(); err != nil {
+		return rows.stats, err
+	}
+
+	for {
+		row, err := rows.nextRow()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			c.abort()
+			return rows.stats, err
+		}
+
+		if opts.ChunkRows > 0 && c.rows == opts.ChunkRows {
+			if err := c.close(); err != nil {
+				return rows.stats, err
+			}
+			if err := c.start(); err != nil {
+				return rows.stats, err
+			}
+		}
+
+		err = c.writer.write(row)
+		if err != nil {
+			c.abort()
+			return rows.stats, fmt.Errorf("writing %s to chunk %d on line %d: %w", opts.Format, c.seq, rows.line, err)
+		}
+		c.rows++
+	}
+
+	if err := c.close(); err != nil {
+		return rows.stats, err
+	}
+	rows.logDone(strconv.Itoa(c.seq+1) + " chunk(s)")
+	return rows.stats, nil
+}
+
+// chunks are the chunks of a chunked output. `seq` is the number of the
+// current chunk, which has `rows` records.
+type chunks struct {
+	opts   Options
+	header []string
+	seq    int
+	rows   int
+	output io.WriteCloser
+	writer *outputWriter
+}
+
+// start starts the next chunk and writes the header to it.
+func (c *chunks) start() error {
+	if c.output != nil {
+		c.seq++
+	}
+
+	output, err := c.opts.NewChunkWriter(c.seq)
+	if err != nil {
+		return fmt.Errorf("starting chunk %d: %w", c.seq, err)
+	}
+	c.output = output
+	c.writer = newOutputWriter(output, c.opts)
+	c.rows = 0
+
+	if err := c.writer.writeHeader(c.header); err != nil {
+		c.abort()
+		return fmt.Errorf("writing %s header to chunk %d: %w", c.opts.Format, c.seq, err)
+	}
+	return nil
+}
+
+// close flushes and closes the current chunk.
+func (c *chunks) close() error {
+	if err := c.writer.finish(); err != nil {
+		c.abort()
+		return fmt.Errorf("chunk %d: %w", c.seq, err)
+	}
+	if err := c.output.Close(); err != nil {
+		return fmt.Errorf("closing chunk %d: %w", c.seq, err)
+	}
+	return nil
+}
+
+// abort closes the current chunk after an error.
+func (c *chunks) abort() {
+	c.output.Close()
+}
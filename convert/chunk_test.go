@@ -0,0 +1,166 @@
+package convert
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkBuffer is a chunk written to memory.
+type chunkBuffer struct {
+	strings.Builder
+	closed bool
+}
+
+func (c *chunkBuffer) Close() error {
+	c.closed = true
+	return nil
+}
+
+// newChunkBuffers returns an Options.NewChunkWriter writing to the returned
+// slice of chunks.
+func newChunkBuffers() (*[]*chunkBuffer, func(int) (io.WriteCloser, error)) {
+	var chunks []*chunkBuffer
+	return &chunks, func(seq int) (io.WriteCloser, error) {
+		if seq != len(chunks) {
+			return nil, errors.New("unexpected chunk number")
+		}
+		c := &chunkBuffer{}
+		chunks = append(chunks, c)
+		return c, nil
+	}
+}
+
+func chunkContents(chunks []*chunkBuffer) []string {
+	var contents []string
+	for _, c := range chunks {
+		contents = append(contents, c.String())
+	}
+	return contents
+}
+
+func TestChunks(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,1
+1.0.1.0/24,2
+1.0.2.0/24,3
+1.0.3.0/24,4
+1.0.4.0/24,5
+`
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected []string
+	}{
+		{
+			name: "partial last chunk",
+			opts: Options{ChunkRows: 2},
+			expected: []string{
+				"network,geoname_id\n1.0.0.0/24,1\n1.0.1.0/24,2\n",
+				"network,geoname_id\n1.0.2.0/24,3\n1.0.3.0/24,4\n",
+				"network,geoname_id\n1.0.4.0/24,5\n",
+			},
+		},
+		{
+			name: "full last chunk",
+			opts: Options{ChunkRows: 5},
+			expected: []string{
+				"network,geoname_id\n1.0.0.0/24,1\n1.0.1.0/24,2\n1.0.2.0/24,3\n1.0.3.0/24,4\n1.0.4.0/24,5\n",
+			},
+		},
+		{
+			name: "single chunk",
+			opts: Options{},
+			expected: []string{
+				"network,geoname_id\n1.0.0.0/24,1\n1.0.1.0/24,2\n1.0.2.0/24,3\n1.0.3.0/24,4\n1.0.4.0/24,5\n",
+			},
+		},
+		{
+			name: "no header or trailing newline",
+			opts: Options{ChunkRows: 3, NoHeader: true, NoTrailingNewline: true},
+			expected: []string{
+				"1.0.0.0/24,1\n1.0.1.0/24,2\n1.0.2.0/24,3",
+				"1.0.3.0/24,4\n1.0.4.0/24,5",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chunks, newChunkWriter := newChunkBuffers()
+			opts := test.opts
+			opts.CIDR = true
+			opts.NewChunkWriter = newChunkWriter
+
+			stats, err := ConvertWithOptions(strings.NewReader(input), nil, opts)
+			require.NoError(t, err)
+			assert.Equal(t, 5, stats.RecordsProcessed)
+
+			assert.Equal(t, test.expected, chunkContents(*chunks))
+			for _, c := range *chunks {
+				assert.True(t, c.closed)
+			}
+		})
+	}
+}
+
+func TestChunksHeaderOnly(t *testing.T) {
+	chunks, newChunkWriter := newChunkBuffers()
+	_, err := ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n"),
+		nil,
+		Options{CIDR: true, ChunkRows: 2, NewChunkWriter: newChunkWriter},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"network,geoname_id\n"}, chunkContents(*chunks))
+
+	chunks, newChunkWriter = newChunkBuffers()
+	_, err = ConvertWithOptions(
+		strings.NewReader(""),
+		nil,
+		Options{CIDR: true, ChunkRows: 2, NewChunkWriter: newChunkWriter},
+	)
+	require.NoError(t, err)
+	assert.Empty(t, *chunks)
+}
+
+func TestChunksErrors(t *testing.T) {
+	chunks, newChunkWriter := newChunkBuffers()
+	_, err := ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n1.0.0.0/24,1\nnot-a-network,2\n"),
+		nil,
+		Options{CIDR: true, ChunkRows: 2, NewChunkWriter: newChunkWriter},
+	)
+	require.Error(t, err)
+	// The chunk being written when the error occurred is closed too.
+	require.Len(t, *chunks, 1)
+	assert.True(t, (*chunks)[0].closed)
+
+	_, err = ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n1.0.0.0/24,1\n1.0.1.0/24,2\n"),
+		nil,
+		Options{
+			CIDR:      true,
+			ChunkRows: 1,
+			NewChunkWriter: func(seq int) (io.WriteCloser, error) {
+				if seq > 0 {
+					return nil, errors.New("no more chunks")
+				}
+				return &chunkBuffer{}, nil
+			},
+		},
+	)
+	assert.EqualError(t, err, "starting chunk 1: no more chunks")
+
+	_, err = ConvertFileWithOptions(
+		"input.csv",
+		"output.csv",
+		Options{CIDR: true, NewChunkWriter: newChunkWriter},
+	)
+	assert.EqualError(t, err, "NewChunkWriter may not be used with an output file")
+}
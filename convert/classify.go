@@ -0,0 +1,43 @@
+package convert
+
+import "net/netip"
+
+// The values of the network_class column added by Options.Classification.
+const (
+	classGlobal        = "global"
+	classMixed         = "mixed"
+	classUnspecified   = "unspecified"
+	classPrivate       = "private"
+	classShared        = "shared"
+	classLoopback      = "loopback"
+	classLinkLocal     = "link-local"
+	classMulticast     = "multicast"
+	classDocumentation = "documentation"
+	classIPv4Mapped    = "ipv4-mapped"
+	classReserved      = "reserved"
+)
+
+func classificationHeader(orig []string) []string {
+	return append([]string{"network_class"}, orig...)
+}
+
+func classificationLine(network netip.Prefix, columns []string) {
+	columns[0] = classify(network)
+}
+
+// classify returns the class of the reserved network containing `network`,
+// classMixed if `network` only partially overlaps reserved networks, or
+// classGlobal if it does not overlap any. The reserved networks and their
+// classes are the reservedNetworks.
+func classify(network netip.Prefix) string {
+	class := classGlobal
+	for _, c := range reservedNetworks {
+		if c.network.Bits() <= network.Bits() && c.network.Contains(network.Addr()) {
+			return c.class
+		}
+		if c.network.Overlaps(network) {
+			class = classMixed
+		}
+	}
+	return class
+}
@@ -0,0 +1,56 @@
+package convert
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	for network, expected := range map[string]string{
+		"1.0.0.0/24":             classGlobal,
+		"2001:4220::/32":         classGlobal,
+		"0.0.0.0/0":              classMixed,
+		"192.0.0.0/8":            classMixed,
+		"::/0":                   classMixed,
+		"0.1.2.0/24":             classUnspecified,
+		"::/128":                 classUnspecified,
+		"10.1.0.0/16":            classPrivate,
+		"172.16.0.0/12":          classPrivate,
+		"192.168.1.0/24":         classPrivate,
+		"fd00::/8":               classPrivate,
+		"100.64.0.0/10":          classShared,
+		"127.0.0.1/32":           classLoopback,
+		"::1/128":                classLoopback,
+		"169.254.1.0/24":         classLinkLocal,
+		"fe80::/64":              classLinkLocal,
+		"224.0.0.0/24":           classMulticast,
+		"ff02::/16":              classMulticast,
+		"192.0.2.0/24":           classDocumentation,
+		"198.51.100.0/25":        classDocumentation,
+		"203.0.113.0/24":         classDocumentation,
+		"2001:db8:1::/48":        classDocumentation,
+		"::ffff:203.0.113.0/120": classIPv4Mapped,
+		"192.0.0.0/24":           classReserved,
+		"192.88.99.0/24":         classReserved,
+		"198.18.0.0/15":          classReserved,
+		"240.0.0.0/4":            classReserved,
+		"255.255.255.255/32":     classReserved,
+		"64:ff9b:1::/48":         classReserved,
+		"100::/64":               classReserved,
+		"2001::/32":              classReserved,
+	} {
+		assert.Equal(t, expected, classify(netip.MustParsePrefix(network)), network)
+	}
+}
+
+func TestClassificationLine(t *testing.T) {
+	checkHeader(
+		t,
+		classificationHeader,
+		[]string{"network_class"},
+	)
+
+	checkLine(t, classificationLine, "10.0.0.0/8", []string{classPrivate})
+}
@@ -0,0 +1,109 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Compression is a compression format that inputs are decompressed from and
+// output files are compressed with, e.g., zstd. It is registered for the
+// file name extension of the format with RegisterCompression.
+type Compression interface {
+	// Magic returns the bytes that compressed data starts with. Inputs
+	// starting with them are decompressed if Options.AutoDecompress is set.
+	Magic() []byte
+	// NewReader returns a reader decompressing `r`. The reader should
+	// release any resources it holds once it returns an error, including
+	// io.EOF, as it is not closed.
+	NewReader(r io.Reader) (io.Reader, error)
+	// NewWriter returns a writer compressing to `w`. The compressed data is
+	// only complete once Close returns without an error. Close must not
+	// close `w`.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	compressionsMu sync.RWMutex
+	compressions   = map[string]Compression{}
+)
+
+// RegisterCompression makes `compression` handle the output files whose
+// names end with `extension`, e.g., ".zst", and the inputs starting with its
+// magic bytes. It is typically called from the init function of the package
+// implementing the Compression. It panics if a Compression is already
+// registered for `extension`.
+func RegisterCompression(extension string, compression Compression) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+
+	if _, ok := compressions[extension]; ok {
+		panic("convert: RegisterCompression called twice for extension " + extension)
+	}
+	compressions[extension] = compression
+}
+
+// compressionFor returns the Compression registered for the extension of
+// the output file `name`, if any.
+func compressionFor(name string) (Compression, bool) {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+
+	for extension, compression := range compressions {
+		if strings.HasSuffix(name, extension) {
+			return compression, true
+		}
+	}
+	return nil, false
+}
+
+// compressionWithMagic returns the registered Compression whose magic bytes
+// `start`, the start of an input, begins with, if any.
+func compressionWithMagic(start []byte) (Compression, bool) {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+
+	for _, compression := range compressions {
+		if magic := compression.Magic(); len(magic) > 0 && bytes.HasPrefix(start, magic) {
+			return compression, true
+		}
+	}
+	return nil, false
+}
+
+// maxMagicLen returns the length of the longest registered magic bytes.
+func maxMagicLen() int {
+	compressionsMu.RLock()
+	defer compressionsMu.RUnlock()
+
+	n := 0
+	for _, compression := range compressions {
+		if len(compression.Magic()) > n {
+			n = len(compression.Magic())
+		}
+	}
+	return n
+}
+
+// compressedWrite returns a function calling `write` with a writer
+// compressing to the output with `compression`.
+func compressedWrite(compression Compression, write func(io.Writer) (Stats, error)) func(io.Writer) (Stats, error) {
+	return func(output io.Writer) (Stats, error) {
+		compressed, err := compression.NewWriter(output)
+		if err != nil {
+			return Stats{}, fmt.Errorf("compressing output: %w", err)
+		}
+
+		stats, err := write(compressed)
+		if err != nil {
+			compressed.Close()
+			return stats, err
+		}
+		if err := compressed.Close(); err != nil {
+			return stats, fmt.Errorf("compressing output: %w", err)
+		}
+		return stats, nil
+	}
+}
@@ -0,0 +1,76 @@
+package convert
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// upperCompression "compresses" by uppercasing after a magic prefix.
+type upperCompression struct{}
+
+var upperMagic = []byte("UPPER:")
+
+func (upperCompression) Magic() []byte {
+	return upperMagic
+}
+
+func (upperCompression) NewReader(r io.Reader) (io.Reader, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(strings.ToLower(string(bytes.TrimPrefix(b, upperMagic)))), nil
+}
+
+func (upperCompression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return &upperWriter{w: w}, nil
+}
+
+type upperWriter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (u *upperWriter) Write(p []byte) (int, error) {
+	return u.buf.Write(p)
+}
+
+func (u *upperWriter) Close() error {
+	_, err := u.w.Write(append(upperMagic, bytes.ToUpper(u.buf.Bytes())...))
+	return err
+}
+
+func init() {
+	RegisterCompression(".upper", upperCompression{})
+}
+
+func TestCompression(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "blocks.csv")
+	require.NoError(t, os.WriteFile(inputFile, []byte("UPPER:NETWORK,GEONAME_ID\n1.0.0.0/24,2077456\n"), 0o600))
+
+	outputFile := filepath.Join(dir, "output.csv.upper")
+	_, err := ConvertFileWithOptions(
+		inputFile,
+		outputFile,
+		Options{IPRange: true, AutoDecompress: true, NoSync: true},
+	)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "UPPER:NETWORK_START_IP,NETWORK_LAST_IP,GEONAME_ID\n1.0.0.0,1.0.0.255,2077456\n", string(b))
+
+	assert.PanicsWithValue(
+		t,
+		"convert: RegisterCompression called twice for extension .upper",
+		func() { RegisterCompression(".upper", upperCompression{}) },
+	)
+}
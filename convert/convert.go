@@ -2,15 +2,19 @@
 package convert
 
 import (
-	"encoding/csv"
+	"bufio"
+	"encoding/base64"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"math/big"
+	"math/rand"
 	"net/netip"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"go4.org/netipx"
@@ -19,8 +23,513 @@ import (
 type (
 	headerFunc func([]string) []string
 	lineFunc   func(netip.Prefix, []string) []string
+
+	// columnsFunc sets the columns of a network representation. `columns`
+	// has one element per column of the representation.
+	columnsFunc func(network netip.Prefix, columns []string)
+
+	// rowFilter returns whether a record with the network and remaining
+	// columns should be kept. It may modify the remaining columns.
+	rowFilter func(netip.Prefix, []string) (bool, error)
 )
 
+// maxSkippedLines is the maximum number of line numbers recorded in
+// Stats.SkippedLines.
+const maxSkippedLines = 100
+
+// Options specifies how a conversion is performed.
+type Options struct {
+	// CIDR includes the network in CIDR format.
+	CIDR bool
+	// IPRange includes the IP range of the network in string format.
+	IPRange bool
+	// IntRange includes the IP range of the network in integer format.
+	IntRange bool
+	// IntRangeByFamily causes the integer range to be written to separate
+	// network_start_integer_v4 and network_last_integer_v4 columns for IPv4
+	// networks and network_start_integer_v6 and network_last_integer_v6
+	// columns for IPv6 networks, rather than to network_start_integer and
+	// network_last_integer. Only the columns of the family of the network
+	// are set. The others are empty. This lets a typed schema store the IPv4
+	// values as 64-bit integers and the IPv6 values as text or decimals. It
+	// has no effect unless IntRange is set.
+	IntRangeByFamily bool
+	// IntegerCIDR includes the network as the integer of its first address
+	// and its prefix length, e.g., "16777216" and "24" for "1.0.0.0/24".
+	// It may not be used with IntRange, as both have a
+	// network_start_integer column, unless IntRangeByFamily is set.
+	IntegerCIDR bool
+	// HexRange includes the IP range of the network in hexadecimal format.
+	HexRange bool
+	// HostCIDRRange includes the IP range of the network as host networks,
+	// e.g., "1.0.0.0/32" and "1.0.0.255/32", for systems that only store
+	// networks. IPv6 addresses have a "/128" suffix. IPv6Uppercase applies
+	// to these columns.
+	HostCIDRRange bool
+	// ExclusiveEndRange includes the IP range of the network as a half-open
+	// interval, with the first address after the network rather than the
+	// last address in the network, e.g., "1.0.0.0" and "1.0.1.0" for
+	// "1.0.0.0/24". The end is empty for networks at the top of the address
+	// space, e.g., "255.255.255.0/24". It may not be used with IPRange, as
+	// both have a network_start_ip column. IPv6Expanded and IPv6Uppercase
+	// apply to these columns.
+	ExclusiveEndRange bool
+	// StartCountRange includes the IP range of the network as its first
+	// address and the number of addresses in it, e.g., "1.0.0.0" and "256"
+	// for "1.0.0.0/24", which is more compact than two addresses. The count
+	// is in base 10 and may exceed 64 bits for IPv6 networks. It may not be
+	// used with IPRange or ExclusiveEndRange, as they also have a
+	// network_start_ip column. IPv6Expanded and IPv6Uppercase apply to the
+	// start.
+	StartCountRange bool
+	// IPv6Expanded causes the IP range to use the fully expanded IPv6 form,
+	// e.g., "2001:0db8:0000:0000:0000:0000:0000:0000". The CIDR
+	// representation is unaffected.
+	IPv6Expanded bool
+	// HexUppercase causes the hexadecimal representation to use uppercase
+	// letters.
+	HexUppercase bool
+	// IPv6Uppercase causes the IP range, host CIDR range, and CIDR
+	// representations of IPv6 networks to use uppercase letters, e.g.,
+	// "2001:DB8::/32", to match tools that expect them. The hexadecimal
+	// representation is unaffected. See HexUppercase.
+	IPv6Uppercase bool
+	// IntRangeCombined includes the IP range of the network in integer
+	// format as a single column, e.g., "16843008-16843263".
+	IntRangeCombined bool
+	// IntegerBase is the base, from 2 to 36, of the IntRange,
+	// IntRangeCombined, and IntegerCIDR integer columns, e.g., 36 for
+	// compact keys. The digits above 9 are lowercase letters. Zero uses base
+	// 10. The prefix length is always in base 10.
+	IntegerBase int
+	// BinaryRange includes the IP range of the network in binary format.
+	BinaryRange bool
+	// Base64Range includes the IP range of the network as the base64
+	// encoding of the address bytes.
+	Base64Range bool
+
+	// NetworkColumn is the zero-based index of the column containing the
+	// network. The remaining columns are passed through in their original
+	// order.
+	NetworkColumn int
+	// NetworkColumnName is the name of the header column containing the
+	// network. If set, it takes precedence over NetworkColumn.
+	NetworkColumnName string
+	// RetainNetworkColumn keeps the network column, as it appears in the
+	// input, in its original position among the passed-through columns. As
+	// this column already contains the network, CIDR may not also be set.
+	RetainNetworkColumn bool
+	// OnlyNetwork discards the columns passed through from the input, such
+	// as geoname_id, so that the output only has the selected network
+	// representations. The discarded columns may still be used by filters
+	// such as ExcludeAnonymousProxy. At least one representation must be
+	// selected, and RetainNetworkColumn and Locations may not be set.
+	OnlyNetwork bool
+	// NullValue, if set, replaces the empty values of the columns passed
+	// through from the input and of the Locations columns, e.g., `\N` so
+	// that PostgreSQL's COPY loads them as NULL rather than as empty
+	// strings. The network representations are unaffected. It only applies
+	// to the formats with columns. See OutputFormat.HasColumns.
+	NullValue string
+
+	// RequireCanonical causes networks with host bits set, e.g.,
+	// "1.2.3.5/24", to be treated as invalid, as with a network that cannot
+	// be parsed. Otherwise, such networks are converted as they are, e.g.,
+	// with a range starting at "1.2.3.5".
+	RequireCanonical bool
+	// Normalize causes networks with host bits set to be converted as the
+	// canonical network, e.g., "1.2.3.0/24" for "1.2.3.5/24". It takes
+	// precedence over RequireCanonical.
+	Normalize bool
+
+	// Unmap causes IPv4-mapped IPv6 networks, e.g., "::ffff:1.2.3.0/120", to
+	// be converted as the equivalent IPv4 network, e.g., "1.2.3.0/24".
+	Unmap bool
+
+	// AllowRaggedRows allows records to have a different number of columns
+	// than the header. Only the network column is required. The output
+	// header is still based on the input header.
+	AllowRaggedRows bool
+
+	// Comment, if not 0, is the character that starts a comment line in the
+	// input. Comment lines are skipped. The character only starts a comment
+	// at the beginning of a line.
+	Comment rune
+
+	// AutoDecompress causes gzip- or bzip2-compressed input to be
+	// decompressed. See DecompressReader.
+	AutoDecompress bool
+
+	// Locations, if set, is used to append the `country_iso_code` and
+	// `country_name` columns for the location with the geoname ID in the
+	// `geoname_id` column of each record. The columns are empty if the
+	// geoname ID is empty or not found.
+	Locations Locations
+
+	// ASN enables ASN mode for GeoLite2 ASN CSVs. The
+	// `autonomous_system_number` column must be present and parse as a
+	// 32-bit unsigned integer. Setting ASNFormat or ASNFilter implies ASN.
+	ASN bool
+	// ASNFormat specifies how the autonomous system number is written.
+	ASNFormat ASNFormat
+	// ASNFilter, if not empty, restricts the output to records with one of
+	// these autonomous system numbers.
+	ASNFilter []uint32
+
+	// Within, if not empty, restricts the output to records whose network
+	// is fully contained by the networks in Within.
+	Within []netip.Prefix
+	// WithinOverlap causes Within to also keep records whose network only
+	// partially overlaps the networks in Within.
+	WithinOverlap bool
+
+	// ExcludeReserved excludes records whose network overlaps a private,
+	// loopback, link-local, multicast, or other special-use network. The
+	// networks are listed in the README.
+	ExcludeReserved bool
+
+	// ExcludeAnonymousProxy excludes records with is_anonymous_proxy set to
+	// 1. It is an error if the input has no is_anonymous_proxy column.
+	ExcludeAnonymousProxy bool
+	// ExcludeSatelliteProvider excludes records with is_satellite_provider
+	// set to 1. It is an error if the input has no is_satellite_provider
+	// column.
+	ExcludeSatelliteProvider bool
+
+	// Dedup excludes records whose network is the same as an earlier
+	// record's, keeping the first occurrence. This requires memory
+	// proportional to the number of unique networks.
+	Dedup bool
+	// DedupAssumeSorted causes Dedup to only compare each network with the
+	// previous record's. This uses constant memory but only removes
+	// duplicates that are adjacent in the input. It implies Dedup.
+	DedupAssumeSorted bool
+
+	// Sort sorts the output by the start address of each network and then
+	// by prefix length, with IPv4 networks before IPv6 networks. All of the
+	// records are held in memory until the input has been read, which may
+	// be significant for large files.
+	Sort bool
+	// SortIPv6First causes Sort to put IPv6 networks before IPv4 networks.
+	SortIPv6First bool
+	// MaxBufferedRows is the maximum number of records held in memory by
+	// Sort, of unique networks held by Dedup, and of networks held by
+	// CheckOverlaps. Exceeding it is an error wrapping ErrBufferLimit, so
+	// that an input too large for the available memory fails predictably
+	// rather than exhausting it. Zero means no limit.
+	MaxBufferedRows int
+
+	// ErrorOnEmpty causes ErrEmptyInput to be returned if the input is
+	// completely empty. Otherwise, empty input produces empty output. Input
+	// containing only a header always produces only the converted header.
+	ErrorOnEmpty bool
+	// ErrorOnEmptyOutput causes ErrEmptyOutput to be returned if no records
+	// would be written to the output, e.g., because every record was
+	// excluded by a misconfigured filter such as Within. Unlike
+	// ErrorOnEmpty, this includes input containing only a header. As the
+	// error is only returned once the input has been read, the file functions
+	// such as ConvertFileWithOptions do not replace the output file, but the
+	// header may already have been written to an io.Writer.
+	ErrorOnEmptyOutput bool
+
+	// ExpectHeader, if not empty, is the exact header the input must have.
+	// Any difference in the names or order of the columns is an error. This
+	// catches changes to the format of the input before they produce
+	// mis-mapped output.
+	ExpectHeader []string
+	// RequireColumns are the names of columns that must be in the input
+	// header, in any order.
+	RequireColumns []string
+	// StrictHeader causes an error unless the network column, the one given
+	// by NetworkColumn, is named "network" in the input header, as it is in
+	// every MaxMind block file. This catches being given another file, such
+	// as a Locations file, by mistake. It has no effect if NetworkColumnName
+	// is set.
+	StrictHeader bool
+	// InputNoHeader causes the first row of the input to be read as a record
+	// rather than as the header, e.g., for plain lists of networks. The
+	// columns are named "network", for the network column, and "col2",
+	// "col3", etc., by their one-based position. It is an error for the
+	// network column of the first row not to be a valid network as that
+	// suggests the input does have a header. NetworkColumnName may not be
+	// used.
+	InputNoHeader bool
+	// NoHeader causes the header row not to be written to the CSV output,
+	// e.g., for loaders that expect the columns to be specified separately.
+	// The input header is still read and used to find columns by name.
+	NoHeader bool
+	// CRLF causes the lines of the CSV output to end with "\r\n" rather
+	// than "\n", e.g., for Windows tools that require it.
+	CRLF bool
+	// NoTrailingNewline causes the line ending after the last line of the
+	// output to be omitted, e.g., for parsers that treat it as an empty
+	// record.
+	NoTrailingNewline bool
+	// RenameColumns maps the names of output columns to the names to write in
+	// the output header instead, e.g., "network_start_ip" to "ip_lo". Both
+	// the network representation columns and the columns passed through from
+	// the input may be renamed. The data is unaffected. It is an error for a
+	// name not to be in the output header. ValueColumn refers to the new
+	// name of a renamed column.
+	RenameColumns map[string]string
+	// ColumnOrder lists output columns to write first, in this order, e.g.,
+	// "geoname_id" before the network representations. The columns not
+	// listed follow in their usual order. The names are those written in the
+	// output header, i.e., after RenameColumns. It is an error for a name
+	// not to be in the output header or to be listed twice.
+	ColumnOrder []string
+
+	// IPv4Octets includes the four octets of the start address of IPv4
+	// networks as separate columns. The columns are empty for IPv6 networks.
+	IPv4Octets bool
+	// IPv4OctetsSkipIPv6 causes records with IPv6 networks to be excluded
+	// when IPv4Octets is set.
+	IPv4OctetsSkipIPv6 bool
+
+	// Netmask includes the dotted-decimal netmask and wildcard mask of IPv4
+	// networks. The columns are empty for IPv6 networks.
+	Netmask bool
+	// Broadcast includes the broadcast address, i.e., the last address, of
+	// IPv4 networks. The column is empty for IPv6 networks unless
+	// BroadcastIPv6 is set.
+	Broadcast bool
+	// BroadcastIPv6 causes the last address of IPv6 networks to be used in
+	// the Broadcast column.
+	BroadcastIPv6 bool
+	// IPv4Integer32 includes the start and last address of IPv4 networks as
+	// 32-bit unsigned integers. The columns are empty for IPv6 networks.
+	IPv4Integer32 bool
+	// IPv4Key includes a single unsigned 64-bit key for IPv4 networks, the
+	// start address as a 32-bit integer shifted left by 32 bits plus the
+	// prefix length, i.e., start<<32 | bits, e.g., "72057594037927960"
+	// for "1.0.0.0/24". The keys sort in the same order as Sort, for loading
+	// into a range tree or B-tree keyed on one value. The column is empty
+	// for IPv6 networks, whose keys would not fit in 64 bits.
+	IPv4Key bool
+	// IPv4Signed causes the IPv4Integer32 columns to use signed 32-bit
+	// integers, reinterpreting the unsigned value as two's complement, e.g.,
+	// -2147483648 for 128.0.0.0. This matches a legacy signed INT column.
+	IPv4Signed bool
+	// Midpoint includes the address halfway between the start and last
+	// address of the network, rounded down, e.g., "1.0.0.127" for
+	// "1.0.0.0/24". IPv6Expanded applies to this column.
+	Midpoint bool
+	// PTR includes the reverse DNS names of the start and last address of
+	// the network, e.g., "0.0.0.1.in-addr.arpa" and "255.0.0.1.in-addr.arpa"
+	// for "1.0.0.0/24". IPv6 addresses use the full nibble form under
+	// "ip6.arpa". The names do not have a trailing dot.
+	PTR bool
+	// IPVersion includes the IP version of the network, "4" or "6".
+	IPVersion bool
+	// Classification includes the class of the special-use network
+	// containing the network, e.g., "private" or "loopback", "mixed" if the
+	// network partially overlaps special-use networks, or "global" if it does
+	// not overlap any. The special-use networks are those excluded by
+	// ExcludeReserved.
+	Classification bool
+	// Hash includes a "network_hash" column with the FNV-1a 32-bit hash of
+	// the network in CIDR notation, with any host bits cleared, as eight
+	// lowercase hexadecimal digits, e.g., for detecting changes between
+	// releases. The hash is stable across versions.
+	Hash bool
+	// RowIndex includes a "row_index" column, before the other columns, with
+	// the position of the record in the input, starting at RowIndexStart.
+	// Every record after the header is counted, including records that are
+	// filtered or skipped, but not blank lines, so the index identifies the
+	// record in the input even when the output is sorted or filtered. The
+	// index continues across multiple inputs.
+	RowIndex bool
+	// RowIndexStart is the index of the first record with RowIndex, e.g., 1.
+	RowIndexStart int
+
+	// Format is the format of the output. The default is CSV.
+	Format OutputFormat
+	// IPSetName is the name of the set used with OutputFormatIPSet.
+	IPSetName string
+	// IPTablesChain is the chain the rules are appended to with
+	// OutputFormatIPTables.
+	IPTablesChain string
+	// IPTablesTarget is the target the rules jump to with
+	// OutputFormatIPTables.
+	IPTablesTarget string
+	// ValueColumn is the name of the column in the converted header that
+	// supplies the value with OutputFormatNginxGeo.
+	ValueColumn string
+	// SkipEmptyValues causes records with an empty value column to be
+	// skipped with OutputFormatNginxGeo.
+	SkipEmptyValues bool
+
+	// SampleEvery causes only every SampleEvery-th record, starting with the
+	// first, to be kept. The records are sampled in input order after the
+	// other filters have been applied. Zero or one keeps every record.
+	SampleEvery int
+	// SampleRate causes each record to be kept with this probability, e.g.,
+	// 0.01 keeps about 1% of the records. Like SampleEvery, it is applied
+	// after the other filters. Zero keeps every record.
+	SampleRate float64
+	// SampleSeed seeds the random number generator used for SampleRate. The
+	// same seed and input always produce the same output.
+	SampleSeed int64
+
+	// Skip is the number of records to discard before returning any. Along
+	// with Limit, this selects the records [Skip, Skip+Limit). The skipped
+	// records are not included in the Stats.
+	Skip int
+	// Limit is the maximum number of records to return. Once it is reached,
+	// the rest of the input is not read. Zero means no limit. With Sort, the
+	// first Limit records of the sorted output are returned. Records discarded
+	// by Skip do not count toward the limit.
+	Limit int
+
+	// Workers is the number of goroutines used to convert the records. The
+	// records are still read and filtered by a single goroutine, and the
+	// output is in the same order as with a single worker. Zero or one
+	// converts the records on the calling goroutine.
+	Workers int
+
+	// PartitionBy is the name of the output column whose values select the
+	// output file of each record with ConvertPartitionedWithOptions and
+	// ConvertFilesPartitionedWithOptions, e.g., "country_iso_code" with
+	// Locations.
+	PartitionBy string
+	// Shards is the number of files the output is striped across with
+	// ConvertShardedWithOptions and ConvertFilesShardedWithOptions.
+	Shards int
+	// MaxOpenFiles is the maximum number of output files kept open at once
+	// when partitioning or sharding the output. Zero uses a default of 64.
+	MaxOpenFiles int
+
+	// NewChunkWriter, if set, is called to start each chunk of the output,
+	// with the chunk number, starting at 0, and the output is written to
+	// the chunks rather than to the `output` of ConvertWithOptions or
+	// ConvertMultipleWithOptions, which may be nil. This allows uploading
+	// large outputs in parts, e.g., to object storage. Each chunk starts
+	// with the header unless NoHeader is set and has at most ChunkRows
+	// records. Each chunk is closed once it is complete, including the last
+	// one. The file functions such as ConvertFileWithOptions may not be used
+	// with it.
+	NewChunkWriter func(seq int) (io.WriteCloser, error)
+	// ChunkRows is the maximum number of records in each chunk with
+	// NewChunkWriter. Zero writes all the records to a single chunk.
+	ChunkRows int
+
+	// Logger, if set, receives progress messages about the conversion, such
+	// as when it starts and ends, the number of records, and the time taken
+	// by each phase. Nothing is logged if it is nil.
+	Logger Logger
+
+	// BufferSize is the size in bytes of the buffers used when reading the
+	// input and writing the output. Zero uses a default of 64 KiB. Larger
+	// buffers reduce the number of system calls, which may help when writing
+	// to a network file system.
+	BufferSize int
+
+	// NoClobber causes the file functions such as ConvertFileWithOptions to
+	// return an error rather than overwrite an existing output file.
+	NoClobber bool
+
+	// Append causes the file functions such as ConvertFileWithOptions to
+	// append the records to an existing output file rather than replace it,
+	// e.g., to accumulate several drops in one file. The file is created if
+	// it does not exist. The header is only written if the file is empty;
+	// otherwise, it is an error for the first line of the file not to be the
+	// header that would have been written. As the file is written in place,
+	// a failed conversion may leave some of its records appended. It may not
+	// be used with NoClobber, NoTrailingNewline, compressed outputs, or
+	// outputs written with a Storage.
+	Append bool
+
+	// NoSync skips syncing output files to disk before they are closed. This
+	// speeds up conversions, particularly on network file systems, when the
+	// output does not need to survive a crash, e.g., in tests.
+	NoSync bool
+
+	// Outputs are the outputs written by ConvertToOutputsWithOptions and
+	// ConvertFilesToOutputsWithOptions, which read the input once and
+	// convert each record for every output, e.g., with different network
+	// representations. It may not be set for the other conversion
+	// functions.
+	Outputs []OutputSpec
+
+	// SkipInvalid causes records with a network that cannot be parsed to be
+	// skipped rather than aborting the conversion. The skipped records are
+	// reported in the returned Stats.
+	SkipInvalid bool
+	// RejectOutput, if set, receives the records skipped due to SkipInvalid
+	// as CSV, preceded by the original header.
+	RejectOutput io.Writer
+}
+
+// defaultBufferSize is the buffer size used when Options.BufferSize is zero.
+const defaultBufferSize = 64 * 1024
+
+func (o Options) maxOpenFiles() int {
+	if o.MaxOpenFiles <= 0 {
+		return defaultMaxOpenFiles
+	}
+	return o.MaxOpenFiles
+}
+
+// integerBase returns the base of the integer columns. Invalid bases are
+// rejected when the conversion starts.
+func (o Options) integerBase() int {
+	if o.IntegerBase < 2 || o.IntegerBase > 36 {
+		return 10
+	}
+	return o.IntegerBase
+}
+
+func (o Options) bufferSize() int {
+	if o.BufferSize > 0 {
+		return o.BufferSize
+	}
+	return defaultBufferSize
+}
+
+// clearRepresentations deselects every network representation.
+func (o *Options) clearRepresentations() {
+	o.CIDR, o.IPRange, o.IntRange, o.HexRange = false, false, false, false
+	o.IntRangeCombined, o.BinaryRange, o.Base64Range, o.IPv4Octets = false, false, false, false
+	o.Netmask, o.Broadcast, o.IPv4Integer32, o.Midpoint = false, false, false, false
+	o.IPVersion, o.Classification, o.Hash, o.HostCIDRRange = false, false, false, false
+	o.ExclusiveEndRange, o.IntegerCIDR, o.IPv4Key, o.StartCountRange = false, false, false, false
+	o.PTR = false
+}
+
+// HasRepresentation returns true if at least one network representation is
+// selected.
+func (o Options) HasRepresentation() bool {
+	return o.CIDR || o.IPRange || o.IntRange || o.HexRange ||
+		o.IntRangeCombined || o.BinaryRange || o.Base64Range || o.IPv4Octets ||
+		o.Netmask || o.Broadcast || o.IPv4Integer32 || o.Midpoint ||
+		o.IPVersion || o.Classification || o.Hash || o.HostCIDRRange ||
+		o.ExclusiveEndRange || o.IntegerCIDR || o.IPv4Key || o.StartCountRange ||
+		o.PTR
+}
+
+// Stats contains information about a conversion.
+type Stats struct {
+	// RecordsProcessed is the number of records that were converted.
+	RecordsProcessed int
+	// IPv4Count is the number of converted records with an IPv4 network.
+	IPv4Count int
+	// IPv6Count is the number of converted records with an IPv6 network.
+	IPv6Count int
+	// TotalAddresses is the sum of the number of addresses in the networks
+	// of the converted records.
+	TotalAddresses *big.Int
+
+	// FilteredRecords is the number of records that were excluded by a
+	// filter such as Options.ASNFilter.
+	FilteredRecords int
+	// SkippedRecords is the number of records that were skipped because
+	// their network could not be parsed.
+	SkippedRecords int
+	// SkippedLines contains the line numbers of the skipped records. At most
+	// 100 line numbers are recorded.
+	SkippedLines []int
+}
+
 // ConvertFile converts the MaxMind GeoIP2 or GeoLite2 CSV file `inputFile` to
 // `outputFile` file using a different representation of the network. The
 // representation can be specified by setting one or more of `cidr`,
@@ -34,38 +543,239 @@ func ConvertFile( //nolint: revive // too late to change name
 	intRange bool,
 	hexRange bool,
 ) error {
-	outFile, err := os.Create(filepath.Clean(outputFile))
+	_, err := ConvertFileWithOptions(
+		inputFile,
+		outputFile,
+		Options{
+			CIDR:     cidr,
+			IPRange:  ipRange,
+			IntRange: intRange,
+			HexRange: hexRange,
+		},
+	)
+	return err
+}
+
+// ConvertFileWithOptions converts the MaxMind GeoIP2 or GeoLite2 CSV file
+// `inputFile` to `outputFile` as specified by `opts`. `inputFile` may be an
+// HTTP(S) URL. See OpenInput.
+func ConvertFileWithOptions(
+	inputFile string,
+	outputFile string,
+	opts Options,
+) (Stats, error) {
+	return convertToFile(
+		func() (io.ReadCloser, error) {
+			return OpenInput(inputFile)
+		},
+		inputFile,
+		outputFile,
+		opts,
+	)
+}
+
+// convertToFile converts the input returned by `openInput` to `outputFile`.
+// `inputName` is used in error messages.
+func convertToFile(
+	openInput func() (io.ReadCloser, error),
+	inputName string,
+	outputFile string,
+	opts Options,
+) (Stats, error) {
+	if err := checkNotInput(outputFile, []string{inputName}); err != nil {
+		return Stats{}, err
+	}
+
+	return WriteOutputFile(outputFile, opts, func(output io.Writer) (Stats, error) {
+		inFile, err := openInput()
+		if err != nil {
+			return Stats{}, err
+		}
+
+		stats, err := ConvertWithOptions(inFile, output, opts)
+		if err != nil {
+			inFile.Close()
+			return stats, err
+		}
+		if err := inFile.Close(); err != nil {
+			return stats, fmt.Errorf("closing file (%s): %w", inputName, err)
+		}
+		return stats, nil
+	})
+}
+
+// WriteOutputFile creates `outputFile` and calls `write` to write the
+// converted output to it, as the file functions such as
+// ConvertFileWithOptions do. If Options.NoClobber is set, it is an error for
+// `outputFile` to already exist. Names with the URL scheme of a registered
+// Storage are written with that Storage. Names ending with the extension of
+// a Compression registered with RegisterCompression, e.g., ".zst", are
+// compressed with it. If Options.Append is set, the output is appended to
+// `outputFile` as described there. It allows other packages to write output
+// files in formats not provided by this package.
+//
+// The output is written to a temporary file in the same directory, which is
+// renamed to `outputFile` once it has been synced and closed, so that a
+// partial output is never seen under that name. The temporary file is
+// removed if writing fails. An existing file keeps its permissions. Outputs
+// that are not regular files, such as symbolic links, /dev/stdout, or named
+// pipes, are written directly so that they are not replaced.
+func WriteOutputFile(
+	outputFile string,
+	opts Options,
+	write func(io.Writer) (Stats, error),
+) (Stats, error) {
+	if opts.NewChunkWriter != nil {
+		return Stats{}, errors.New("NewChunkWriter may not be used with an output file")
+	}
+
+	if opts.Append {
+		return appendFile(outputFile, opts, write)
+	}
+
+	if compression, ok := compressionFor(outputFile); ok {
+		write = compressedWrite(compression, write)
+	}
+
+	if storage, ok := storageFor(outputFile); ok {
+		if opts.NoClobber {
+			return Stats{}, fmt.Errorf(
+				"creating output file (%s): NoClobber is not supported by its storage",
+				outputFile,
+			)
+		}
+		return writeStorage(storage, outputFile, write)
+	}
+
+	info, err := os.Lstat(outputFile)
+	switch {
+	case err == nil && opts.NoClobber:
+		return Stats{}, fmt.Errorf("creating output file (%s): %w", outputFile, os.ErrExist)
+	case err == nil && !info.Mode().IsRegular():
+		return writeInPlace(outputFile, opts.NoSync, write)
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return Stats{}, fmt.Errorf("creating output file (%s): %w", outputFile, err)
+	}
+
+	tempFile, err := createTemp(outputFile)
 	if err != nil {
-		return fmt.Errorf("creating output file (%s): %w", outputFile, err)
+		return Stats{}, fmt.Errorf("creating output file (%s): %w", outputFile, err)
+	}
+	if info != nil {
+		if err := tempFile.Chmod(info.Mode().Perm()); err != nil {
+			tempFile.Close()
+			os.Remove(tempFile.Name())
+			return Stats{}, fmt.Errorf("creating output file (%s): %w", outputFile, err)
+		}
 	}
 
-	inFile, err := os.Open(filepath.Clean(inputFile))
+	stats, err := writeAndClose(tempFile, outputFile, opts.NoSync, write)
 	if err != nil {
-		outFile.Close()
-		return fmt.Errorf("opening input file (%s): %w", inputFile, err)
+		os.Remove(tempFile.Name())
+		return stats, err
+	}
+	if err := os.Rename(tempFile.Name(), outputFile); err != nil {
+		os.Remove(tempFile.Name())
+		return stats, fmt.Errorf("renaming output file (%s): %w", outputFile, err)
 	}
+	return stats, nil
+}
 
-	err = Convert(inFile, outFile, cidr, ipRange, intRange, hexRange)
+// writeInPlace calls `write` with the existing, non-regular `outputFile`.
+func writeInPlace(outputFile string, noSync bool, write func(io.Writer) (Stats, error)) (Stats, error) {
+	outFile, err := os.OpenFile(filepath.Clean(outputFile), os.O_WRONLY|os.O_TRUNC, 0)
 	if err != nil {
-		inFile.Close()
-		outFile.Close()
-		return err
+		return Stats{}, fmt.Errorf("creating output file (%s): %w", outputFile, err)
 	}
-	err = outFile.Sync()
+	return writeAndClose(outFile, outputFile, noSync, write)
+}
+
+// writeAndClose calls `write` with `outFile` and syncs, unless `noSync` is
+// set, and closes it. `outputFile` is used in error messages.
+func writeAndClose(
+	outFile *os.File,
+	outputFile string,
+	noSync bool,
+	write func(io.Writer) (Stats, error),
+) (Stats, error) {
+	stats, err := write(outFile)
 	if err != nil {
-		inFile.Close()
 		outFile.Close()
-		return fmt.Errorf("syncing file (%s): %w", outputFile, err)
+		return stats, err
 	}
-	if err := inFile.Close(); err != nil {
-		return fmt.Errorf("closing file (%s): %w", inputFile, err)
+	if !noSync {
+		if err := outFile.Sync(); err != nil {
+			outFile.Close()
+			return stats, fmt.Errorf("syncing file (%s): %w", outputFile, err)
+		}
 	}
 	if err := outFile.Close(); err != nil {
-		return fmt.Errorf("closing file (%s): %w", outputFile, err)
+		return stats, fmt.Errorf("closing file (%s): %w", outputFile, err)
+	}
+	return stats, nil
+}
+
+// SameFile reports whether the names `name1` and `name2` refer to the same
+// file, e.g., "Blocks.csv" and "/data/blocks.csv" on a case-insensitive file
+// system, or a relative and an absolute path. Names are compared by their
+// absolute paths and, if both are existing regular files, with os.SameFile.
+// URLs and the names of a registered Storage are only compared as strings.
+func SameFile(name1, name2 string) bool {
+	if name1 == name2 {
+		return true
+	}
+	for _, name := range []string{name1, name2} {
+		if _, ok := storageFor(name); ok || isURL(name) {
+			return false
+		}
+	}
+
+	abs1, err1 := filepath.Abs(name1)
+	abs2, err2 := filepath.Abs(name2)
+	if err1 == nil && err2 == nil && abs1 == abs2 {
+		return true
+	}
+
+	// Devices such as /dev/stdin and /dev/stdout may be the same terminal
+	// without one replacing the other, so only regular files are compared.
+	info1, err := os.Stat(name1)
+	if err != nil || !info1.Mode().IsRegular() {
+		return false
+	}
+	info2, err := os.Stat(name2)
+	if err != nil || !info2.Mode().IsRegular() {
+		return false
+	}
+	return os.SameFile(info1, info2)
+}
+
+// checkNotInput returns an error if `outputFile` is the same file as one of
+// `inputFiles`, as writing it would replace that input.
+func checkNotInput(outputFile string, inputFiles []string) error {
+	for _, inputFile := range inputFiles {
+		if SameFile(outputFile, inputFile) {
+			return fmt.Errorf("the output file (%s) is the same file as the input file (%s)", outputFile, inputFile)
+		}
 	}
 	return nil
 }
 
+// createTemp creates a new temporary file in the directory of `outputFile`.
+// Unlike os.CreateTemp, it uses the same permissions as os.Create so that
+// the renamed file has the permissions the output file would have had.
+func createTemp(outputFile string) (*os.File, error) {
+	dir, base := filepath.Split(filepath.Clean(outputFile))
+	for i := 0; ; i++ {
+		name := filepath.Join(dir, "."+base+"."+strconv.FormatUint(uint64(rand.Uint32()), 36)+".tmp")
+		//nolint:gosec // These are the same permissions os.Create uses.
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o666)
+		if errors.Is(err, os.ErrExist) && i < 100 {
+			continue
+		}
+		return f, err
+	}
+}
+
 // Convert writes the MaxMind GeoIP2 or GeoLite2 CSV in the `input` io.Reader
 // to the Writer `output` using the network representation specified by setting
 // `cidr`, ipRange`, or `intRange` to true. If none of these are set to true,
@@ -78,30 +788,264 @@ func Convert(
 	intRange bool,
 	hexRange bool,
 ) error {
+	_, err := ConvertWithOptions(
+		input,
+		output,
+		Options{
+			CIDR:     cidr,
+			IPRange:  ipRange,
+			IntRange: intRange,
+			HexRange: hexRange,
+		},
+	)
+	return err
+}
+
+// ConvertWithOptions writes the MaxMind GeoIP2 or GeoLite2 CSV in the `input`
+// io.Reader to the Writer `output` as specified by `opts`. If no network
+// representation is selected, it will strip off the network information.
+func ConvertWithOptions(
+	input io.Reader,
+	output io.Writer,
+	opts Options,
+) (Stats, error) {
+	makeHeader, makeLine := buildFuncs(opts)
+
+	return convert(input, output, opts, makeHeader, makeLine)
+}
+
+// ValidateFile checks that the MaxMind GeoIP2 or GeoLite2 CSV file
+// `inputFile` can be converted as specified by `opts` without writing any
+// output. See Validate.
+func ValidateFile(inputFile string, opts Options) (int, error) {
+	inFile, err := OpenInput(inputFile)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := Validate(inFile, opts)
+	if err != nil {
+		inFile.Close()
+		return count, err
+	}
+	if err := inFile.Close(); err != nil {
+		return count, fmt.Errorf("closing file (%s): %w", inputFile, err)
+	}
+	return count, nil
+}
+
+// Validate reads the MaxMind GeoIP2 or GeoLite2 CSV in the `input` io.Reader
+// and checks that every network can be parsed, discarding the converted
+// records. It returns the number of valid records and the first error
+// encountered.
+func Validate(input io.Reader, opts Options) (int, error) {
+	rows, err := NewRowConverter(input, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for {
+		_, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		} else if err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+// Representations returns the representations of the network `network`
+// selected by `opts`, keyed by the name of the corresponding output column,
+// e.g., "network_start_ip". The options not related to the network
+// representation are ignored.
+func Representations(network netip.Prefix, opts Options) map[string]string {
+	opts.RowIndex = false
+	makeHeader, makeLine := buildFuncs(opts)
+
+	names := makeHeader(nil)
+	values := makeLine(network, nil)
+
+	reps := make(map[string]string, len(names))
+	for i, name := range names {
+		reps[name] = values[i]
+	}
+	return reps
+}
+
+// ParseNetwork parses `network` in CIDR notation, e.g., "1.0.0.0/24", in the
+// same manner as the conversion functions.
+func ParseNetwork(network string) (netip.Prefix, error) {
+	return netip.ParsePrefix(network)
+}
+
+// unmapPrefix returns the IPv4 network equivalent to the IPv4-mapped IPv6
+// network `network`. Other networks, including IPv4-mapped networks with a
+// prefix length shorter than 96, are returned unchanged.
+func unmapPrefix(network netip.Prefix) netip.Prefix {
+	const mappedBits = 96
+
+	if !network.Addr().Is4In6() || network.Bits() < mappedBits {
+		return network
+	}
+	return netip.PrefixFrom(network.Addr().Unmap(), network.Bits()-mappedBits)
+}
+
+// buildFuncs composes the header and line functions for the network
+// representations selected by `opts`.
+func buildFuncs(opts Options) (headerFunc, lineFunc) {
 	makeHeader := func(orig []string) []string { return orig }
-	makeLine := func(_ netip.Prefix, orig []string) []string { return orig }
+	var reps []representation
+
+	add := func(header headerFunc, columns columnsFunc) {
+		makeHeader = addHeaderFunc(makeHeader, header)
+		reps = append(reps, representation{width: len(header(nil)), columns: columns})
+	}
+
+	if opts.Hash {
+		add(hashHeader, hashLine)
+	}
+
+	if opts.Classification {
+		add(classificationHeader, classificationLine)
+	}
+
+	if opts.IPVersion {
+		add(ipVersionHeader, ipVersionLine)
+	}
+
+	if opts.Midpoint {
+		if opts.IPv6Expanded {
+			add(midpointHeader, expandedMidpointLine)
+		} else {
+			add(midpointHeader, midpointLine)
+		}
+	}
+
+	if opts.PTR {
+		add(ptrHeader, ptrLine)
+	}
+
+	if opts.IPv4Integer32 {
+		if opts.IPv4Signed {
+			add(ipv4Integer32Header, ipv4SignedInteger32Line)
+		} else {
+			add(ipv4Integer32Header, ipv4Integer32Line)
+		}
+	}
+
+	if opts.IPv4Key {
+		add(ipv4KeyHeader, ipv4KeyLine)
+	}
+
+	if opts.Broadcast {
+		if opts.BroadcastIPv6 {
+			add(broadcastHeader, lastAddressLine)
+		} else {
+			add(broadcastHeader, broadcastLine)
+		}
+	}
 
-	if hexRange {
-		makeHeader = addHeaderFunc(makeHeader, hexRangeHeader)
-		makeLine = addLineFunc(makeLine, hexRangeLine)
+	if opts.Netmask {
+		add(netmaskHeader, netmaskLine)
 	}
 
-	if intRange {
-		makeHeader = addHeaderFunc(makeHeader, intRangeHeader)
-		makeLine = addLineFunc(makeLine, intRangeLine)
+	if opts.IPv4Octets {
+		add(ipv4OctetsHeader, ipv4OctetsLine)
 	}
 
-	if ipRange {
-		makeHeader = addHeaderFunc(makeHeader, rangeHeader)
-		makeLine = addLineFunc(makeLine, rangeLine)
+	if opts.Base64Range {
+		add(base64RangeHeader, base64RangeLine)
 	}
 
-	if cidr {
-		makeHeader = addHeaderFunc(makeHeader, cidrHeader)
-		makeLine = addLineFunc(makeLine, cidrLine)
+	if opts.BinaryRange {
+		add(binaryRangeHeader, binaryRangeLine)
 	}
 
-	return convert(input, output, makeHeader, makeLine)
+	if opts.IntRangeCombined {
+		add(intRangeCombinedHeader, intRangeCombinedLine(opts.integerBase()))
+	}
+
+	if opts.HexRange {
+		if opts.HexUppercase {
+			add(hexRangeHeader, upperHexRangeLine)
+		} else {
+			add(hexRangeHeader, hexRangeLine)
+		}
+	}
+
+	if opts.IntRange {
+		if opts.IntRangeByFamily {
+			add(intRangeByFamilyHeader, intRangeByFamilyLine(opts.integerBase()))
+		} else {
+			add(intRangeHeader, intRangeLine(opts.integerBase()))
+		}
+	}
+
+	if opts.IntegerCIDR {
+		add(integerCIDRHeader, integerCIDRLine(opts.integerBase()))
+	}
+
+	if opts.HostCIDRRange {
+		if opts.IPv6Uppercase {
+			add(hostCIDRRangeHeader, upperIPv6Line(hostCIDRRangeLine))
+		} else {
+			add(hostCIDRRangeHeader, hostCIDRRangeLine)
+		}
+	}
+
+	if opts.ExclusiveEndRange {
+		line := exclusiveEndRangeLine
+		if opts.IPv6Expanded {
+			line = expandedExclusiveEndRangeLine
+		}
+		if opts.IPv6Uppercase {
+			line = upperIPv6Line(line)
+		}
+		add(exclusiveEndRangeHeader, line)
+	}
+
+	if opts.StartCountRange {
+		line := startCountRangeLine
+		if opts.IPv6Expanded {
+			line = expandedStartCountRangeLine
+		}
+		if opts.IPv6Uppercase {
+			line = upperIPv6Line(line)
+		}
+		add(startCountRangeHeader, line)
+	}
+
+	if opts.IPRange {
+		line := rangeLine
+		if opts.IPv6Expanded {
+			line = expandedRangeLine
+		}
+		if opts.IPv6Uppercase {
+			line = upperIPv6Line(line)
+		}
+		add(rangeHeader, line)
+	}
+
+	if opts.CIDR {
+		if opts.IPv6Uppercase {
+			add(cidrHeader, upperIPv6Line(cidrLine))
+		} else {
+			add(cidrHeader, cidrLine)
+		}
+	}
+
+	leading := 0
+	if opts.RowIndex {
+		leading = 1
+	}
+	extra := 0
+	if opts.Locations != nil {
+		extra = len(locationHeader)
+	}
+
+	return makeHeader, combineLines(reps, leading, extra)
 }
 
 func addHeaderFunc(first, second headerFunc) headerFunc {
@@ -110,9 +1054,38 @@ func addHeaderFunc(first, second headerFunc) headerFunc {
 	}
 }
 
-func addLineFunc(first, second lineFunc) lineFunc {
-	return func(network netip.Prefix, line []string) []string {
-		return second(network, first(network, line))
+// representation is a network representation, which writes `width` columns.
+type representation struct {
+	width   int
+	columns columnsFunc
+}
+
+// combineLines returns a lineFunc writing the columns of `reps` followed by
+// the original columns. Like the header, the columns of the last
+// representation come first. Each record is allocated once, starting with
+// `leading` empty columns, e.g., for the row index, and with room for
+// `extra` more columns, e.g., for the location columns.
+func combineLines(reps []representation, leading, extra int) lineFunc {
+	width := leading
+	for _, r := range reps {
+		width += r.width
+	}
+
+	if width == 0 {
+		return func(_ netip.Prefix, orig []string) []string { return orig }
+	}
+
+	return func(network netip.Prefix, orig []string) []string {
+		line := make([]string, width+len(orig), width+len(orig)+extra)
+
+		offset := width
+		for _, r := range reps {
+			offset -= r.width
+			r.columns(network, line[offset:offset+r.width])
+		}
+		copy(line[width:], orig)
+
+		return line
 	}
 }
 
@@ -120,101 +1093,421 @@ func cidrHeader(orig []string) []string {
 	return append([]string{"network"}, orig...)
 }
 
-func cidrLine(network netip.Prefix, orig []string) []string {
-	return append([]string{network.String()}, orig...)
+func cidrLine(network netip.Prefix, columns []string) {
+	columns[0] = network.String()
 }
 
 func rangeHeader(orig []string) []string {
 	return append([]string{"network_start_ip", "network_last_ip"}, orig...)
 }
 
-func rangeLine(network netip.Prefix, orig []string) []string {
-	return append(
-		[]string{network.Addr().String(), netipx.PrefixLastIP(network).String()},
-		orig...,
-	)
+func rangeLine(network netip.Prefix, columns []string) {
+	columns[0] = network.Addr().String()
+	columns[1] = netipx.PrefixLastIP(network).String()
 }
 
-func intRangeHeader(orig []string) []string {
-	return append([]string{"network_start_integer", "network_last_integer"}, orig...)
+func expandedRangeLine(network netip.Prefix, columns []string) {
+	columns[0] = network.Addr().StringExpanded()
+	columns[1] = netipx.PrefixLastIP(network).StringExpanded()
 }
 
-func intRangeLine(network netip.Prefix, orig []string) []string {
-	startInt := new(big.Int)
+func exclusiveEndRangeHeader(orig []string) []string {
+	return append([]string{"network_start_ip", "network_end_ip_exclusive"}, orig...)
+}
 
-	startInt.SetBytes(network.Addr().AsSlice())
+func exclusiveEndRangeLine(network netip.Prefix, columns []string) {
+	columns[0] = network.Addr().String()
+	if end := netipx.PrefixLastIP(network).Next(); end.IsValid() {
+		columns[1] = end.String()
+	}
+}
 
-	endInt := new(big.Int)
-	endInt.SetBytes(netipx.PrefixLastIP(network).AsSlice())
+func expandedExclusiveEndRangeLine(network netip.Prefix, columns []string) {
+	columns[0] = network.Addr().StringExpanded()
+	if end := netipx.PrefixLastIP(network).Next(); end.IsValid() {
+		columns[1] = end.StringExpanded()
+	}
+}
 
-	return append(
-		[]string{startInt.String(), endInt.String()},
-		orig...,
-	)
+func startCountRangeHeader(orig []string) []string {
+	return append([]string{"network_start_ip", "network_address_count"}, orig...)
 }
 
-func hexRangeHeader(orig []string) []string {
-	return append([]string{"network_start_hex", "network_last_hex"}, orig...)
+func startCountRangeLine(network netip.Prefix, columns []string) {
+	columns[0] = network.Addr().String()
+	columns[1] = addressCount(network).String()
+}
+
+func expandedStartCountRangeLine(network netip.Prefix, columns []string) {
+	columns[0] = network.Addr().StringExpanded()
+	columns[1] = addressCount(network).String()
+}
+
+// addressCount returns the number of addresses in `network`.
+func addressCount(network netip.Prefix) *big.Int {
+	hostBits := uint(network.Addr().BitLen() - network.Bits())
+	return new(big.Int).Lsh(big.NewInt(1), hostBits)
+}
+
+func hostCIDRRangeHeader(orig []string) []string {
+	return append([]string{"network_start_cidr", "network_last_cidr"}, orig...)
+}
+
+func hostCIDRRangeLine(network netip.Prefix, columns []string) {
+	start := network.Addr()
+	last := netipx.PrefixLastIP(network)
+	columns[0] = netip.PrefixFrom(start, start.BitLen()).String()
+	columns[1] = netip.PrefixFrom(last, last.BitLen()).String()
+}
+
+// upperIPv6Line returns a columnsFunc writing the columns of `line` in
+// uppercase for IPv6 networks.
+func upperIPv6Line(line columnsFunc) columnsFunc {
+	return func(network netip.Prefix, columns []string) {
+		line(network, columns)
+		if network.Addr().Is4() {
+			return
+		}
+		for i, column := range columns {
+			columns[i] = strings.ToUpper(column)
+		}
+	}
+}
+
+func intRangeHeader(orig []string) []string {
+	return append([]string{"network_start_integer", "network_last_integer"}, orig...)
 }
 
-func hexRangeLine(network netip.Prefix, orig []string) []string {
+func intRangeLine(base int) columnsFunc {
+	return func(network netip.Prefix, columns []string) {
+		columns[0] = toInt(network.Addr(), base)
+		columns[1] = toInt(netipx.PrefixLastIP(network), base)
+	}
+}
+
+func intRangeByFamilyHeader(orig []string) []string {
 	return append(
 		[]string{
-			toHex(network.Addr()),
-			toHex(netipx.PrefixLastIP(network)),
+			"network_start_integer_v4",
+			"network_last_integer_v4",
+			"network_start_integer_v6",
+			"network_last_integer_v6",
 		},
 		orig...,
 	)
 }
 
+func intRangeByFamilyLine(base int) columnsFunc {
+	line := intRangeLine(base)
+	return func(network netip.Prefix, columns []string) {
+		if network.Addr().Is4() {
+			line(network, columns[:2])
+		} else {
+			line(network, columns[2:])
+		}
+	}
+}
+
+func integerCIDRHeader(orig []string) []string {
+	return append([]string{"network_start_integer", "prefix_length"}, orig...)
+}
+
+func integerCIDRLine(base int) columnsFunc {
+	return func(network netip.Prefix, columns []string) {
+		columns[0] = toInt(network.Addr(), base)
+		columns[1] = strconv.Itoa(network.Bits())
+	}
+}
+
+func intRangeCombinedHeader(orig []string) []string {
+	return append([]string{"network_integer_range"}, orig...)
+}
+
+func intRangeCombinedLine(base int) columnsFunc {
+	return func(network netip.Prefix, columns []string) {
+		columns[0] = toInt(network.Addr(), base) + "-" + toInt(netipx.PrefixLastIP(network), base)
+	}
+}
+
+func toInt(ip netip.Addr, base int) string {
+	if ip.Is4() {
+		return strconv.FormatUint(uint64(ipv4ToUint32(ip)), base)
+	}
+	return new(big.Int).SetBytes(ip.AsSlice()).Text(base)
+}
+
+func hashHeader(orig []string) []string {
+	return append([]string{"network_hash"}, orig...)
+}
+
+func hashLine(network netip.Prefix, columns []string) {
+	h := fnv.New32a()
+	// Writing to a hash.Hash never returns an error.
+	_, _ = h.Write([]byte(network.Masked().String()))
+	columns[0] = fmt.Sprintf("%08x", h.Sum32())
+}
+
+func ipVersionHeader(orig []string) []string {
+	return append([]string{"network_version"}, orig...)
+}
+
+func ipVersionLine(network netip.Prefix, columns []string) {
+	if network.Addr().Is4() {
+		columns[0] = "4"
+	} else {
+		columns[0] = "6"
+	}
+}
+
+func midpointHeader(orig []string) []string {
+	return append([]string{"network_midpoint_ip"}, orig...)
+}
+
+func midpointLine(network netip.Prefix, columns []string) {
+	columns[0] = midpoint(network).String()
+}
+
+func expandedMidpointLine(network netip.Prefix, columns []string) {
+	columns[0] = midpoint(network).StringExpanded()
+}
+
+// midpoint returns the average of the start and last address of `network`,
+// rounded down.
+func midpoint(network netip.Prefix) netip.Addr {
+	start := new(big.Int).SetBytes(network.Addr().AsSlice())
+	last := new(big.Int).SetBytes(netipx.PrefixLastIP(network).AsSlice())
+	mid := start.Add(start, last).Rsh(start, 1)
+
+	b := mid.FillBytes(make([]byte, network.Addr().BitLen()/8))
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// ptrHeader prepends the reverse DNS name columns of the start and last
+// address to `orig`.
+func ptrHeader(orig []string) []string {
+	return append([]string{"network_start_ptr", "network_last_ptr"}, orig...)
+}
+
+// ptrLine sets the reverse DNS names of the start and last address of
+// `network`.
+func ptrLine(network netip.Prefix, columns []string) {
+	columns[0] = ptrName(network.Addr())
+	columns[1] = ptrName(netipx.PrefixLastIP(network))
+}
+
+// ptrName returns the reverse DNS name of `ip`, the bytes of an IPv4 address
+// or the nibbles of an IPv6 address in reverse order under "in-addr.arpa" or
+// "ip6.arpa", respectively.
+func ptrName(ip netip.Addr) string {
+	b := ip.AsSlice()
+	var name strings.Builder
+	if ip.Is4() {
+		for i := len(b) - 1; i >= 0; i-- {
+			name.WriteString(strconv.Itoa(int(b[i])))
+			name.WriteByte('.')
+		}
+		name.WriteString("in-addr.arpa")
+		return name.String()
+	}
+
+	const digits = "0123456789abcdef"
+	for i := len(b) - 1; i >= 0; i-- {
+		name.WriteByte(digits[b[i]&0xf])
+		name.WriteByte('.')
+		name.WriteByte(digits[b[i]>>4])
+		name.WriteByte('.')
+	}
+	name.WriteString("ip6.arpa")
+	return name.String()
+}
+
+func hexRangeHeader(orig []string) []string {
+	return append([]string{"network_start_hex", "network_last_hex"}, orig...)
+}
+
+func hexRangeLine(network netip.Prefix, columns []string) {
+	columns[0] = toHex(network.Addr())
+	columns[1] = toHex(netipx.PrefixLastIP(network))
+}
+
+func upperHexRangeLine(network netip.Prefix, columns []string) {
+	columns[0] = strings.ToUpper(toHex(network.Addr()))
+	columns[1] = strings.ToUpper(toHex(netipx.PrefixLastIP(network)))
+}
+
 func toHex(ip netip.Addr) string {
 	return strings.TrimPrefix(hex.EncodeToString(ip.AsSlice()), "0")
 }
 
+func binaryRangeHeader(orig []string) []string {
+	return append([]string{"network_start_binary", "network_last_binary"}, orig...)
+}
+
+func binaryRangeLine(network netip.Prefix, columns []string) {
+	columns[0] = toBinary(network.Addr())
+	columns[1] = toBinary(netipx.PrefixLastIP(network))
+}
+
+func toBinary(ip netip.Addr) string {
+	var b strings.Builder
+	b.Grow(ip.BitLen())
+	for _, octet := range ip.AsSlice() {
+		fmt.Fprintf(&b, "%08b", octet)
+	}
+	return b.String()
+}
+
+func base64RangeHeader(orig []string) []string {
+	return append([]string{"network_start_base64", "network_last_base64"}, orig...)
+}
+
+func base64RangeLine(network netip.Prefix, columns []string) {
+	columns[0] = base64.StdEncoding.EncodeToString(network.Addr().AsSlice())
+	columns[1] = base64.StdEncoding.EncodeToString(netipx.PrefixLastIP(network).AsSlice())
+}
+
 func convert(
 	input io.Reader,
 	output io.Writer,
+	opts Options,
 	makeHeader headerFunc,
 	makeLine lineFunc,
-) error {
-	reader := csv.NewReader(input)
-	writer := csv.NewWriter(output)
-
-	header, err := reader.Read()
+) (Stats, error) {
+	rows, err := newRowConverter(input, opts, makeHeader, makeLine)
 	if err != nil {
-		return fmt.Errorf("reading CSV header: %w", err)
+		return Stats{}, err
+	}
+
+	return writeRows(rows, output, opts)
+}
+
+// writeRows writes the records converted by `rows` to `output` in the format
+// specified by `opts`.
+func writeRows(rows *RowConverter, output io.Writer, opts Options) (Stats, error) {
+	if opts.NewChunkWriter != nil {
+		return writeChunks(rows, opts)
+	}
+	if rows.empty {
+		return rows.stats, nil
 	}
 
-	newHeader := makeHeader(header[1:])
-	err = writer.Write(newHeader)
+	writer := newOutputWriter(output, opts)
+
+	err := writer.writeHeader(rows.header)
 	if err != nil {
-		return fmt.Errorf("writing CSV header: %w", err)
+		return rows.stats, fmt.Errorf("writing %s header: %w", opts.Format, err)
 	}
 
 	for {
-		record, err := reader.Read()
+		row, err := rows.nextRow()
 		if errors.Is(err, io.EOF) {
 			break
 		} else if err != nil {
-			return fmt.Errorf("reading CSV: %w", err)
+			return rows.stats, err
 		}
 
-		prefix, err := netip.ParsePrefix(record[0])
+		err = writer.write(row)
 		if err != nil {
-			return fmt.Errorf("parsing network (%s): %w", record[0], err)
+			return rows.stats, fmt.Errorf("writing %s on line %d: %w", opts.Format, rows.line, err)
 		}
+	}
 
-		err = writer.Write(makeLine(prefix, record[1:]))
-		if err != nil {
-			return fmt.Errorf("writing CSV: %w", err)
-		}
+	if err := writer.finish(); err != nil {
+		return rows.stats, err
+	}
+	rows.logDone("the output")
+	return rows.stats, nil
+}
+
+// outputWriter writes records to a buffered output in the format specified
+// by Options.
+type outputWriter struct {
+	recordWriter
+	opts     Options
+	buffered *bufio.Writer
+	trimmer  *trailingNewlineWriter
+}
+
+func newOutputWriter(output io.Writer, opts Options) *outputWriter {
+	w := &outputWriter{opts: opts, buffered: bufio.NewWriterSize(output, opts.bufferSize())}
+	var out io.Writer = w.buffered
+	if opts.NoTrailingNewline {
+		w.trimmer = &trailingNewlineWriter{writer: w.buffered}
+		out = w.trimmer
 	}
+	w.recordWriter = newRecordWriter(out, opts)
+	return w
+}
 
-	writer.Flush()
+// finish flushes the records and the buffered output.
+func (w *outputWriter) finish() error {
+	if err := w.flush(); err != nil {
+		return fmt.Errorf("flushing %s: %w", w.opts.Format, err)
+	}
 
-	if err := writer.Error(); err != nil {
-		return fmt.Errorf("flushing CSV: %w", err)
+	if w.trimmer != nil {
+		if err := w.trimmer.finish(); err != nil {
+			return fmt.Errorf("flushing output: %w", err)
+		}
 	}
 
+	if err := w.buffered.Flush(); err != nil {
+		return fmt.Errorf("flushing output: %w", err)
+	}
 	return nil
 }
+
+// findNetworkColumn returns the index of the column containing the network
+// in `header`.
+func findNetworkColumn(header []string, opts Options) (int, error) {
+	if opts.NetworkColumnName != "" {
+		i := columnIndex(header, opts.NetworkColumnName)
+		if i < 0 {
+			return 0, fmt.Errorf("network column %q not found in header", opts.NetworkColumnName)
+		}
+		return i, nil
+	}
+
+	if opts.NetworkColumn < 0 || opts.NetworkColumn >= len(header) {
+		return 0, fmt.Errorf(
+			"network column %d is out of range for a header with %d columns",
+			opts.NetworkColumn,
+			len(header),
+		)
+	}
+	return opts.NetworkColumn, nil
+}
+
+// columnIndex returns the index of the column named `name` in `header` or -1
+// if there is no such column.
+func columnIndex(header []string, name string) int {
+	for i, column := range header {
+		if column == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// field returns the value of the column at index `i` in `record` or an empty
+// string if the record is too short.
+func field(record []string, i int) string {
+	if i >= len(record) {
+		return ""
+	}
+	return record[i]
+}
+
+// splitRecord returns the value of the network column at index `column` and
+// the remaining columns in their original order.
+func splitRecord(record []string, column int) (string, []string) {
+	if column == 0 {
+		return record[0], record[1:]
+	}
+
+	rest := make([]string, 0, len(record)-1)
+	rest = append(rest, record[:column]...)
+	rest = append(rest, record[column+1:]...)
+	return record[column], rest
+}
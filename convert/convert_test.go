@@ -2,10 +2,14 @@ package convert
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net/netip"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -59,6 +63,183 @@ func TestRange(t *testing.T) {
 	)
 }
 
+func TestExpandedRange(t *testing.T) {
+	checkLine(
+		t,
+		expandedRangeLine,
+		"1.1.1.0/24",
+		[]string{"1.1.1.0", "1.1.1.255"},
+	)
+
+	checkLine(
+		t,
+		expandedRangeLine,
+		"2001:db8:85a3:42::/64",
+		[]string{
+			"2001:0db8:85a3:0042:0000:0000:0000:0000",
+			"2001:0db8:85a3:0042:ffff:ffff:ffff:ffff",
+		},
+	)
+
+	assert.Equal(
+		t,
+		map[string]string{
+			"network":          "2001:db8::/120",
+			"network_start_ip": "2001:0db8:0000:0000:0000:0000:0000:0000",
+			"network_last_ip":  "2001:0db8:0000:0000:0000:0000:0000:00ff",
+		},
+		Representations(
+			netip.MustParsePrefix("2001:db8::/120"),
+			Options{CIDR: true, IPRange: true, IPv6Expanded: true},
+		),
+	)
+}
+
+func TestHostCIDRRange(t *testing.T) {
+	checkHeader(t, hostCIDRRangeHeader, []string{"network_start_cidr", "network_last_cidr"})
+
+	checkLine(t, hostCIDRRangeLine, "1.0.0.0/24", []string{"1.0.0.0/32", "1.0.0.255/32"})
+	checkLine(t, hostCIDRRangeLine, "1.0.0.1/32", []string{"1.0.0.1/32", "1.0.0.1/32"})
+	checkLine(
+		t,
+		hostCIDRRangeLine,
+		"2001:db8:abcd::/48",
+		[]string{"2001:db8:abcd::/128", "2001:db8:abcd:ffff:ffff:ffff:ffff:ffff/128"},
+	)
+
+	var output strings.Builder
+	_, err := ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n1.0.0.0/24,2077456\n2001:db8::/32,6252001\n"),
+		&output,
+		Options{CIDR: true, IPRange: true, HostCIDRRange: true, IPv6Uppercase: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"network,network_start_ip,network_last_ip,network_start_cidr,network_last_cidr,geoname_id\n"+
+			"1.0.0.0/24,1.0.0.0,1.0.0.255,1.0.0.0/32,1.0.0.255/32,2077456\n"+
+			"2001:DB8::/32,2001:DB8::,2001:DB8:FFFF:FFFF:FFFF:FFFF:FFFF:FFFF,"+
+			"2001:DB8::/128,2001:DB8:FFFF:FFFF:FFFF:FFFF:FFFF:FFFF/128,6252001\n",
+		output.String(),
+	)
+}
+
+func TestExclusiveEndRange(t *testing.T) {
+	checkHeader(t, exclusiveEndRangeHeader, []string{"network_start_ip", "network_end_ip_exclusive"})
+
+	checkLine(t, exclusiveEndRangeLine, "1.0.0.0/24", []string{"1.0.0.0", "1.0.1.0"})
+	checkLine(t, exclusiveEndRangeLine, "1.0.0.255/32", []string{"1.0.0.255", "1.0.1.0"})
+	checkLine(t, exclusiveEndRangeLine, "255.255.255.0/24", []string{"255.255.255.0", ""})
+	checkLine(t, exclusiveEndRangeLine, "255.255.255.255/32", []string{"255.255.255.255", ""})
+	checkLine(t, exclusiveEndRangeLine, "0.0.0.0/0", []string{"0.0.0.0", ""})
+	checkLine(t, exclusiveEndRangeLine, "2001:db8::/32", []string{"2001:db8::", "2001:db9::"})
+	checkLine(t, exclusiveEndRangeLine, "ffff::/16", []string{"ffff::", ""})
+	checkLine(
+		t,
+		expandedExclusiveEndRangeLine,
+		"2001:db8::/32",
+		[]string{"2001:0db8:0000:0000:0000:0000:0000:0000", "2001:0db9:0000:0000:0000:0000:0000:0000"},
+	)
+
+	var output strings.Builder
+	_, err := ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n1.0.0.0/24,2077456\n2001:db8::/32,6252001\n"),
+		&output,
+		Options{CIDR: true, ExclusiveEndRange: true, IPv6Uppercase: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"network,network_start_ip,network_end_ip_exclusive,geoname_id\n"+
+			"1.0.0.0/24,1.0.0.0,1.0.1.0,2077456\n"+
+			"2001:DB8::/32,2001:DB8::,2001:DB9::,6252001\n",
+		output.String(),
+	)
+
+	_, err = ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n1.0.0.0/24,2077456\n"),
+		io.Discard,
+		Options{IPRange: true, ExclusiveEndRange: true},
+	)
+	assert.EqualError(t, err, "the IP range may not be used with the exclusive end range")
+}
+
+func TestStartCountRange(t *testing.T) {
+	checkHeader(t, startCountRangeHeader, []string{"network_start_ip", "network_address_count"})
+
+	checkLine(t, startCountRangeLine, "1.0.0.0/24", []string{"1.0.0.0", "256"})
+	checkLine(t, startCountRangeLine, "1.0.0.1/32", []string{"1.0.0.1", "1"})
+	checkLine(t, startCountRangeLine, "0.0.0.0/0", []string{"0.0.0.0", "4294967296"})
+	checkLine(t, startCountRangeLine, "2001:db8::/64", []string{"2001:db8::", "18446744073709551616"})
+	checkLine(t, startCountRangeLine, "::/0", []string{"::", "340282366920938463463374607431768211456"})
+	checkLine(
+		t,
+		expandedStartCountRangeLine,
+		"2001:db8::/126",
+		[]string{"2001:0db8:0000:0000:0000:0000:0000:0000", "4"},
+	)
+
+	var output strings.Builder
+	_, err := ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n1.0.0.0/24,2077456\n2001:db8::/120,6252001\n"),
+		&output,
+		Options{StartCountRange: true, IPv6Uppercase: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"network_start_ip,network_address_count,geoname_id\n"+
+			"1.0.0.0,256,2077456\n"+
+			"2001:DB8::,256,6252001\n",
+		output.String(),
+	)
+
+	for _, opts := range []Options{
+		{StartCountRange: true, IPRange: true},
+		{StartCountRange: true, ExclusiveEndRange: true},
+	} {
+		_, err = ConvertWithOptions(strings.NewReader("network,geoname_id\n1.0.0.0/24,2077456\n"), io.Discard, opts)
+		assert.EqualError(
+			t,
+			err,
+			"the start and count range may not be used with the IP range or exclusive end range",
+		)
+	}
+}
+
+func TestIPv6Uppercase(t *testing.T) {
+	checkLine(
+		t,
+		upperIPv6Line(rangeLine),
+		"2001:db8:abcd:ef00::/56",
+		[]string{"2001:DB8:ABCD:EF00::", "2001:DB8:ABCD:EFFF:FFFF:FFFF:FFFF:FFFF"},
+	)
+
+	checkLine(t, upperIPv6Line(cidrLine), "::ffff:1.0.0.0/120", []string{"::FFFF:1.0.0.0/120"})
+
+	assert.Equal(
+		t,
+		map[string]string{
+			"network":           "2001:DB8:ABCD:EF00::/56",
+			"network_start_ip":  "2001:0DB8:ABCD:EF00:0000:0000:0000:0000",
+			"network_last_ip":   "2001:0DB8:ABCD:EFFF:FFFF:FFFF:FFFF:FFFF",
+			"network_start_hex": "20010db8abcdef000000000000000000",
+			"network_last_hex":  "20010db8abcdefffffffffffffffffff",
+		},
+		Representations(
+			netip.MustParsePrefix("2001:db8:abcd:ef00::/56"),
+			Options{CIDR: true, IPRange: true, HexRange: true, IPv6Expanded: true, IPv6Uppercase: true},
+		),
+	)
+
+	// IPv4 networks are unaffected.
+	assert.Equal(
+		t,
+		map[string]string{"network": "1.0.0.0/24", "network_start_ip": "1.0.0.0", "network_last_ip": "1.0.0.255"},
+		Representations(netip.MustParsePrefix("1.0.0.0/24"), Options{CIDR: true, IPRange: true, IPv6Uppercase: true}),
+	)
+}
+
 func TestIntRange(t *testing.T) {
 	checkHeader(
 		t,
@@ -68,20 +249,142 @@ func TestIntRange(t *testing.T) {
 
 	checkLine(
 		t,
-		intRangeLine,
+		intRangeLine(10),
 		"1.1.1.0/24",
 		[]string{"16843008", "16843263"},
 	)
 
 	checkLine(
 		t,
-		intRangeLine,
+		intRangeLine(10),
+		"2001:0db8:85a3:0042::/64",
+		[]string{
+			"42540766452641155289225172512357220352",
+			"42540766452641155307671916586066771967",
+		},
+	)
+}
+
+func TestIntRangeByFamily(t *testing.T) {
+	checkHeader(
+		t,
+		intRangeByFamilyHeader,
+		[]string{
+			"network_start_integer_v4",
+			"network_last_integer_v4",
+			"network_start_integer_v6",
+			"network_last_integer_v6",
+		},
+	)
+
+	checkLine(t, intRangeByFamilyLine(10), "1.1.1.0/24", []string{"16843008", "16843263", "", ""})
+	checkLine(
+		t,
+		intRangeByFamilyLine(10),
 		"2001:0db8:85a3:0042::/64",
 		[]string{
+			"",
+			"",
 			"42540766452641155289225172512357220352",
 			"42540766452641155307671916586066771967",
 		},
 	)
+	// IPv4-mapped networks are IPv6 networks unless Unmap is set.
+	checkLine(
+		t,
+		intRangeByFamilyLine(10),
+		"::ffff:1.1.1.0/120",
+		[]string{"", "", "281470698586368", "281470698586623"},
+	)
+
+	var output bytes.Buffer
+	_, err := ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n1.0.0.0/24,2077456\n"),
+		&output,
+		Options{IntRange: true, IntRangeByFamily: true, IntegerCIDR: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"network_start_integer,prefix_length,network_start_integer_v4,network_last_integer_v4,"+
+			"network_start_integer_v6,network_last_integer_v6,geoname_id\n"+
+			"16777216,24,16777216,16777471,,,2077456\n",
+		output.String(),
+	)
+}
+
+func TestIntegerCIDR(t *testing.T) {
+	checkHeader(t, integerCIDRHeader, []string{"network_start_integer", "prefix_length"})
+
+	checkLine(t, integerCIDRLine(10), "1.1.1.0/24", []string{"16843008", "24"})
+	checkLine(t, integerCIDRLine(10), "0.0.0.0/0", []string{"0", "0"})
+	checkLine(t, integerCIDRLine(36), "1.1.1.0/24", []string{"a105c", "24"})
+	checkLine(
+		t,
+		integerCIDRLine(10),
+		"2001:0db8:85a3:0042::/64",
+		[]string{"42540766452641155289225172512357220352", "64"},
+	)
+	checkLine(
+		t,
+		integerCIDRLine(10),
+		"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff/128",
+		[]string{"340282366920938463463374607431768211455", "128"},
+	)
+
+	// The columns identify the network exactly.
+	for _, network := range []string{"1.1.1.0/24", "2001:db8::/32"} {
+		prefix := netip.MustParsePrefix(network)
+		reps := Representations(prefix, Options{IntegerCIDR: true})
+
+		start, ok := new(big.Int).SetString(reps["network_start_integer"], 10)
+		require.True(t, ok)
+		bits, err := strconv.Atoi(reps["prefix_length"])
+		require.NoError(t, err)
+
+		addr, ok := netip.AddrFromSlice(start.FillBytes(make([]byte, prefix.Addr().BitLen()/8)))
+		require.True(t, ok)
+		assert.Equal(t, prefix, netip.PrefixFrom(addr, bits))
+	}
+
+	_, err := ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n1.0.0.0/24,2077456\n"),
+		io.Discard,
+		Options{IntRange: true, IntegerCIDR: true},
+	)
+	assert.EqualError(t, err, "the integer range may not be used with the integer CIDR")
+}
+
+func TestIntegerBase(t *testing.T) {
+	checkLine(t, intRangeLine(36), "1.1.1.0/24", []string{"a105c", "a10cf"})
+	checkLine(
+		t,
+		intRangeLine(2),
+		"1.1.1.0/24",
+		[]string{"1000000010000000100000000", "1000000010000000111111111"},
+	)
+	checkLine(
+		t,
+		intRangeLine(36),
+		"2001:0db8:85a3:0042::/64",
+		[]string{"1w7k3vl7thgjb03kk9y5r3fgg", "1w7k3vl7thgjew8ylb0bvvw8v"},
+	)
+	checkLine(t, intRangeCombinedLine(36), "1.1.1.0/24", []string{"a105c-a10cf"})
+
+	assert.Equal(
+		t,
+		map[string]string{"network_start_integer": "a105c", "network_last_integer": "a10cf"},
+		Representations(netip.MustParsePrefix("1.1.1.0/24"), Options{IntRange: true, IntegerBase: 36}),
+	)
+
+	for _, base := range []int{1, 37, -1} {
+		_, err := ConvertWithOptions(
+			strings.NewReader(ipv4BlocksInput),
+			&bytes.Buffer{},
+			Options{IntRange: true, IntegerBase: base},
+		)
+		assert.EqualError(t, err, fmt.Sprintf("the integer base must be between 2 and 36, not %d", base))
+	}
 }
 
 func TestHexRange(t *testing.T) {
@@ -109,6 +412,82 @@ func TestHexRange(t *testing.T) {
 	)
 }
 
+func TestUpperHexRange(t *testing.T) {
+	checkLine(
+		t,
+		upperHexRangeLine,
+		"2001:0db8:85a3:abcd::/64",
+		[]string{
+			"20010DB885A3ABCD0000000000000000",
+			"20010DB885A3ABCDFFFFFFFFFFFFFFFF",
+		},
+	)
+
+	assert.Equal(
+		t,
+		map[string]string{
+			"network_start_hex": "AFDB0000",
+			"network_last_hex":  "AFDBFFFF",
+		},
+		Representations(
+			netip.MustParsePrefix("175.219.0.0/16"),
+			Options{HexRange: true, HexUppercase: true},
+		),
+	)
+}
+
+func TestBinaryRange(t *testing.T) {
+	checkHeader(
+		t,
+		binaryRangeHeader,
+		[]string{"network_start_binary", "network_last_binary"},
+	)
+
+	checkLine(
+		t,
+		binaryRangeLine,
+		"1.1.1.0/24",
+		[]string{
+			"00000001000000010000000100000000",
+			"00000001000000010000000111111111",
+		},
+	)
+
+	checkLine(
+		t,
+		binaryRangeLine,
+		"2001:0db8:85a3:0042::/64",
+		[]string{
+			"0010000000000001000011011011100010000101101000110000000001000010" +
+				"0000000000000000000000000000000000000000000000000000000000000000",
+			"0010000000000001000011011011100010000101101000110000000001000010" +
+				"1111111111111111111111111111111111111111111111111111111111111111",
+		},
+	)
+}
+
+func TestBase64Range(t *testing.T) {
+	checkHeader(
+		t,
+		base64RangeHeader,
+		[]string{"network_start_base64", "network_last_base64"},
+	)
+
+	checkLine(
+		t,
+		base64RangeLine,
+		"1.1.1.0/24",
+		[]string{"AQEBAA==", "AQEB/w=="},
+	)
+
+	checkLine(
+		t,
+		base64RangeLine,
+		"2001:0db8:85a3:0042::/64",
+		[]string{"IAENuIWjAEIAAAAAAAAAAA==", "IAENuIWjAEL//////////w=="},
+	)
+}
+
 func checkHeader(
 	t *testing.T,
 	makeHeader headerFunc,
@@ -124,7 +503,7 @@ func checkHeader(
 
 func checkLine(
 	t *testing.T,
-	makeLine lineFunc,
+	makeColumns columnsFunc,
 	network string,
 	expected []string,
 ) {
@@ -133,12 +512,9 @@ func checkLine(
 		t.Fatal(err)
 	}
 
-	suffix := []string{"1", "2"}
-	assert.Equal(
-		t,
-		append(expected, suffix...),
-		makeLine(p, suffix),
-	)
+	columns := make([]string, len(expected))
+	makeColumns(p, columns)
+	assert.Equal(t, expected, columns)
 }
 
 func TestCIDROutput(t *testing.T) {
@@ -245,6 +621,19 @@ func TestAllOutput(t *testing.T) {
 	)
 }
 
+// countryBlocksInput is a block file with empty represented_country_geoname_id
+// values.
+//
+//nolint:lll // The header is longer than a line.
+const countryBlocksInput = `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider
+1.0.0.0/24,2077456,2077456,,0,0
+4.69.140.16/29,6252001,6252001,,0,0
+5.61.192.0/21,2635167,2635167,,0,0
+2001:4220::/32,357994,357994,,0,0
+2402:d000::/32,1227603,1227603,,0,0
+2406:4000::/32,1835841,1835841,,0,0
+`
+
 func checkOutput(
 	t *testing.T,
 	name string,
@@ -254,18 +643,9 @@ func checkOutput(
 	hexRange bool,
 	expected []any,
 ) {
-	//nolint: lll
-	input := `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider
-1.0.0.0/24,2077456,2077456,,0,0
-4.69.140.16/29,6252001,6252001,,0,0
-5.61.192.0/21,2635167,2635167,,0,0
-2001:4220::/32,357994,357994,,0,0
-2402:d000::/32,1227603,1227603,,0,0
-2406:4000::/32,1835841,1835841,,0,0
-`
 	var outbuf bytes.Buffer
 
-	err := Convert(strings.NewReader(input), &outbuf, cidr, ipRange, intRange, hexRange)
+	err := Convert(strings.NewReader(countryBlocksInput), &outbuf, cidr, ipRange, intRange, hexRange)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -290,6 +670,38 @@ func checkOutput(
 	)
 }
 
+func TestNullValue(t *testing.T) {
+	var output strings.Builder
+	_, err := ConvertWithOptions(
+		strings.NewReader(countryBlocksInput),
+		&output,
+		Options{CIDR: true, ExclusiveEndRange: true, NullValue: `\N`, Limit: 2},
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"network,network_start_ip,network_end_ip_exclusive,geoname_id,registered_country_geoname_id,"+
+			"represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider\n"+
+			`1.0.0.0/24,1.0.0.0,1.0.1.0,2077456,2077456,\N,0,0`+"\n"+
+			`4.69.140.16/29,4.69.140.16,4.69.140.24,6252001,6252001,\N,0,0`+"\n",
+		output.String(),
+	)
+
+	// The network representations are unaffected.
+	output.Reset()
+	_, err = ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n255.255.255.0/24,\n"),
+		&output,
+		Options{CIDR: true, ExclusiveEndRange: true, NullValue: "NULL"},
+	)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"network,network_start_ip,network_end_ip_exclusive,geoname_id\n255.255.255.0/24,255.255.255.0,,NULL\n",
+		output.String(),
+	)
+}
+
 func TestFileWriting(t *testing.T) {
 	input := `network,something
 1.0.0.0/24,"some more"
@@ -320,9 +732,732 @@ func TestFileWriting(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	buf := bytes.NewBuffer(nil)
-	_, err = io.Copy(buf, outFile)
+	// The output file is replaced rather than truncated, so it is read by
+	// name.
+	b, err := os.ReadFile(outFile.Name())
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, string(b))
+}
+
+func TestFileWritingAtomic(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "input.csv")
+	outputFile := filepath.Join(dir, "output.csv")
+
+	require.NoError(t, os.WriteFile(outputFile, []byte("existing"), 0o640))
+
+	// A failed conversion leaves the existing file as it was.
+	require.NoError(t, os.WriteFile(inputFile, []byte("network,geoname_id\nnot-a-network,2077456\n"), 0o600))
+	_, err := ConvertFileWithOptions(inputFile, outputFile, Options{CIDR: true})
+	require.Error(t, err)
+
+	b, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "existing", string(b))
+
+	require.NoError(t, os.WriteFile(inputFile, []byte("network,geoname_id\n1.0.0.0/24,2077456\n"), 0o600))
+	_, err = ConvertFileWithOptions(inputFile, outputFile, Options{CIDR: true})
+	require.NoError(t, err)
+
+	b, err = os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "network,geoname_id\n1.0.0.0/24,2077456\n", string(b))
+
+	// The replaced file keeps its permissions.
+	info, err := os.Stat(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"input.csv", "output.csv"}, names)
+}
+
+func TestSameFile(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "input.csv")
+	input := "network,geoname_id\n1.0.0.0/24,1\n"
+	require.NoError(t, os.WriteFile(inputFile, []byte(input), 0o600))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	relative, err := filepath.Rel(wd, inputFile)
+	require.NoError(t, err)
+
+	link := filepath.Join(dir, "link.csv")
+	require.NoError(t, os.Symlink(inputFile, link))
+
+	assert.True(t, SameFile(inputFile, inputFile))
+	assert.True(t, SameFile(relative, inputFile))
+	assert.True(t, SameFile(filepath.Join(dir, ".", "input.csv"), inputFile))
+	assert.True(t, SameFile(link, inputFile))
+	assert.False(t, SameFile(filepath.Join(dir, "output.csv"), inputFile))
+	assert.False(t, SameFile("https://example.com/input.csv", inputFile))
+
+	for _, outputFile := range []string{relative, link} {
+		_, err = ConvertFileWithOptions(inputFile, outputFile, Options{CIDR: true})
+		assert.EqualError(
+			t,
+			err,
+			"the output file ("+outputFile+") is the same file as the input file ("+inputFile+")",
+		)
+	}
+
+	_, err = ConvertFilesWithOptions([]string{link, inputFile}, relative, Options{CIDR: true})
+	assert.EqualError(t, err, "the output file ("+relative+") is the same file as the input file ("+link+")")
+
+	// The input is left as it was.
+	b, err := os.ReadFile(inputFile)
+	require.NoError(t, err)
+	assert.Equal(t, input, string(b))
+}
+
+func TestNoSync(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "input.csv")
+	outputFile := filepath.Join(dir, "output.csv")
+
+	require.NoError(t, os.WriteFile(inputFile, []byte("network,geoname_id\n1.0.0.0/24,2077456\n"), 0o600))
+
+	_, err := ConvertFileWithOptions(inputFile, outputFile, Options{CIDR: true, NoSync: true})
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "network,geoname_id\n1.0.0.0/24,2077456\n", string(b))
+}
+
+func TestNoClobber(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "input.csv")
+	outputFile := filepath.Join(dir, "output.csv")
+
+	require.NoError(t, os.WriteFile(inputFile, []byte("network,geoname_id\n1.0.0.0/24,2077456\n"), 0o600))
+	require.NoError(t, os.WriteFile(outputFile, []byte("existing"), 0o600))
+
+	_, err := ConvertFileWithOptions(inputFile, outputFile, Options{CIDR: true, NoClobber: true})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, os.ErrExist))
+
+	b, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "existing", string(b))
+
+	require.NoError(t, os.Remove(outputFile))
+
+	_, err = ConvertFileWithOptions(inputFile, outputFile, Options{CIDR: true, NoClobber: true})
+	require.NoError(t, err)
+
+	b, err = os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, "network,geoname_id\n1.0.0.0/24,2077456\n", string(b))
+}
+
+func TestParseErrorLineNumber(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,2077456
+"4.69.140.16/29",6252001
+not-a-network,2635167
+`
+
+	err := Convert(strings.NewReader(input), io.Discard, true, false, false, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "parsing network on line 4 (not-a-network)")
+}
+
+func TestSkipInvalid(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,2077456
+bad,6252001
+5.61.192.0/21,2635167
+also-bad,357994
+`
+
+	var outbuf, rejectbuf bytes.Buffer
+
+	stats, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		Options{CIDR: true, SkipInvalid: true, RejectOutput: &rejectbuf},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, "network,geoname_id\n1.0.0.0/24,2077456\n5.61.192.0/21,2635167\n", outbuf.String())
+	assert.Equal(t, "network,geoname_id\nbad,6252001\nalso-bad,357994\n", rejectbuf.String())
+	assert.Equal(t, 2, stats.SkippedRecords)
+	assert.Equal(t, []int{3, 5}, stats.SkippedLines)
+	assert.Equal(t, 2, stats.RecordsProcessed)
+}
+
+func TestRequireCanonical(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,2077456
+1.2.3.5/24,6252001
+2001:4220::1/32,357994
+`
+
+	var outbuf, rejectbuf bytes.Buffer
+	_, err := ConvertWithOptions(strings.NewReader(input), &outbuf, Options{IPRange: true})
+	require.NoError(t, err)
+	assert.Contains(t, outbuf.String(), "1.2.3.5,1.2.3.255,6252001\n")
+
+	_, err = ConvertWithOptions(strings.NewReader(input), &outbuf, Options{CIDR: true, RequireCanonical: true})
+	assert.EqualError(
+		t,
+		err,
+		"parsing network on line 3 (1.2.3.5/24): host bits are set; the canonical form is 1.2.3.0/24",
+	)
+
+	stats, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		Options{CIDR: true, RequireCanonical: true, SkipInvalid: true, RejectOutput: &rejectbuf},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []int{3, 4}, stats.SkippedLines)
+	assert.Equal(t, "network,geoname_id\n1.2.3.5/24,6252001\n2001:4220::1/32,357994\n", rejectbuf.String())
+
+	for _, opts := range []Options{
+		{CIDR: true, IPRange: true, Normalize: true},
+		{CIDR: true, IPRange: true, Normalize: true, RequireCanonical: true},
+	} {
+		outbuf.Reset()
+		_, err = ConvertWithOptions(strings.NewReader(input), &outbuf, opts)
+		require.NoError(t, err)
+		assert.Equal(t, `network,network_start_ip,network_last_ip,geoname_id
+1.0.0.0/24,1.0.0.0,1.0.0.255,2077456
+1.2.3.0/24,1.2.3.0,1.2.3.255,6252001
+2001:4220::/32,2001:4220::,2001:4220:ffff:ffff:ffff:ffff:ffff:ffff,357994
+`, outbuf.String())
+	}
+}
+
+func TestSkipInvalidLineLimit(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("network,geoname_id\n")
+	for i := 0; i < maxSkippedLines+10; i++ {
+		input.WriteString("bad,1\n")
+	}
+
+	stats, err := ConvertWithOptions(
+		strings.NewReader(input.String()),
+		io.Discard,
+		Options{CIDR: true, SkipInvalid: true},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, maxSkippedLines+10, stats.SkippedRecords)
+	assert.Len(t, stats.SkippedLines, maxSkippedLines)
+}
+
+func TestNetworkColumn(t *testing.T) {
+	input := `geoname_id,country,network,is_anycast
+2077456,AU,1.0.0.0/24,0
+6252001,US,2001:4220::/32,1
+`
+
+	var outbuf bytes.Buffer
+
+	_, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		Options{IPRange: true, NetworkColumn: 2},
+	)
+	require.NoError(t, err)
+
+	expected := `network_start_ip,network_last_ip,geoname_id,country,is_anycast
+1.0.0.0,1.0.0.255,2077456,AU,0
+2001:4220::,2001:4220:ffff:ffff:ffff:ffff:ffff:ffff,6252001,US,1
+`
+	assert.Equal(t, expected, outbuf.String())
+}
+
+func TestNetworkColumnOutOfRange(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,2077456
+`
+
+	_, err := ConvertWithOptions(
+		strings.NewReader(input),
+		io.Discard,
+		Options{CIDR: true, NetworkColumn: 2},
+	)
+	require.EqualError(t, err, "network column 2 is out of range for a header with 2 columns")
+}
+
+func TestNetworkColumnName(t *testing.T) {
+	input := `autonomous_system_number,network,autonomous_system_organization
+13335,1.0.0.0/24,CLOUDFLARENET
+`
+
+	var outbuf bytes.Buffer
+
+	_, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		// NetworkColumnName takes precedence over NetworkColumn.
+		Options{CIDR: true, NetworkColumn: 2, NetworkColumnName: "network"},
+	)
+	require.NoError(t, err)
+
+	expected := `network,autonomous_system_number,autonomous_system_organization
+1.0.0.0/24,13335,CLOUDFLARENET
+`
+	assert.Equal(t, expected, outbuf.String())
+
+	_, err = ConvertWithOptions(
+		strings.NewReader(input),
+		io.Discard,
+		Options{CIDR: true, NetworkColumnName: "cidr"},
+	)
+	require.EqualError(t, err, `network column "cidr" not found in header`)
+}
+
+func TestRepresentations(t *testing.T) {
+	network, err := ParseNetwork("2001:0db8:85a3:0042::/64")
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		map[string]string{
+			"network":               "2001:db8:85a3:42::/64",
+			"network_start_ip":      "2001:db8:85a3:42::",
+			"network_last_ip":       "2001:db8:85a3:42:ffff:ffff:ffff:ffff",
+			"network_start_integer": "42540766452641155289225172512357220352",
+			"network_last_integer":  "42540766452641155307671916586066771967",
+			"network_start_hex":     "20010db885a300420000000000000000",
+			"network_last_hex":      "20010db885a30042ffffffffffffffff",
+		},
+		Representations(
+			network,
+			Options{CIDR: true, IPRange: true, IntRange: true, HexRange: true},
+		),
+	)
+
+	assert.Equal(
+		t,
+		map[string]string{
+			"network_start_integer": "16843008",
+			"network_last_integer":  "16843263",
+		},
+		Representations(netip.MustParsePrefix("1.1.1.0/24"), Options{IntRange: true}),
+	)
+
+	_, err = ParseNetwork("1.1.1.0")
+	require.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,2077456
+2001:4220::/32,357994
+`
+	count, err := Validate(strings.NewReader(input), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = Validate(strings.NewReader(input+"bad,1\n"), Options{})
+	require.EqualError(
+		t,
+		err,
+		`parsing network on line 4 (bad): netip.ParsePrefix("bad"): no '/'`,
+	)
+	assert.Equal(t, 2, count)
+}
+
+func TestStats(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,2077456
+4.69.140.16/29,6252001
+2001:4220::/32,357994
+2402:d000::/127,1227603
+`
+
+	stats, err := ConvertWithOptions(strings.NewReader(input), io.Discard, Options{CIDR: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, stats.RecordsProcessed)
+	assert.Equal(t, 2, stats.IPv4Count)
+	assert.Equal(t, 2, stats.IPv6Count)
+
+	// 2^8 + 2^3 + 2^96 + 2^1
+	expected, ok := new(big.Int).SetString("79228162514264337593543950602", 10)
+	require.True(t, ok)
+	assert.Equal(t, expected.String(), stats.TotalAddresses.String())
+}
+
+func TestIntRangeCombined(t *testing.T) {
+	checkHeader(
+		t,
+		intRangeCombinedHeader,
+		[]string{"network_integer_range"},
+	)
+
+	checkLine(
+		t,
+		intRangeCombinedLine(10),
+		"1.1.1.0/24",
+		[]string{"16843008-16843263"},
+	)
+
+	checkLine(
+		t,
+		intRangeCombinedLine(10),
+		"2001:0db8:85a3:0042::/64",
+		[]string{
+			"42540766452641155289225172512357220352-42540766452641155307671916586066771967",
+		},
+	)
+}
+
+func TestIPVersion(t *testing.T) {
+	checkHeader(
+		t,
+		ipVersionHeader,
+		[]string{"network_version"},
+	)
+
+	checkLine(t, ipVersionLine, "1.1.1.0/24", []string{"4"})
+	checkLine(t, ipVersionLine, "2001:0db8:85a3:0042::/64", []string{"6"})
+	checkLine(t, ipVersionLine, "::ffff:1.1.1.0/120", []string{"6"})
+
+	assert.Equal(
+		t,
+		map[string]string{"network": "1.1.1.0/24", "network_version": "4"},
+		Representations(netip.MustParsePrefix("1.1.1.0/24"), Options{CIDR: true, IPVersion: true}),
+	)
+}
+
+func TestHash(t *testing.T) {
+	checkHeader(
+		t,
+		hashHeader,
+		[]string{"network_hash"},
+	)
+
+	// These are fixed so that a change to the hash is noticed.
+	checkLine(t, hashLine, "1.1.1.0/24", []string{"39aef045"})
+	checkLine(t, hashLine, "1.1.1.1/24", []string{"39aef045"})
+	checkLine(t, hashLine, "2001:db8::/32", []string{"c0cc9a04"})
+}
+
+func TestMidpoint(t *testing.T) {
+	checkHeader(
+		t,
+		midpointHeader,
+		[]string{"network_midpoint_ip"},
+	)
+
+	for network, expected := range map[string]string{
+		"1.0.0.0/24":     "1.0.0.127",
+		"1.0.0.0/31":     "1.0.0.0",
+		"1.0.0.1/32":     "1.0.0.1",
+		"0.0.0.0/0":      "127.255.255.255",
+		"2001:db8::/32":  "2001:db8:7fff:ffff:ffff:ffff:ffff:ffff",
+		"2001:db8::/128": "2001:db8::",
+		"::/0":           "7fff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+	} {
+		checkLine(t, midpointLine, network, []string{expected})
+	}
+
+	checkLine(
+		t,
+		expandedMidpointLine,
+		"2001:db8::/120",
+		[]string{"2001:0db8:0000:0000:0000:0000:0000:007f"},
+	)
+}
+
+func TestPTR(t *testing.T) {
+	checkHeader(
+		t,
+		ptrHeader,
+		[]string{"network_start_ptr", "network_last_ptr"},
+	)
+
+	checkLine(t, ptrLine, "1.0.0.0/24", []string{"0.0.0.1.in-addr.arpa", "255.0.0.1.in-addr.arpa"})
+	checkLine(
+		t,
+		ptrLine,
+		"2001:db8::/120",
+		[]string{
+			"0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+			"f.f.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa",
+		},
+	)
+	// The reverse DNS name of an IPv4-mapped IPv6 address is under ip6.arpa.
+	checkLine(
+		t,
+		ptrLine,
+		"::ffff:1.2.3.4/128",
+		[]string{
+			"4.0.3.0.2.0.1.0.f.f.f.f.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.ip6.arpa",
+			"4.0.3.0.2.0.1.0.f.f.f.f.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.ip6.arpa",
+		},
+	)
+}
+
+func TestHasRepresentation(t *testing.T) {
+	assert.False(t, Options{SkipInvalid: true}.HasRepresentation())
+	assert.True(t, Options{CIDR: true}.HasRepresentation())
+	assert.True(t, Options{Base64Range: true}.HasRepresentation())
+}
+
+func TestUnmapPrefix(t *testing.T) {
+	tests := map[string]string{
+		"::ffff:1.2.3.0/120": "1.2.3.0/24",
+		"::ffff:0.0.0.0/96":  "0.0.0.0/0",
+		"::ffff:0.0.0.0/95":  "::ffff:0.0.0.0/95",
+		"1.2.3.0/24":         "1.2.3.0/24",
+		"2001:4220::/32":     "2001:4220::/32",
+	}
+
+	for network, expected := range tests {
+		assert.Equal(
+			t,
+			expected,
+			unmapPrefix(netip.MustParsePrefix(network)).String(),
+			network,
+		)
+	}
+}
+
+func TestUnmap(t *testing.T) {
+	input := `network,geoname_id
+::ffff:1.2.3.0/120,2077456
+`
+
+	var outbuf bytes.Buffer
+
+	stats, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		Options{CIDR: true, IntRange: true, HexRange: true, Unmap: true},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		"network,network_start_integer,network_last_integer,network_start_hex,network_last_hex,geoname_id\n"+
+			"1.2.3.0/24,16909056,16909311,1020300,10203ff,2077456\n",
+		outbuf.String(),
+	)
+	assert.Equal(t, 1, stats.IPv4Count)
+}
+
+func TestEmptyInput(t *testing.T) {
+	var outbuf bytes.Buffer
+	stats, err := ConvertWithOptions(strings.NewReader(""), &outbuf, Options{CIDR: true, IPRange: true})
+	require.NoError(t, err)
+	assert.Empty(t, outbuf.String())
+	assert.Equal(t, 0, stats.RecordsProcessed)
+
+	_, err = ConvertWithOptions(
+		strings.NewReader(""),
+		&outbuf,
+		Options{CIDR: true, ErrorOnEmpty: true},
+	)
+	require.ErrorIs(t, err, ErrEmptyInput)
+}
+
+func TestHeaderOnlyInput(t *testing.T) {
+	var outbuf bytes.Buffer
+	stats, err := ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n"),
+		&outbuf,
+		Options{CIDR: true, IPRange: true, ErrorOnEmpty: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "network,network_start_ip,network_last_ip,geoname_id\n", outbuf.String())
+	assert.Equal(t, 0, stats.RecordsProcessed)
+}
+
+func TestErrorOnEmptyOutput(t *testing.T) {
+	input := "network,geoname_id\n1.0.0.0/24,2077456\n2001:4220::/32,357994\n"
+
+	for name, in := range map[string]string{"empty": "", "header only": "network,geoname_id\n"} {
+		_, err := ConvertWithOptions(
+			strings.NewReader(in),
+			io.Discard,
+			Options{CIDR: true, ErrorOnEmptyOutput: true},
+		)
+		require.ErrorIs(t, err, ErrEmptyOutput, name)
+	}
+
+	// Every record is filtered out.
+	within := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	_, err := ConvertWithOptions(
+		strings.NewReader(input),
+		io.Discard,
+		Options{CIDR: true, Within: within, ErrorOnEmptyOutput: true},
+	)
+	require.ErrorIs(t, err, ErrEmptyOutput)
+
+	// Every record is skipped, including when Skip exceeds the records.
+	for _, skip := range []int{2, 3, 10} {
+		_, err = ConvertWithOptions(
+			strings.NewReader(input),
+			io.Discard,
+			Options{CIDR: true, Skip: skip, ErrorOnEmptyOutput: true},
+		)
+		require.ErrorIs(t, err, ErrEmptyOutput, "skip %d", skip)
+	}
+
+	var outbuf bytes.Buffer
+	stats, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		Options{CIDR: true, Skip: 1, ErrorOnEmptyOutput: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.RecordsProcessed)
+	assert.Equal(t, "network,geoname_id\n2001:4220::/32,357994\n", outbuf.String())
+
+	// The existing output file is kept.
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "input.csv")
+	outputFile := filepath.Join(dir, "output.csv")
+	require.NoError(t, os.WriteFile(inputFile, []byte(input), 0o600))
+	require.NoError(t, os.WriteFile(outputFile, []byte("existing"), 0o600))
+
+	_, err = ConvertFileWithOptions(
+		inputFile,
+		outputFile,
+		Options{CIDR: true, Within: within, Sort: true, ErrorOnEmptyOutput: true},
+	)
+	require.ErrorIs(t, err, ErrEmptyOutput)
+
+	b, err := os.ReadFile(outputFile)
 	require.NoError(t, err)
+	assert.Equal(t, "existing", string(b))
+}
+
+func BenchmarkConvertWorkers(b *testing.B) {
+	input := generatedInput(10000)
+	opts := Options{CIDR: true, IPRange: true, IntRange: true, HexRange: true}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		opts.Workers = workers
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := ConvertWithOptions(strings.NewReader(input), io.Discard, opts)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkConvertFormats(b *testing.B) {
+	input := generatedInput(10000)
+	opts := Options{CIDR: true, IPRange: true, IntRange: true, HexRange: true}
+
+	for _, format := range []OutputFormat{OutputFormatCSV, OutputFormatTSVRaw} {
+		opts.Format = format
+		b.Run(format.String(), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := ConvertWithOptions(strings.NewReader(input), io.Discard, opts)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMakeLine(b *testing.B) {
+	_, makeLine := buildFuncs(Options{
+		CIDR:        true,
+		IPRange:     true,
+		IntRange:    true,
+		HexRange:    true,
+		Base64Range: true,
+		Netmask:     true,
+	})
+	networks := []netip.Prefix{
+		netip.MustParsePrefix("1.0.0.0/24"),
+		netip.MustParsePrefix("2001:4220::/32"),
+	}
+	orig := []string{"2077456", "2077456", "", "0", "0", "", "-27.4766", "153.0166", "1000"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		makeLine(networks[i%len(networks)], orig)
+	}
+}
+
+// countingWriter counts the calls to Write.
+type countingWriter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestBufferSize(t *testing.T) {
+	input := generatedInput(5000)
 
-	assert.Equal(t, expected, buf.String())
+	tests := []struct {
+		bufferSize int
+		maxWrites  int
+	}{
+		{bufferSize: 16, maxWrites: 1_000_000},
+		{bufferSize: 4096, maxWrites: 1_000_000},
+		{bufferSize: 0, maxWrites: 20},
+		{bufferSize: 1 << 20, maxWrites: 1},
+	}
+
+	var expected string
+	for _, test := range tests {
+		var output countingWriter
+		_, err := ConvertWithOptions(
+			strings.NewReader(input),
+			&output,
+			Options{IPRange: true, BufferSize: test.bufferSize},
+		)
+		require.NoError(t, err)
+
+		if expected == "" {
+			expected = output.String()
+		}
+		assert.Equal(t, expected, output.String())
+		assert.LessOrEqual(t, output.writes, test.maxWrites, "buffer size %d", test.bufferSize)
+	}
+}
+
+func BenchmarkConvertFileBufferSize(b *testing.B) {
+	dir := b.TempDir()
+	inputFile := filepath.Join(dir, "input.csv")
+	outputFile := filepath.Join(dir, "output.csv")
+
+	err := os.WriteFile(inputFile, []byte(generatedInput(50000)), 0o600)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, size := range []int{4096, 64 * 1024, 1 << 20} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_, err := ConvertFileWithOptions(
+					inputFile,
+					outputFile,
+					Options{CIDR: true, IPRange: true, BufferSize: size},
+				)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
 }
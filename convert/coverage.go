@@ -0,0 +1,201 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"text/tabwriter"
+
+	"go4.org/netipx"
+)
+
+// Coverage summarizes the address space covered by the networks of an
+// input, e.g., to check that an extract only contains the expected space.
+type Coverage struct {
+	IPv4 FamilyCoverage
+	IPv6 FamilyCoverage
+}
+
+// FamilyCoverage is the coverage of one address family.
+type FamilyCoverage struct {
+	// Networks is the number of input networks of the family.
+	Networks int
+	// Prefixes are the fewest prefixes covering exactly the addresses of the
+	// networks, in order. Overlapping and adjacent networks are merged.
+	Prefixes []netip.Prefix
+	// Supernet is the smallest single prefix containing every network. It
+	// is the zero netip.Prefix if there are no networks.
+	Supernet netip.Prefix
+	// Addresses is the number of distinct addresses covered.
+	Addresses *big.Int
+	// Percent is Addresses as a percentage of the address space of the
+	// family.
+	Percent float64
+}
+
+// SummarizeCoverage reads the MaxMind GeoIP2 or GeoLite2 CSV in the `input`
+// io.Reader and returns the address space covered by its networks. The
+// networks are held in memory as a set of ranges, but the other columns are
+// not. The network representation options are ignored.
+func SummarizeCoverage(input io.Reader, opts Options) (Coverage, error) {
+	opts.Sort = false
+
+	makeHeader, makeLine := buildFuncs(Options{RowIndex: opts.RowIndex})
+	rows, err := newRowConverter(input, opts, makeHeader, makeLine)
+	if err != nil {
+		return Coverage{}, err
+	}
+
+	var ipv4, ipv6 netipx.IPSetBuilder
+	var ipv4Networks, ipv6Networks int
+	for {
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return Coverage{}, err
+		}
+
+		network := row.network.Masked()
+		if network.Addr().Is4() {
+			ipv4.AddPrefix(network)
+			ipv4Networks++
+		} else {
+			ipv6.AddPrefix(network)
+			ipv6Networks++
+		}
+	}
+
+	c := Coverage{}
+	c.IPv4, err = newFamilyCoverage(&ipv4, ipv4Networks, 32)
+	if err != nil {
+		return Coverage{}, err
+	}
+	c.IPv6, err = newFamilyCoverage(&ipv6, ipv6Networks, 128)
+	if err != nil {
+		return Coverage{}, err
+	}
+	return c, nil
+}
+
+// Merge returns the coverage of the networks of both `c` and `other`, e.g.,
+// to summarize several inputs.
+func (c Coverage) Merge(other Coverage) (Coverage, error) {
+	var err error
+	c.IPv4, err = c.IPv4.merge(other.IPv4, 32)
+	if err != nil {
+		return Coverage{}, err
+	}
+	c.IPv6, err = c.IPv6.merge(other.IPv6, 128)
+	if err != nil {
+		return Coverage{}, err
+	}
+	return c, nil
+}
+
+func (f FamilyCoverage) merge(other FamilyCoverage, bits int) (FamilyCoverage, error) {
+	var b netipx.IPSetBuilder
+	for _, prefix := range f.Prefixes {
+		b.AddPrefix(prefix)
+	}
+	for _, prefix := range other.Prefixes {
+		b.AddPrefix(prefix)
+	}
+	return newFamilyCoverage(&b, f.Networks+other.Networks, bits)
+}
+
+func newFamilyCoverage(b *netipx.IPSetBuilder, networks, bits int) (FamilyCoverage, error) {
+	set, err := b.IPSet()
+	if err != nil {
+		return FamilyCoverage{}, fmt.Errorf("summarizing coverage: %w", err)
+	}
+
+	f := FamilyCoverage{
+		Networks:  networks,
+		Prefixes:  set.Prefixes(),
+		Addresses: new(big.Int),
+	}
+	for _, prefix := range f.Prefixes {
+		f.Addresses.Add(f.Addresses, new(big.Int).Lsh(big.NewInt(1), uint(bits-prefix.Bits())))
+	}
+
+	space := new(big.Float).SetInt(new(big.Int).Lsh(big.NewInt(1), uint(bits)))
+	percent := new(big.Float).SetInt(f.Addresses)
+	percent.Quo(percent.Mul(percent, big.NewFloat(100)), space)
+	f.Percent, _ = percent.Float64()
+
+	ranges := set.Ranges()
+	if len(ranges) > 0 {
+		f.Supernet = supernet(ranges[0].From(), ranges[len(ranges)-1].To())
+	}
+	return f, nil
+}
+
+// supernet returns the smallest prefix containing both `start` and `last`,
+// where `start` is not after `last`.
+func supernet(start, last netip.Addr) netip.Prefix {
+	for bits := start.BitLen(); bits > 0; bits-- {
+		prefix := netip.PrefixFrom(start, bits).Masked()
+		if prefix.Contains(last) {
+			return prefix
+		}
+	}
+	return netip.PrefixFrom(start, 0).Masked()
+}
+
+// WriteText writes the coverage to `w` as human-readable text, e.g.:
+//
+//	IPv4 networks:  2
+//	IPv4 supernet:  1.0.0.0/23
+//	IPv4 addresses: 512 (1.19209e-05%)
+//	IPv4 prefixes:  1.0.0.0/23
+func (c Coverage) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for _, family := range []struct {
+		name     string
+		coverage FamilyCoverage
+	}{
+		{"IPv4", c.IPv4},
+		{"IPv6", c.IPv6},
+	} {
+		f := family.coverage
+		supernet := "none"
+		if f.Supernet.IsValid() {
+			supernet = f.Supernet.String()
+		}
+		addresses := "0"
+		if f.Addresses != nil {
+			addresses = f.Addresses.String()
+		}
+
+		lines := [][2]string{
+			{"networks:", strconv.Itoa(f.Networks)},
+			{"supernet:", supernet},
+			{"addresses:", addresses + " (" + strconv.FormatFloat(f.Percent, 'g', 6, 64) + "%)"},
+		}
+		for i, prefix := range f.Prefixes {
+			name := ""
+			if i == 0 {
+				name = "prefixes:"
+			}
+			lines = append(lines, [2]string{name, prefix.String()})
+		}
+
+		for _, line := range lines {
+			label := family.name + " " + line[0]
+			if line[0] == "" {
+				label = ""
+			}
+			if _, err := fmt.Fprintf(tw, "%s\t%s\n", label, line[1]); err != nil {
+				return fmt.Errorf("writing coverage: %w", err)
+			}
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing coverage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package convert
+
+import (
+	"bytes"
+	"math/big"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeCoverage(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,1
+1.0.1.0/24,2
+1.0.1.128/25,3
+8.8.8.0/24,4
+2001:db8::/33,5
+2001:db8:8000::/33,6
+`
+
+	coverage, err := SummarizeCoverage(strings.NewReader(input), Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, coverage.IPv4.Networks)
+	assert.Equal(
+		t,
+		[]netip.Prefix{netip.MustParsePrefix("1.0.0.0/23"), netip.MustParsePrefix("8.8.8.0/24")},
+		coverage.IPv4.Prefixes,
+	)
+	assert.Equal(t, netip.MustParsePrefix("0.0.0.0/4"), coverage.IPv4.Supernet)
+	assert.Equal(t, big.NewInt(768), coverage.IPv4.Addresses)
+	assert.InDelta(t, 768.0/(1<<32)*100, coverage.IPv4.Percent, 1e-12)
+
+	assert.Equal(t, 2, coverage.IPv6.Networks)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("2001:db8::/32")}, coverage.IPv6.Prefixes)
+	assert.Equal(t, netip.MustParsePrefix("2001:db8::/32"), coverage.IPv6.Supernet)
+
+	var buf bytes.Buffer
+	require.NoError(t, coverage.WriteText(&buf))
+	assert.Equal(t, `IPv4 networks:  4
+IPv4 supernet:  0.0.0.0/4
+IPv4 addresses: 768 (1.78814e-05%)
+IPv4 prefixes:  1.0.0.0/23
+                8.8.8.0/24
+IPv6 networks:  2
+IPv6 supernet:  2001:db8::/32
+IPv6 addresses: 79228162514264337593543950336 (2.32831e-08%)
+IPv6 prefixes:  2001:db8::/32
+`, buf.String())
+}
+
+func TestSummarizeCoverageEmpty(t *testing.T) {
+	coverage, err := SummarizeCoverage(strings.NewReader("network,geoname_id\n"), Options{})
+	require.NoError(t, err)
+
+	assert.Zero(t, coverage.IPv4.Networks)
+	assert.Empty(t, coverage.IPv4.Prefixes)
+	assert.False(t, coverage.IPv4.Supernet.IsValid())
+
+	var buf bytes.Buffer
+	require.NoError(t, coverage.WriteText(&buf))
+	assert.Contains(t, buf.String(), "IPv6 supernet:  none\nIPv6 addresses: 0 (0%)\n")
+}
+
+func TestCoverageMerge(t *testing.T) {
+	a, err := SummarizeCoverage(strings.NewReader("network\n1.0.0.0/24\n"), Options{})
+	require.NoError(t, err)
+	b, err := SummarizeCoverage(strings.NewReader("network\n1.0.1.0/24\n::/0\n"), Options{})
+	require.NoError(t, err)
+
+	merged, err := a.Merge(b)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, merged.IPv4.Networks)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.0.0.0/23")}, merged.IPv4.Prefixes)
+	assert.Equal(t, netip.MustParsePrefix("1.0.0.0/23"), merged.IPv4.Supernet)
+	assert.Equal(t, netip.MustParsePrefix("::/0"), merged.IPv6.Supernet)
+	assert.InDelta(t, 100, merged.IPv6.Percent, 0)
+}
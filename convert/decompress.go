@@ -0,0 +1,65 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// bzip2Magic is followed by the block size, from '1' to '9'.
+var bzip2Magic = []byte("BZh")
+
+// DecompressReader returns a reader that decompresses `input` if it is
+// gzip or bzip2 compressed, or compressed with a Compression registered with
+// RegisterCompression. Otherwise, it returns a reader with the same contents
+// as `input`. The returned reader must be used in place of `input` as bytes
+// may have been buffered from it.
+func DecompressReader(input io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(input)
+
+	n := len(bzip2Magic) + 1
+	if m := maxMagicLen(); m > n {
+		n = m
+	}
+	magic, err := buffered.Peek(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("detecting compression: %w", err)
+	}
+
+	if isBzip2(magic) {
+		return bzip2.NewReader(buffered), nil
+	}
+
+	if compression, ok := compressionWithMagic(magic); ok {
+		r, err := compression.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("creating decompressing reader: %w", err)
+		}
+		return r, nil
+	}
+
+	if !bytes.HasPrefix(magic, gzipMagic) {
+		return buffered, nil
+	}
+
+	gzReader, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("creating gzip reader: %w", err)
+	}
+	return gzReader, nil
+}
+
+// isBzip2 returns true if `magic`, the first bytes of an input, is the
+// start of a bzip2 stream. The block size is checked as well, as a CSV
+// could start with "BZh".
+func isBzip2(magic []byte) bool {
+	return len(magic) > len(bzip2Magic) &&
+		bytes.HasPrefix(magic, bzip2Magic) &&
+		magic[len(bzip2Magic)] >= '1' && magic[len(bzip2Magic)] <= '9'
+}
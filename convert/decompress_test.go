@@ -0,0 +1,87 @@
+package convert
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const decompressInput = "network,geoname_id\n1.0.0.0/24,2077456\n"
+
+func TestDecompressReaderGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err := w.Write([]byte(decompressInput))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := DecompressReader(&compressed)
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, decompressInput, string(b))
+}
+
+// bzip2Input is decompressInput compressed with `bzip2 -9`, as the standard
+// library cannot write bzip2.
+const bzip2Input = "QlpoOTFBWSZTWULQzQ4AABBbgAAQAAX3gAAApquUgCAAMUGjRoMg" +
+	"NDNNom2kExqempdBoCBwQwhWFon6u1Uq7b5so5Rf8XckU4UJBC0M0OA="
+
+func TestDecompressReaderBzip2(t *testing.T) {
+	compressed, err := base64.StdEncoding.DecodeString(bzip2Input)
+	require.NoError(t, err)
+
+	r, err := DecompressReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, decompressInput, string(b))
+
+	var outbuf bytes.Buffer
+	_, err = ConvertWithOptions(bytes.NewReader(compressed), &outbuf, Options{CIDR: true, AutoDecompress: true})
+	require.NoError(t, err)
+	assert.Equal(t, decompressInput, outbuf.String())
+}
+
+func TestDecompressReaderPlain(t *testing.T) {
+	// "BZh" is only bzip2 if followed by a block size.
+	for _, input := range []string{decompressInput, "n", "", "BZh", "BZhx,y\n"} {
+		r, err := DecompressReader(strings.NewReader(input))
+		require.NoError(t, err)
+
+		// The peeked bytes must not be lost.
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, input, string(b))
+	}
+}
+
+func TestAutoDecompress(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err := w.Write([]byte(decompressInput))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	var outbuf bytes.Buffer
+	_, err = ConvertWithOptions(
+		&compressed,
+		&outbuf,
+		Options{IPRange: true, AutoDecompress: true},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		"network_start_ip,network_last_ip,geoname_id\n1.0.0.0,1.0.0.255,2077456\n",
+		outbuf.String(),
+	)
+}
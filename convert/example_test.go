@@ -0,0 +1,49 @@
+package convert
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func ExampleConvertWithOptions() {
+	input := strings.NewReader(`network,geoname_id
+1.0.0.0/24,2077456
+2001:4220::/32,357994
+`)
+
+	stats, err := ConvertWithOptions(input, os.Stdout, Options{IPRange: true})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(stats.RecordsProcessed, "records")
+
+	// Output:
+	// network_start_ip,network_last_ip,geoname_id
+	// 1.0.0.0,1.0.0.255,2077456
+	// 2001:4220::,2001:4220:ffff:ffff:ffff:ffff:ffff:ffff,357994
+	// 2 records
+}
+
+func ExampleOptionsFromFlags() {
+	fs := flag.NewFlagSet("converter", flag.ExitOnError)
+	RegisterFlags(fs)
+	//nolint:errcheck // ExitOnError exits on errors.
+	fs.Parse([]string{"-include-cidr", "-include-integer-range", "-limit", "1"})
+
+	opts := OptionsFromFlags(fs)
+
+	input := strings.NewReader(`network,geoname_id
+1.0.0.0/24,2077456
+2001:4220::/32,357994
+`)
+	if _, err := ConvertWithOptions(input, os.Stdout, opts); err != nil {
+		fmt.Println(err)
+	}
+
+	// Output:
+	// network,network_start_integer,network_last_integer,geoname_id
+	// 1.0.0.0/24,16777216,16777471,2077456
+}
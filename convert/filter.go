@@ -0,0 +1,150 @@
+package convert
+
+import (
+	"fmt"
+	"math/rand"
+	"net/netip"
+
+	"go4.org/netipx"
+)
+
+// reservedNetworks are the special-use networks excluded by
+// Options.ExcludeReserved. These are the networks in the IANA IPv4 and IPv6
+// Special-Purpose Address Registries that are not globally reachable, as
+// well as multicast and, for IPv4, the reserved 240.0.0.0/4 network. Each
+// has the class written by Options.Classification.
+var reservedNetworks = []struct {
+	network netip.Prefix
+	class   string
+}{
+	{netip.MustParsePrefix("0.0.0.0/8"), classUnspecified},         // "This network"
+	{netip.MustParsePrefix("10.0.0.0/8"), classPrivate},            // Private-Use
+	{netip.MustParsePrefix("100.64.0.0/10"), classShared},          // Shared Address Space
+	{netip.MustParsePrefix("127.0.0.0/8"), classLoopback},          // Loopback
+	{netip.MustParsePrefix("169.254.0.0/16"), classLinkLocal},      // Link Local
+	{netip.MustParsePrefix("172.16.0.0/12"), classPrivate},         // Private-Use
+	{netip.MustParsePrefix("192.0.0.0/24"), classReserved},         // IETF Protocol Assignments
+	{netip.MustParsePrefix("192.0.2.0/24"), classDocumentation},    // Documentation (TEST-NET-1)
+	{netip.MustParsePrefix("192.88.99.0/24"), classReserved},       // Deprecated 6to4 Relay Anycast
+	{netip.MustParsePrefix("192.168.0.0/16"), classPrivate},        // Private-Use
+	{netip.MustParsePrefix("198.18.0.0/15"), classReserved},        // Benchmarking
+	{netip.MustParsePrefix("198.51.100.0/24"), classDocumentation}, // Documentation (TEST-NET-2)
+	{netip.MustParsePrefix("203.0.113.0/24"), classDocumentation},  // Documentation (TEST-NET-3)
+	{netip.MustParsePrefix("224.0.0.0/4"), classMulticast},         // Multicast
+	{netip.MustParsePrefix("240.0.0.0/4"), classReserved},          // Reserved, including Limited Broadcast
+
+	{netip.MustParsePrefix("::/128"), classUnspecified},          // Unspecified Address
+	{netip.MustParsePrefix("::1/128"), classLoopback},            // Loopback Address
+	{netip.MustParsePrefix("::ffff:0:0/96"), classIPv4Mapped},    // IPv4-mapped Address
+	{netip.MustParsePrefix("64:ff9b:1::/48"), classReserved},     // IPv4-IPv6 Translation (local use)
+	{netip.MustParsePrefix("100::/64"), classReserved},           // Discard-Only Address Block
+	{netip.MustParsePrefix("2001::/23"), classReserved},          // IETF Protocol Assignments
+	{netip.MustParsePrefix("2001:db8::/32"), classDocumentation}, // Documentation
+	{netip.MustParsePrefix("fc00::/7"), classPrivate},            // Unique-Local
+	{netip.MustParsePrefix("fe80::/10"), classLinkLocal},         // Link-Local Unicast
+	{netip.MustParsePrefix("ff00::/8"), classMulticast},          // Multicast
+}
+
+// excludeReservedFilter returns a rowFilter that excludes records whose
+// network overlaps any of the reservedNetworks.
+func excludeReservedFilter() (rowFilter, error) {
+	var b netipx.IPSetBuilder
+	for _, r := range reservedNetworks {
+		b.AddPrefix(r.network)
+	}
+	set, err := b.IPSet()
+	if err != nil {
+		return nil, fmt.Errorf("building reserved set: %w", err)
+	}
+
+	return func(network netip.Prefix, _ []string) (bool, error) {
+		return !set.OverlapsPrefix(network), nil
+	}, nil
+}
+
+// withinFilter returns a rowFilter that keeps records whose network is
+// contained by, or if `overlap` is true overlaps, the networks in
+// `supernets`.
+func withinFilter(supernets []netip.Prefix, overlap bool) (rowFilter, error) {
+	var b netipx.IPSetBuilder
+	for _, p := range supernets {
+		b.AddPrefix(p)
+	}
+	set, err := b.IPSet()
+	if err != nil {
+		return nil, fmt.Errorf("building within set: %w", err)
+	}
+
+	if overlap {
+		return func(network netip.Prefix, _ []string) (bool, error) {
+			return set.OverlapsPrefix(network), nil
+		}, nil
+	}
+	return func(network netip.Prefix, _ []string) (bool, error) {
+		return set.ContainsPrefix(network), nil
+	}, nil
+}
+
+// dedupFilter returns a rowFilter that excludes records whose network was
+// already seen. This requires memory proportional to the number of unique
+// networks, of which there may be at most `max` unless it is zero.
+func dedupFilter(max int) rowFilter {
+	seen := map[netip.Prefix]struct{}{}
+
+	return func(network netip.Prefix, _ []string) (bool, error) {
+		if _, ok := seen[network]; ok {
+			return false, nil
+		}
+		if max > 0 && len(seen) == max {
+			return false, bufferLimitError("deduplicating", max)
+		}
+		seen[network] = struct{}{}
+		return true, nil
+	}
+}
+
+// sortedDedupFilter returns a rowFilter that excludes records whose network
+// is the same as the previous record's. This only removes all duplicates if
+// duplicate networks are adjacent in the input.
+func sortedDedupFilter() rowFilter {
+	var previous netip.Prefix
+
+	return func(network netip.Prefix, _ []string) (bool, error) {
+		if network == previous {
+			return false, nil
+		}
+		previous = network
+		return true, nil
+	}
+}
+
+// sampleEveryFilter returns a rowFilter that keeps every `n`th record,
+// starting with the first.
+func sampleEveryFilter(n int) rowFilter {
+	i := 0
+
+	return func(netip.Prefix, []string) (bool, error) {
+		keep := i%n == 0
+		i++
+		return keep, nil
+	}
+}
+
+// sampleRateFilter returns a rowFilter that keeps each record with
+// probability `rate`. The same `seed` and input always keep the same records.
+func sampleRateFilter(rate float64, seed int64) rowFilter {
+	//nolint:gosec // The sample does not need to be cryptographically random.
+	r := rand.New(rand.NewSource(seed))
+
+	return func(netip.Prefix, []string) (bool, error) {
+		return r.Float64() < rate, nil
+	}
+}
+
+// flagColumnFilter returns a rowFilter that excludes records with "1" in the
+// column at index `column`, e.g., is_anonymous_proxy.
+func flagColumnFilter(column int) rowFilter {
+	return func(_ netip.Prefix, rest []string) (bool, error) {
+		return field(rest, column) != "1", nil
+	}
+}
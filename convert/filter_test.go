@@ -0,0 +1,262 @@
+package convert
+
+import (
+	"bytes"
+	"io"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const filterInput = `network,geoname_id
+9.255.255.0/24,1
+10.0.0.0/16,2
+10.0.0.0/7,3
+2001:db8:1::/48,4
+2001:db9::/32,5
+`
+
+func convertFiltered(t *testing.T, opts Options) string {
+	t.Helper()
+
+	var outbuf bytes.Buffer
+	_, err := ConvertWithOptions(strings.NewReader(filterInput), &outbuf, opts)
+	require.NoError(t, err)
+	return outbuf.String()
+}
+
+func TestWithin(t *testing.T) {
+	within := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	assert.Equal(
+		t,
+		"network,geoname_id\n10.0.0.0/16,2\n2001:db8:1::/48,4\n",
+		convertFiltered(t, Options{CIDR: true, Within: within}),
+	)
+
+	assert.Equal(
+		t,
+		"network,geoname_id\n10.0.0.0/16,2\n10.0.0.0/7,3\n2001:db8:1::/48,4\n",
+		convertFiltered(t, Options{CIDR: true, Within: within, WithinOverlap: true}),
+	)
+}
+
+func TestExcludeReserved(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,1
+10.1.0.0/16,2
+172.0.0.0/8,3
+192.168.1.0/24,4
+8.8.8.0/24,5
+127.0.0.0/8,6
+169.254.0.0/16,7
+2001:4220::/32,8
+fd00::/8,9
+fe80::/64,10
+::1/128,11
+2001:db8::/48,12
+`
+
+	var outbuf bytes.Buffer
+	stats, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		Options{CIDR: true, ExcludeReserved: true},
+	)
+	require.NoError(t, err)
+
+	// 172.0.0.0/8 is excluded as it overlaps 172.16.0.0/12.
+	assert.Equal(
+		t,
+		"network,geoname_id\n1.0.0.0/24,1\n8.8.8.0/24,5\n2001:4220::/32,8\n",
+		outbuf.String(),
+	)
+	assert.Equal(t, 9, stats.FilteredRecords)
+}
+
+func TestDedup(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,1
+1.0.0.0/25,2
+2001:4220::/32,3
+1.0.0.0/24,4
+1.0.0.0/24,5
+2001:4220::/32,6
+1.0.0.0/25,7
+`
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{
+			name: "dedup",
+			opts: Options{CIDR: true, Dedup: true},
+			expected: "network,geoname_id\n1.0.0.0/24,1\n1.0.0.0/25,2\n" +
+				"2001:4220::/32,3\n",
+		},
+		{
+			name: "assume sorted",
+			opts: Options{CIDR: true, DedupAssumeSorted: true},
+			expected: "network,geoname_id\n1.0.0.0/24,1\n1.0.0.0/25,2\n" +
+				"2001:4220::/32,3\n1.0.0.0/24,4\n2001:4220::/32,6\n1.0.0.0/25,7\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var outbuf bytes.Buffer
+			_, err := ConvertWithOptions(strings.NewReader(input), &outbuf, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, outbuf.String())
+		})
+	}
+}
+
+func TestDedupMaxBufferedRows(t *testing.T) {
+	input := "network,geoname_id\n1.0.0.0/24,1\n1.0.0.0/24,2\n1.0.1.0/24,3\n1.0.0.0/24,4\n1.0.2.0/24,5\n"
+
+	// Only the unique networks count toward the limit.
+	var outbuf bytes.Buffer
+	_, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		Options{CIDR: true, Dedup: true, MaxBufferedRows: 3},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "network,geoname_id\n1.0.0.0/24,1\n1.0.1.0/24,3\n1.0.2.0/24,5\n", outbuf.String())
+
+	_, err = ConvertWithOptions(
+		strings.NewReader(input),
+		io.Discard,
+		Options{CIDR: true, Dedup: true, MaxBufferedRows: 2},
+	)
+	require.ErrorIs(t, err, ErrBufferLimit)
+	assert.EqualError(
+		t,
+		err,
+		"filtering record on line 6: deduplicating more than 2 records: too many records to hold in memory",
+	)
+
+	// The limit does not apply when only adjacent duplicates are removed.
+	_, err = ConvertWithOptions(
+		strings.NewReader(input),
+		io.Discard,
+		Options{CIDR: true, DedupAssumeSorted: true, MaxBufferedRows: 1},
+	)
+	require.NoError(t, err)
+}
+
+func TestSampleEvery(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "every second",
+			opts:     Options{CIDR: true, SampleEvery: 2},
+			expected: "network,geoname_id\n9.255.255.0/24,1\n10.0.0.0/7,3\n2001:db9::/32,5\n",
+		},
+		{
+			name:     "every fourth",
+			opts:     Options{CIDR: true, SampleEvery: 4},
+			expected: "network,geoname_id\n9.255.255.0/24,1\n2001:db9::/32,5\n",
+		},
+		{
+			name: "after other filters",
+			opts: Options{
+				CIDR:        true,
+				SampleEvery: 2,
+				Within:      []netip.Prefix{netip.MustParsePrefix("10.0.0.0/7")},
+			},
+			expected: "network,geoname_id\n10.0.0.0/16,2\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, convertFiltered(t, test.opts))
+		})
+	}
+}
+
+func TestSampleRate(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("network\n")
+	for i := 0; i < 1000; i++ {
+		input.WriteString(netip.AddrFrom4([4]byte{1, 0, byte(i >> 8), byte(i)}).String() + "/32\n")
+	}
+
+	sample := func(opts Options) string {
+		var outbuf bytes.Buffer
+		stats, err := ConvertWithOptions(strings.NewReader(input.String()), &outbuf, opts)
+		require.NoError(t, err)
+		assert.Equal(t, 1000, stats.RecordsProcessed+stats.FilteredRecords)
+		return outbuf.String()
+	}
+
+	first := sample(Options{CIDR: true, SampleRate: 0.1, SampleSeed: 1})
+	assert.Equal(t, first, sample(Options{CIDR: true, SampleRate: 0.1, SampleSeed: 1}))
+	assert.NotEqual(t, first, sample(Options{CIDR: true, SampleRate: 0.1, SampleSeed: 2}))
+
+	// The header plus roughly 100 records.
+	lines := strings.Count(first, "\n")
+	assert.Greater(t, lines, 50)
+	assert.Less(t, lines, 150)
+
+	assert.Equal(t, input.String(), sample(Options{CIDR: true, SampleRate: 1}))
+}
+
+func TestExcludeAnonymousProxyAndSatellite(t *testing.T) {
+	input := `network,geoname_id,is_anonymous_proxy,is_satellite_provider
+1.0.0.0/24,1,0,0
+1.0.1.0/24,2,1,0
+1.0.2.0/24,3,0,1
+1.0.3.0/24,4,1,1
+`
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected []string
+	}{
+		{
+			name:     "anonymous proxy",
+			opts:     Options{CIDR: true, ExcludeAnonymousProxy: true},
+			expected: []string{"1.0.0.0/24", "1.0.2.0/24"},
+		},
+		{
+			name:     "satellite",
+			opts:     Options{CIDR: true, ExcludeSatelliteProvider: true},
+			expected: []string{"1.0.0.0/24", "1.0.1.0/24"},
+		},
+		{
+			name:     "both",
+			opts:     Options{CIDR: true, ExcludeAnonymousProxy: true, ExcludeSatelliteProvider: true},
+			expected: []string{"1.0.0.0/24"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, err := NewRowConverter(strings.NewReader(input), test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, readNetworks(t, rows))
+			assert.Equal(t, 4-len(test.expected), rows.Stats().FilteredRecords)
+		})
+	}
+
+	_, err := NewRowConverter(
+		strings.NewReader("network,geoname_id\n"),
+		Options{CIDR: true, ExcludeSatelliteProvider: true},
+	)
+	assert.EqualError(t, err, `column "is_satellite_provider" not found in header`)
+}
@@ -0,0 +1,510 @@
+package convert
+
+import (
+	"flag"
+	"time"
+)
+
+// optionFlag is a command-line flag of the geoip2-csv-converter binary that
+// sets an Options field.
+type optionFlag struct {
+	name string
+	// value is the default value, a bool, int, int64, float64, or string.
+	value any
+	usage string
+	// field returns a pointer to the field of the same type as value.
+	field func(o *Options) any
+}
+
+// optionFlags are the flags that map directly to an Options field. The
+// flags that need more than a flag value, such as -format, -within, and
+// -locations-file, are defined by the binary itself.
+var optionFlags = []optionFlag{
+	{
+		"partition-by",
+		"",
+		"Write the records to a file per value of this output column, e.g., country_iso_code",
+		func(o *Options) any { return &o.PartitionBy },
+	},
+	{
+		"shards",
+		0,
+		"Stripe the records across this many files named by formatting -output-file, e.g., out-%d.csv",
+		func(o *Options) any { return &o.Shards },
+	},
+	{
+		"max-open-files",
+		64,
+		"The maximum number of files kept open with -partition-by or -shards",
+		func(o *Options) any { return &o.MaxOpenFiles },
+	},
+	{
+		"no-clobber",
+		false,
+		"Exit with an error rather than overwrite an existing output file",
+		func(o *Options) any { return &o.NoClobber },
+	},
+	{
+		"append",
+		false,
+		"Append the records to the output file rather than replace it, writing the header only if it is empty",
+		func(o *Options) any { return &o.Append },
+	},
+	{
+		"no-sync",
+		false,
+		"Do not sync the output files to disk, e.g., for faster test runs",
+		func(o *Options) any { return &o.NoSync },
+	},
+	{
+		"include-range",
+		false,
+		"Include the IP range of the network in string format",
+		func(o *Options) any { return &o.IPRange },
+	},
+	{
+		"include-integer-range",
+		false,
+		"Include the IP range of the network in integer format",
+		func(o *Options) any { return &o.IntRange },
+	},
+	{
+		"include-integer-cidr",
+		false,
+		"Include the network as the integer of its start address and its prefix length",
+		func(o *Options) any { return &o.IntegerCIDR },
+	},
+	{
+		"include-hex-range",
+		false,
+		"Include the IP range of the network in hexadecimal format",
+		func(o *Options) any { return &o.HexRange },
+	},
+	{
+		"range-as-host-cidr",
+		false,
+		"Include the IP range of the network as host networks, e.g., 1.0.0.0/32 and 1.0.0.255/32",
+		func(o *Options) any { return &o.HostCIDRRange },
+	},
+	{
+		"range-exclusive-end",
+		false,
+		"Include the IP range of the network with the first address after it as the end, e.g., 1.0.1.0 for 1.0.0.0/24",
+		func(o *Options) any { return &o.ExclusiveEndRange },
+	},
+	{
+		"range-as-start-count",
+		false,
+		"Include the IP range of the network as its start and number of addresses, e.g., 1.0.0.0 and 256",
+		func(o *Options) any { return &o.StartCountRange },
+	},
+	{"include-cidr", false, "Include the network in CIDR format", func(o *Options) any { return &o.CIDR }},
+	{
+		"integer-range-combined",
+		false,
+		"Include the IP range of the network in integer format as a single start-end column",
+		func(o *Options) any { return &o.IntRangeCombined },
+	},
+	{
+		"include-binary-range",
+		false,
+		"Include the IP range of the network in binary format",
+		func(o *Options) any { return &o.BinaryRange },
+	},
+	{
+		"include-base64-range",
+		false,
+		"Include the IP range of the network in base64 format",
+		func(o *Options) any { return &o.Base64Range },
+	},
+	{
+		"ipv6-expanded",
+		false,
+		"Use the fully expanded IPv6 form in the IP range",
+		func(o *Options) any { return &o.IPv6Expanded },
+	},
+	{
+		"ipv6-uppercase",
+		false,
+		"Use uppercase letters for IPv6 networks in the IP range and CIDR",
+		func(o *Options) any { return &o.IPv6Uppercase },
+	},
+	{
+		"ipv4-octets",
+		false,
+		"Include the octets of the start of IPv4 networks as separate columns",
+		func(o *Options) any { return &o.IPv4Octets },
+	},
+	{
+		"ipv4-octets-skip-ipv6",
+		false,
+		"Skip IPv6 networks with -ipv4-octets",
+		func(o *Options) any { return &o.IPv4OctetsSkipIPv6 },
+	},
+	{
+		"include-netmask",
+		false,
+		"Include the netmask and wildcard mask of IPv4 networks",
+		func(o *Options) any { return &o.Netmask },
+	},
+	{
+		"include-broadcast",
+		false,
+		"Include the broadcast address of IPv4 networks",
+		func(o *Options) any { return &o.Broadcast },
+	},
+	{
+		"ipv4-integer32",
+		false,
+		"Include the IP range of IPv4 networks as 32-bit integers",
+		func(o *Options) any { return &o.IPv4Integer32 },
+	},
+	{
+		"include-ipv4-key",
+		false,
+		"Include a single 64-bit key, start<<32 | prefix length, for IPv4 networks",
+		func(o *Options) any { return &o.IPv4Key },
+	},
+	{
+		"include-hash",
+		false,
+		"Include a short, stable hash of the network for detecting changes",
+		func(o *Options) any { return &o.Hash },
+	},
+	{
+		"include-classification",
+		false,
+		"Include the special-use class of the network, e.g., global, private, or loopback",
+		func(o *Options) any { return &o.Classification },
+	},
+	{
+		"include-index",
+		false,
+		"Include a row_index column with the position of each record in the block file",
+		func(o *Options) any { return &o.RowIndex },
+	},
+	{
+		"index-start",
+		0,
+		"The row_index of the first record with -include-index, e.g., 1",
+		func(o *Options) any { return &o.RowIndexStart },
+	},
+	{
+		"include-version",
+		false,
+		"Include the IP version of the network, 4 or 6",
+		func(o *Options) any { return &o.IPVersion },
+	},
+	{
+		"include-midpoint",
+		false,
+		"Include the address halfway between the start and last address of the network",
+		func(o *Options) any { return &o.Midpoint },
+	},
+	{
+		"include-ptr",
+		false,
+		"Include the reverse DNS names of the start and last address of the network",
+		func(o *Options) any { return &o.PTR },
+	},
+	{
+		"ipv4-signed",
+		false,
+		"Use signed 32-bit integers, e.g., -2147483648 for 128.0.0.0, with -ipv4-integer32",
+		func(o *Options) any { return &o.IPv4Signed },
+	},
+	{
+		"broadcast-ipv6",
+		false,
+		"Use the last address of IPv6 networks in the -include-broadcast column",
+		func(o *Options) any { return &o.BroadcastIPv6 },
+	},
+	{
+		"hex-uppercase",
+		false,
+		"Use uppercase letters in the hexadecimal range",
+		func(o *Options) any { return &o.HexUppercase },
+	},
+	{
+		"integer-range-by-family",
+		false,
+		"Write the -include-integer-range columns separately for IPv4 and IPv6, e.g., network_start_integer_v4",
+		func(o *Options) any { return &o.IntRangeByFamily },
+	},
+	{
+		"integer-base",
+		10,
+		"The base, from 2 to 36, of the integer range columns",
+		func(o *Options) any { return &o.IntegerBase },
+	},
+	{
+		"network-column",
+		0,
+		"The zero-based index of the column containing the network",
+		func(o *Options) any { return &o.NetworkColumn },
+	},
+	{
+		"network-column-name",
+		"",
+		"The name of the header column containing the network. Takes precedence over -network-column",
+		func(o *Options) any { return &o.NetworkColumnName },
+	},
+	{
+		"strict",
+		false,
+		"Exit with an error unless the network column of the input header is named network",
+		func(o *Options) any { return &o.StrictHeader },
+	},
+	{
+		"retain-network-column",
+		false,
+		"Keep the original network column in its position among the other columns",
+		func(o *Options) any { return &o.RetainNetworkColumn },
+	},
+	{
+		"null-value",
+		"",
+		`Replace the empty values of the columns passed through from the block file with this, e.g., \N`,
+		func(o *Options) any { return &o.NullValue },
+	},
+	{
+		"only-network",
+		false,
+		"Discard the columns of the block file, keeping only the network representations",
+		func(o *Options) any { return &o.OnlyNetwork },
+	},
+	{
+		"require-canonical",
+		false,
+		"Treat networks with host bits set, e.g., 1.2.3.5/24, as invalid",
+		func(o *Options) any { return &o.RequireCanonical },
+	},
+	{
+		"normalize",
+		false,
+		"Convert networks with host bits set as the canonical network",
+		func(o *Options) any { return &o.Normalize },
+	},
+	{"unmap", false, "Convert IPv4-mapped IPv6 networks to IPv4 networks", func(o *Options) any { return &o.Unmap }},
+	{
+		"asn",
+		false,
+		"Validate the autonomous_system_number column of an ASN CSV",
+		func(o *Options) any { return &o.ASN },
+	},
+	{
+		"exclude-reserved",
+		false,
+		"Exclude networks overlapping private, loopback, and other special-use networks",
+		func(o *Options) any { return &o.ExcludeReserved },
+	},
+	{
+		"exclude-anonymous-proxy",
+		false,
+		"Exclude networks with is_anonymous_proxy set to 1",
+		func(o *Options) any { return &o.ExcludeAnonymousProxy },
+	},
+	{
+		"exclude-satellite",
+		false,
+		"Exclude networks with is_satellite_provider set to 1",
+		func(o *Options) any { return &o.ExcludeSatelliteProvider },
+	},
+	{"dedup", false, "Skip records with a network that was already seen", func(o *Options) any { return &o.Dedup }},
+	{
+		"dedup-assume-sorted",
+		false,
+		"Skip records with the same network as the previous record. Uses less memory than -dedup",
+		func(o *Options) any { return &o.DedupAssumeSorted },
+	},
+	{
+		"sort",
+		false,
+		"Sort the output by network. The whole file is held in memory",
+		func(o *Options) any { return &o.Sort },
+	},
+	{
+		"sort-ipv6-first",
+		false,
+		"Sort IPv6 networks before IPv4 networks with -sort",
+		func(o *Options) any { return &o.SortIPv6First },
+	},
+	{
+		"max-buffered-rows",
+		0,
+		"Exit with an error if -sort, -dedup, or -check-overlaps would hold more than this many records in memory",
+		func(o *Options) any { return &o.MaxBufferedRows },
+	},
+	{
+		"allow-ragged-rows",
+		false,
+		"Allow records to have a different number of columns than the header",
+		func(o *Options) any { return &o.AllowRaggedRows },
+	},
+	{
+		"input-no-header",
+		false,
+		"Read the first row of the block file as a record rather than as the header",
+		func(o *Options) any { return &o.InputNoHeader },
+	},
+	{
+		"crlf",
+		false,
+		"End the lines of the output CSV with CRLF rather than LF",
+		func(o *Options) any { return &o.CRLF },
+	},
+	{
+		"no-trailing-newline",
+		false,
+		"Omit the line ending after the last line of the output",
+		func(o *Options) any { return &o.NoTrailingNewline },
+	},
+	{"no-header", false, "Do not write the header row to the output CSV", func(o *Options) any { return &o.NoHeader }},
+	{
+		"error-on-empty",
+		false,
+		"Exit with an error if no records would be written to the output, e.g., as the block file is empty or all were filtered out",
+		func(o *Options) any { return &o.ErrorOnEmptyOutput },
+	},
+	{"ipset-name", "geoip", "The set name used with -format ipset", func(o *Options) any { return &o.IPSetName }},
+	{
+		"iptables-chain",
+		"GEOBLOCK",
+		"The chain the rules are appended to with -format iptables",
+		func(o *Options) any { return &o.IPTablesChain },
+	},
+	{
+		"iptables-target",
+		"DROP",
+		"The target the rules jump to with -format iptables",
+		func(o *Options) any { return &o.IPTablesTarget },
+	},
+	{
+		"value-column",
+		"",
+		"The name of the column supplying the value with -format nginx-geo",
+		func(o *Options) any { return &o.ValueColumn },
+	},
+	{
+		"skip-empty-values",
+		false,
+		"Skip networks with an empty -value-column with -format nginx-geo",
+		func(o *Options) any { return &o.SkipEmptyValues },
+	},
+	{
+		"sample-every",
+		0,
+		"Only write every Nth record, starting with the first",
+		func(o *Options) any { return &o.SampleEvery },
+	},
+	{
+		"sample-rate",
+		float64(0),
+		"Only write each record with this probability, e.g., 0.01",
+		func(o *Options) any { return &o.SampleRate },
+	},
+	{
+		"seed",
+		int64(0),
+		"The random seed for -sample-rate. Defaults to a random seed",
+		func(o *Options) any { return &o.SampleSeed },
+	},
+	{"skip", 0, "Discard this many records before writing any", func(o *Options) any { return &o.Skip }},
+	{"workers", 1, "The number of goroutines used to convert the records", func(o *Options) any { return &o.Workers }},
+	{
+		"buffer-size",
+		0,
+		"The size in bytes of the input and output buffers. Defaults to 64 KiB",
+		func(o *Options) any { return &o.BufferSize },
+	},
+	{"limit", 0, "Stop after writing this many records. 0 means no limit", func(o *Options) any { return &o.Limit }},
+	{
+		"skip-invalid",
+		false,
+		"Skip records with a network that cannot be parsed",
+		func(o *Options) any { return &o.SkipInvalid },
+	},
+}
+
+// optionFlagAliases are alternative names of optionFlags, keyed by the
+// alias.
+var optionFlagAliases = map[string]string{
+	"fail-on-empty": "error-on-empty",
+}
+
+// RegisterFlags defines the flags of the geoip2-csv-converter binary that set
+// an Options field on `fs`, with the same names, defaults, and usage. After
+// `fs` has been parsed, OptionsFromFlags returns the Options they specify.
+// An alias, such as -fail-on-empty for -error-on-empty, sets the same value
+// as the flag it names. This allows programs embedding the conversion to
+// offer the same flags as the binary.
+func RegisterFlags(fs *flag.FlagSet) {
+	for _, f := range optionFlags {
+		switch value := f.value.(type) {
+		case bool:
+			fs.Bool(f.name, value, f.usage)
+		case int:
+			fs.Int(f.name, value, f.usage)
+		case int64:
+			fs.Int64(f.name, value, f.usage)
+		case float64:
+			fs.Float64(f.name, value, f.usage)
+		case string:
+			fs.String(f.name, value, f.usage)
+		}
+	}
+
+	for alias, name := range optionFlagAliases {
+		fs.Var(fs.Lookup(name).Value, alias, "An alias for -"+name)
+	}
+}
+
+// OptionsFromFlags returns the Options set by the flags defined with
+// RegisterFlags on `fs`. Fields whose flag is not defined on `fs`, or is
+// defined with a different type, have the flag's default value, except that
+// SampleSeed is random unless -seed was set. The Options not set by a flag,
+// such as Format and Within, are left as their zero values.
+func OptionsFromFlags(fs *flag.FlagSet) Options {
+	var opts Options
+	for _, f := range optionFlags {
+		value := f.value
+		if defined := fs.Lookup(f.name); defined != nil {
+			if getter, ok := defined.Value.(flag.Getter); ok {
+				value = getter.Get()
+			}
+		}
+
+		switch field := f.field(&opts).(type) {
+		case *bool:
+			*field = flagValue[bool](value, f.value)
+		case *int:
+			*field = flagValue[int](value, f.value)
+		case *int64:
+			*field = flagValue[int64](value, f.value)
+		case *float64:
+			*field = flagValue[float64](value, f.value)
+		case *string:
+			*field = flagValue[string](value, f.value)
+		}
+	}
+
+	seeded := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seeded = true
+		}
+	})
+	if !seeded {
+		opts.SampleSeed = time.Now().UnixNano()
+	}
+	return opts
+}
+
+// flagValue returns `value` if it is a T and `defaultValue` otherwise.
+func flagValue[T any](value, defaultValue any) T {
+	if v, ok := value.(T); ok {
+		return v
+	}
+	//nolint:forcetypeassert // The default value has the type of the field.
+	return defaultValue.(T)
+}
@@ -0,0 +1,83 @@
+package convert
+
+import (
+	"flag"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsFromFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterFlags(fs)
+
+	// The seed is random unless -seed is set.
+	opts := OptionsFromFlags(fs)
+	assert.NotZero(t, opts.SampleSeed)
+	opts.SampleSeed = 0
+	assert.Equal(
+		t,
+		Options{
+			IntegerBase:    10,
+			IPSetName:      "geoip",
+			IPTablesChain:  "GEOBLOCK",
+			IPTablesTarget: "DROP",
+			Workers:        1,
+			MaxOpenFiles:   64,
+		},
+		opts,
+		"defaults",
+	)
+
+	require.NoError(t, fs.Parse([]string{
+		"-include-cidr",
+		"-include-integer-range",
+		"-integer-base", "16",
+		"-sample-rate", "0.5",
+		"-seed", "42",
+		"-network-column-name", "cidr",
+	}))
+
+	assert.Equal(
+		t,
+		Options{
+			CIDR:              true,
+			IntRange:          true,
+			IntegerBase:       16,
+			IPSetName:         "geoip",
+			IPTablesChain:     "GEOBLOCK",
+			IPTablesTarget:    "DROP",
+			SampleRate:        0.5,
+			SampleSeed:        42,
+			NetworkColumnName: "cidr",
+			Workers:           1,
+			MaxOpenFiles:      64,
+		},
+		OptionsFromFlags(fs),
+	)
+}
+
+func TestOptionsFromFlagsAlias(t *testing.T) {
+	for _, name := range []string{"-error-on-empty", "-fail-on-empty"} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		RegisterFlags(fs)
+
+		require.NoError(t, fs.Parse([]string{name}))
+		assert.True(t, OptionsFromFlags(fs).ErrorOnEmptyOutput, name)
+	}
+}
+
+func TestOptionsFromFlagsNotRegistered(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	// A flag defined with a different type is ignored.
+	fs.String("workers", "many", "")
+	fs.Bool("include-cidr", true, "")
+
+	opts := OptionsFromFlags(fs)
+	assert.Equal(t, 1, opts.Workers)
+	assert.True(t, opts.CIDR)
+	assert.Equal(t, 64, opts.MaxOpenFiles)
+}
@@ -0,0 +1,316 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// OutputFormat specifies the format of the converted output.
+type OutputFormat int
+
+const (
+	// OutputFormatCSV writes the converted records as CSV.
+	OutputFormatCSV OutputFormat = iota
+	// OutputFormatIPSet writes an `add` command for each network that may be
+	// used with `ipset restore`, e.g., "add geoip 1.0.0.0/24". The set name is
+	// taken from Options.IPSetName. The other columns are ignored.
+	OutputFormatIPSet
+	// OutputFormatIPTables writes an iptables rule appended to
+	// Options.IPTablesChain for each network, e.g.,
+	// "-A GEOBLOCK -s 1.0.0.0/24 -j DROP". The jump target is taken from
+	// Options.IPTablesTarget. The other columns are ignored.
+	OutputFormatIPTables
+	// OutputFormatNginxGeo writes a line for each network that may be used
+	// in the body of an nginx `geo` block, e.g., "1.0.0.0/24 US;". The value
+	// is taken from the column named by Options.ValueColumn.
+	OutputFormatNginxGeo
+	// OutputFormatTSVRaw writes the converted records as tab-separated
+	// values without any quoting or escaping. It is faster than
+	// OutputFormatCSV and the output may be split on tabs, but it is an error
+	// for a field to contain a tab or a newline.
+	OutputFormatTSVRaw
+)
+
+// ParseOutputFormat parses the name of an OutputFormat: "csv", "ipset",
+// "iptables", "nginx-geo", or "tsv-raw".
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	switch name {
+	case "csv":
+		return OutputFormatCSV, nil
+	case "ipset":
+		return OutputFormatIPSet, nil
+	case "iptables":
+		return OutputFormatIPTables, nil
+	case "nginx-geo":
+		return OutputFormatNginxGeo, nil
+	case "tsv-raw":
+		return OutputFormatTSVRaw, nil
+	default:
+		return 0, fmt.Errorf("unknown output format %q", name)
+	}
+}
+
+// String returns the name of the format as used in error messages.
+func (f OutputFormat) String() string {
+	switch f {
+	case OutputFormatIPSet:
+		return "ipset"
+	case OutputFormatIPTables:
+		return "iptables"
+	case OutputFormatNginxGeo:
+		return "nginx-geo"
+	case OutputFormatTSVRaw:
+		return "tsv-raw"
+	default:
+		return "CSV"
+	}
+}
+
+// HasColumns returns true if the format writes the header and other columns
+// of the records. Formats without columns only use the network and, for
+// OutputFormatNginxGeo, the value column.
+func (f OutputFormat) HasColumns() bool {
+	return f == OutputFormatCSV || f == OutputFormatTSVRaw
+}
+
+// extension returns the file name extension used for the format, e.g., when
+// partitioning the output.
+func (f OutputFormat) extension() string {
+	switch f {
+	case OutputFormatCSV:
+		return ".csv"
+	case OutputFormatTSVRaw:
+		return ".tsv"
+	case OutputFormatNginxGeo:
+		return ".conf"
+	default:
+		return ".txt"
+	}
+}
+
+// recordWriter writes converted records in an OutputFormat.
+type recordWriter interface {
+	writeHeader(header []string) error
+	write(row convertedRow) error
+	flush() error
+}
+
+func newRecordWriter(output io.Writer, opts Options) recordWriter {
+	switch opts.Format {
+	case OutputFormatIPSet:
+		return &lineWriter{
+			writer: bufio.NewWriter(output),
+			format: func(row convertedRow) string {
+				return "add " + opts.IPSetName + " " + row.network.String()
+			},
+		}
+	case OutputFormatIPTables:
+		return &lineWriter{
+			writer: bufio.NewWriter(output),
+			format: func(row convertedRow) string {
+				return "-A " + opts.IPTablesChain + " -s " + row.network.String() + " -j " + opts.IPTablesTarget
+			},
+		}
+	case OutputFormatNginxGeo:
+		return &nginxGeoWriter{
+			writer:      bufio.NewWriter(output),
+			valueColumn: opts.ValueColumn,
+			skipEmpty:   opts.SkipEmptyValues,
+		}
+	case OutputFormatTSVRaw:
+		lineEnd := "\n"
+		if opts.CRLF {
+			lineEnd = "\r\n"
+		}
+		return &tsvRawWriter{writer: bufio.NewWriter(output), noHeader: opts.NoHeader, lineEnd: lineEnd}
+	default:
+		writer := csv.NewWriter(output)
+		writer.UseCRLF = opts.CRLF
+		return &csvRecordWriter{writer: writer, noHeader: opts.NoHeader}
+	}
+}
+
+type csvRecordWriter struct {
+	writer   *csv.Writer
+	noHeader bool
+}
+
+func (w *csvRecordWriter) writeHeader(header []string) error {
+	if w.noHeader {
+		return nil
+	}
+	return w.writer.Write(header)
+}
+
+func (w *csvRecordWriter) write(row convertedRow) error {
+	return w.writer.Write(row.record)
+}
+
+func (w *csvRecordWriter) flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// tsvRawWriter writes records as tab-separated values without quoting. A
+// field containing a tab or newline is an error.
+type tsvRawWriter struct {
+	writer   *bufio.Writer
+	noHeader bool
+	lineEnd  string
+}
+
+func (w *tsvRawWriter) writeHeader(header []string) error {
+	if w.noHeader {
+		return nil
+	}
+	return w.writeFields(header)
+}
+
+func (w *tsvRawWriter) write(row convertedRow) error {
+	return w.writeFields(row.record)
+}
+
+func (w *tsvRawWriter) writeFields(fields []string) error {
+	for i, field := range fields {
+		if strings.ContainsAny(field, "\t\r\n") {
+			return fmt.Errorf("field %q contains a tab or newline", field)
+		}
+		if i > 0 {
+			if err := w.writer.WriteByte('\t'); err != nil {
+				return err
+			}
+		}
+		if _, err := w.writer.WriteString(field); err != nil {
+			return err
+		}
+	}
+	_, err := w.writer.WriteString(w.lineEnd)
+	return err
+}
+
+func (w *tsvRawWriter) flush() error {
+	return w.writer.Flush()
+}
+
+// lineWriter writes a single line per network and no header.
+type lineWriter struct {
+	writer *bufio.Writer
+	format func(convertedRow) string
+}
+
+func (*lineWriter) writeHeader([]string) error {
+	return nil
+}
+
+func (w *lineWriter) write(row convertedRow) error {
+	_, err := w.writer.WriteString(w.format(row) + "\n")
+	return err
+}
+
+func (w *lineWriter) flush() error {
+	return w.writer.Flush()
+}
+
+// nginxGeoWriter writes the network and the value from the value column of
+// each record.
+type nginxGeoWriter struct {
+	writer      *bufio.Writer
+	valueColumn string
+	index       int
+	skipEmpty   bool
+}
+
+func (w *nginxGeoWriter) writeHeader(header []string) error {
+	w.index = columnIndex(header, w.valueColumn)
+	if w.index < 0 {
+		return fmt.Errorf("value column %q not found in header", w.valueColumn)
+	}
+	return nil
+}
+
+func (w *nginxGeoWriter) write(row convertedRow) error {
+	value := field(row.record, w.index)
+	if value == "" && w.skipEmpty {
+		return nil
+	}
+
+	_, err := w.writer.WriteString(row.network.String() + " " + nginxQuote(value) + ";\n")
+	return err
+}
+
+func (w *nginxGeoWriter) flush() error {
+	return w.writer.Flush()
+}
+
+// nginxQuote quotes `value` for use in an nginx configuration file if it is
+// empty or contains characters with a special meaning to nginx.
+func nginxQuote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\r\n;{}\"'\\$#") {
+		return value
+	}
+
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(value) + `"`
+}
+
+// trailingNewlineWriter writes to `writer` all but the line ending at the
+// end of the output. As it cannot know which write is the last one, it holds
+// back any line ending bytes at the end of each write until the next write
+// or finish.
+type trailingNewlineWriter struct {
+	writer  io.Writer
+	pending []byte
+}
+
+// maxLineEnding is the length of the longest line ending, "\r\n".
+const maxLineEnding = 2
+
+func (w *trailingNewlineWriter) Write(p []byte) (int, error) {
+	keep := len(p)
+	for keep > 0 && len(p)-keep < maxLineEnding && (p[keep-1] == '\n' || p[keep-1] == '\r') {
+		keep--
+	}
+
+	if keep == 0 {
+		// `p` only contains line ending bytes, which may complete a line
+		// ending started by the pending bytes.
+		w.pending = append(w.pending, p...)
+		if extra := len(w.pending) - maxLineEnding; extra > 0 {
+			if _, err := w.writer.Write(w.pending[:extra]); err != nil {
+				return 0, err
+			}
+			w.pending = append(w.pending[:0], w.pending[extra:]...)
+		}
+		return len(p), nil
+	}
+
+	if len(w.pending) > 0 {
+		if _, err := w.writer.Write(w.pending); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := w.writer.Write(p[:keep]); err != nil {
+		return 0, err
+	}
+	w.pending = append(w.pending[:0], p[keep:]...)
+	return len(p), nil
+}
+
+// finish writes the pending bytes other than the final line ending.
+func (w *trailingNewlineWriter) finish() error {
+	pending := w.pending
+	switch {
+	case bytes.HasSuffix(pending, []byte("\r\n")):
+		pending = pending[:len(pending)-2]
+	case bytes.HasSuffix(pending, []byte("\n")):
+		pending = pending[:len(pending)-1]
+	}
+	w.pending = nil
+
+	_, err := w.writer.Write(pending)
+	return err
+}
@@ -0,0 +1,274 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	for name, expected := range map[string]OutputFormat{
+		"csv":       OutputFormatCSV,
+		"ipset":     OutputFormatIPSet,
+		"iptables":  OutputFormatIPTables,
+		"nginx-geo": OutputFormatNginxGeo,
+		"tsv-raw":   OutputFormatTSVRaw,
+	} {
+		format, err := ParseOutputFormat(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, format)
+	}
+
+	_, err := ParseOutputFormat("json")
+	assert.EqualError(t, err, `unknown output format "json"`)
+}
+
+func TestOutputFormats(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,2077456
+::ffff:203.0.113.0/120,6252001
+2001:4220::/32,357994
+`
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{
+			name: "ipset",
+			opts: Options{Format: OutputFormatIPSet, IPSetName: "blocklist"},
+			expected: `add blocklist 1.0.0.0/24
+add blocklist ::ffff:203.0.113.0/120
+add blocklist 2001:4220::/32
+`,
+		},
+		{
+			name: "ipset unmapped",
+			opts: Options{Format: OutputFormatIPSet, IPSetName: "blocklist", Unmap: true},
+			expected: `add blocklist 1.0.0.0/24
+add blocklist 203.0.113.0/24
+add blocklist 2001:4220::/32
+`,
+		},
+		{
+			name: "iptables",
+			opts: Options{
+				Format:         OutputFormatIPTables,
+				IPTablesChain:  "GEOBLOCK",
+				IPTablesTarget: "REJECT",
+				Unmap:          true,
+			},
+			expected: `-A GEOBLOCK -s 1.0.0.0/24 -j REJECT
+-A GEOBLOCK -s 203.0.113.0/24 -j REJECT
+-A GEOBLOCK -s 2001:4220::/32 -j REJECT
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var outbuf bytes.Buffer
+			stats, err := ConvertWithOptions(strings.NewReader(input), &outbuf, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, outbuf.String())
+			assert.Equal(t, 3, stats.RecordsProcessed)
+		})
+	}
+}
+
+func TestCRLF(t *testing.T) {
+	var outbuf bytes.Buffer
+	_, err := ConvertWithOptions(strings.NewReader(ipv4BlocksInput), &outbuf, Options{CIDR: true, CRLF: true})
+	require.NoError(t, err)
+	assert.Equal(t, strings.ReplaceAll(ipv4BlocksInput, "\n", "\r\n"), outbuf.String())
+	assert.Contains(t, outbuf.String(), "\r\n")
+}
+
+func TestTSVRawFormat(t *testing.T) {
+	input := `network,geoname_id,name
+1.0.0.0/24,2077456,"Australia, Oceania"
+2001:4220::/32,357994,"say ""hi"""
+`
+
+	var outbuf bytes.Buffer
+	stats, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		Options{IPRange: true, Format: OutputFormatTSVRaw},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.RecordsProcessed)
+	assert.Equal(t, "network_start_ip\tnetwork_last_ip\tgeoname_id\tname\n"+
+		"1.0.0.0\t1.0.0.255\t2077456\tAustralia, Oceania\n"+
+		"2001:4220::\t2001:4220:ffff:ffff:ffff:ffff:ffff:ffff\t357994\tsay \"hi\"\n",
+		outbuf.String())
+
+	outbuf.Reset()
+	_, err = ConvertWithOptions(
+		strings.NewReader(ipv4BlocksInput),
+		&outbuf,
+		Options{CIDR: true, Format: OutputFormatTSVRaw, NoHeader: true, CRLF: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0.0/24\t2077456\r\n1.0.1.0/24\t1814991\r\n", outbuf.String())
+
+	_, err = ConvertWithOptions(
+		strings.NewReader("network,name\n1.0.0.0/24,\"a\tb\"\n"),
+		&outbuf,
+		Options{CIDR: true, Format: OutputFormatTSVRaw},
+	)
+	assert.EqualError(t, err, `writing tsv-raw on line 2: field "a\tb" contains a tab or newline`)
+}
+
+func TestNginxGeoFormat(t *testing.T) {
+	input := `network,geoname_id,country_iso_code,country_name
+1.0.0.0/24,2077456,AU,Australia
+2001:4220::/32,357994,EG,Egypt
+203.0.113.0/24,6252001,US,United States
+198.51.100.0/24,,,
+`
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{
+			name: "country code",
+			opts: Options{Format: OutputFormatNginxGeo, ValueColumn: "country_iso_code"},
+			expected: `1.0.0.0/24 AU;
+2001:4220::/32 EG;
+203.0.113.0/24 US;
+198.51.100.0/24 "";
+`,
+		},
+		{
+			name: "quoted and skip empty",
+			opts: Options{Format: OutputFormatNginxGeo, ValueColumn: "country_name", SkipEmptyValues: true},
+			expected: `1.0.0.0/24 Australia;
+2001:4220::/32 Egypt;
+203.0.113.0/24 "United States";
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var outbuf bytes.Buffer
+			_, err := ConvertWithOptions(strings.NewReader(input), &outbuf, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, outbuf.String())
+		})
+	}
+
+	_, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&bytes.Buffer{},
+		Options{Format: OutputFormatNginxGeo, ValueColumn: "missing"},
+	)
+	assert.EqualError(t, err, `writing nginx-geo header: value column "missing" not found in header`)
+}
+
+func TestNginxQuote(t *testing.T) {
+	for value, expected := range map[string]string{
+		"US":            "US",
+		"":              `""`,
+		"United States": `"United States"`,
+		`a"b\c`:         `"a\"b\\c"`,
+		"a;b":           `"a;b"`,
+	} {
+		assert.Equal(t, expected, nginxQuote(value), value)
+	}
+}
+
+func TestNoTrailingNewline(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "CSV",
+			input:    ipv4BlocksInput,
+			opts:     Options{CIDR: true},
+			expected: strings.TrimSuffix(ipv4BlocksInput, "\n"),
+		},
+		{
+			name:     "CRLF",
+			input:    ipv4BlocksInput,
+			opts:     Options{CIDR: true, CRLF: true},
+			expected: "network,geoname_id\r\n1.0.0.0/24,2077456\r\n1.0.1.0/24,1814991",
+		},
+		{
+			name:     "quoted newline",
+			input:    "network,name\n1.0.0.0/24,\"a\n\"\n",
+			opts:     Options{CIDR: true},
+			expected: "network,name\n1.0.0.0/24,\"a\n\"",
+		},
+		{
+			name:     "header only",
+			input:    "network,geoname_id\n",
+			opts:     Options{CIDR: true},
+			expected: "network,geoname_id",
+		},
+		{
+			name:     "no output",
+			input:    "network,geoname_id\n",
+			opts:     Options{CIDR: true, NoHeader: true},
+			expected: "",
+		},
+		{
+			name:     "ipset",
+			input:    ipv4BlocksInput,
+			opts:     Options{Format: OutputFormatIPSet, IPSetName: "geoip"},
+			expected: "add geoip 1.0.0.0/24\nadd geoip 1.0.1.0/24",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.NoTrailingNewline = true
+
+			var outbuf bytes.Buffer
+			_, err := ConvertWithOptions(strings.NewReader(test.input), &outbuf, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, outbuf.String())
+		})
+	}
+
+	var outbuf bytes.Buffer
+	_, err := ConvertWithOptions(
+		strings.NewReader(generatedInput(10000)),
+		&outbuf,
+		Options{CIDR: true, CRLF: true, NoTrailingNewline: true},
+	)
+	require.NoError(t, err)
+	// The header and 20,000 records, less the final line ending.
+	assert.Equal(t, 20000, strings.Count(outbuf.String(), "\r\n"))
+	assert.NotEqual(t, byte('\n'), outbuf.Bytes()[outbuf.Len()-1])
+	assert.NotContains(t, outbuf.String(), "\n\n")
+}
+
+func TestTrailingNewlineWriter(t *testing.T) {
+	for _, writes := range [][]string{
+		{"a\r\nb\r\n"},
+		{"a\r", "\nb\r", "\n"},
+		{"a", "\r", "\n", "b", "\r", "\n"},
+		{"a\r\n", "b", "\r\n", ""},
+	} {
+		var outbuf bytes.Buffer
+		w := &trailingNewlineWriter{writer: &outbuf}
+		for _, s := range writes {
+			n, err := w.Write([]byte(s))
+			require.NoError(t, err)
+			assert.Equal(t, len(s), n)
+		}
+		require.NoError(t, w.finish())
+		assert.Equal(t, "a\r\nb", outbuf.String(), writes)
+	}
+}
@@ -0,0 +1,226 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// validateHeader checks the input `header` against Options.ExpectHeader,
+// Options.RequireColumns, and Options.StrictHeader.
+func validateHeader(header []string, opts Options) error {
+	if opts.StrictHeader && opts.NetworkColumnName == "" {
+		column, err := findNetworkColumn(header, opts)
+		if err != nil {
+			return err
+		}
+		if name := header[column]; name != "network" {
+			return fmt.Errorf(
+				`header (%s) has %q rather than "network" as column %d; the input may not be a block file`,
+				strings.Join(header, ","),
+				name,
+				column+1,
+			)
+		}
+	}
+
+	if len(opts.ExpectHeader) > 0 && !slices.Equal(header, opts.ExpectHeader) {
+		return fmt.Errorf(
+			"header (%s) does not match the expected header (%s): %s",
+			strings.Join(header, ","),
+			strings.Join(opts.ExpectHeader, ","),
+			headerDiff(opts.ExpectHeader, header),
+		)
+	}
+
+	var missing []string
+	for _, column := range opts.RequireColumns {
+		if !slices.Contains(header, column) {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf(
+			"header (%s) is missing the required column(s): %s",
+			strings.Join(header, ","),
+			strings.Join(missing, ", "),
+		)
+	}
+	return nil
+}
+
+// networkColumnHint adds a hint to `err`, the error parsing the network of
+// the first record, if the network column is not named "network", as the
+// input is then likely not a block file, e.g., a Locations file. There is
+// no hint if the column was chosen with Options.NetworkColumnName.
+func (c *RowConverter) networkColumnHint(err error) error {
+	if c.networkName == "network" || c.opts.NetworkColumnName != "" {
+		return err
+	}
+	return fmt.Errorf(
+		`%w; the header has %q rather than "network" as column %d, so the input may not be a block file`,
+		err,
+		c.networkName,
+		c.networkColumn+1,
+	)
+}
+
+// headerDiff describes the differences between the `expected` and `actual`
+// headers.
+func headerDiff(expected, actual []string) string {
+	var missing, unexpected []string
+	for _, column := range expected {
+		if !slices.Contains(actual, column) {
+			missing = append(missing, column)
+		}
+	}
+	for _, column := range actual {
+		if !slices.Contains(expected, column) {
+			unexpected = append(unexpected, column)
+		}
+	}
+
+	var diffs []string
+	if len(missing) > 0 {
+		diffs = append(diffs, "missing "+strings.Join(missing, ", "))
+	}
+	if len(unexpected) > 0 {
+		diffs = append(diffs, "unexpected "+strings.Join(unexpected, ", "))
+	}
+	if len(diffs) == 0 {
+		for i := range expected {
+			if expected[i] != actual[i] {
+				return fmt.Sprintf("column %d is %q rather than %q", i+1, actual[i], expected[i])
+			}
+		}
+		return "the columns are in a different order"
+	}
+	return strings.Join(diffs, "; ")
+}
+
+// renameColumns returns `header` with the columns renamed as specified by
+// `renames`.
+func renameColumns(header []string, renames map[string]string) ([]string, error) {
+	var unknown []string
+	for old := range renames {
+		if !slices.Contains(header, old) {
+			unknown = append(unknown, old)
+		}
+	}
+	if len(unknown) > 0 {
+		slices.Sort(unknown)
+		return nil, fmt.Errorf(
+			"column(s) to rename not found in the output header (%s): %s",
+			strings.Join(header, ","),
+			strings.Join(unknown, ", "),
+		)
+	}
+
+	renamed := make([]string, len(header))
+	for i, column := range header {
+		if name, ok := renames[column]; ok {
+			renamed[i] = name
+		} else {
+			renamed[i] = column
+		}
+	}
+	return renamed, nil
+}
+
+// columnOrder returns the permutation of the columns of `header` that puts
+// the columns named in `order` first, in that order, followed by the other
+// columns in their original order. The i-th output column is column
+// permutation[i] of `header`.
+func columnOrder(header, order []string) ([]int, error) {
+	used := make([]bool, len(header))
+	permutation := make([]int, 0, len(header))
+	for _, name := range order {
+		column := columnIndex(header, name)
+		switch {
+		case column < 0:
+			return nil, fmt.Errorf(
+				"column %q to order not found in the output header (%s)",
+				name,
+				strings.Join(header, ","),
+			)
+		case used[column]:
+			return nil, fmt.Errorf("column %q is listed more than once in the column order", name)
+		}
+		used[column] = true
+		permutation = append(permutation, column)
+	}
+
+	for column := range header {
+		if !used[column] {
+			permutation = append(permutation, column)
+		}
+	}
+	return permutation, nil
+}
+
+// reorder returns `record` with its columns in the order given by
+// `permutation`. Columns beyond those of the header, which are only present
+// with Options.AllowRaggedRows, are kept at the end.
+func reorder(record []string, permutation []int) []string {
+	reordered := make([]string, len(permutation))
+	for i, column := range permutation {
+		reordered[i] = field(record, column)
+	}
+	if len(record) > len(permutation) {
+		reordered = append(reordered, record[len(permutation):]...)
+	}
+	return reordered
+}
+
+// headerlessReader returns a reader returning `first`, the first record of
+// an input without a header, followed by the records of `reader`, along with
+// the generated header for the input.
+func headerlessReader(
+	reader recordReader,
+	first []string,
+	opts Options,
+) (recordReader, []string, error) {
+	if opts.NetworkColumnName != "" {
+		return nil, nil, errors.New("NetworkColumnName may not be used with InputNoHeader")
+	}
+
+	if opts.NetworkColumn < 0 {
+		return nil, nil, fmt.Errorf("network column %d is out of range", opts.NetworkColumn)
+	}
+	if opts.NetworkColumn >= len(first) {
+		return nil, nil, errors.New("the first record has no network column")
+	}
+	_, err := ParseNetwork(first[opts.NetworkColumn])
+	if err != nil {
+		return nil, nil, fmt.Errorf(
+			"the network column of the first record (%s) is not a network; does the input have a header? %w",
+			first[opts.NetworkColumn],
+			err,
+		)
+	}
+
+	header := make([]string, len(first))
+	for i := range header {
+		header[i] = "col" + strconv.Itoa(i+1)
+	}
+	header[opts.NetworkColumn] = "network"
+
+	return &unreadReader{recordReader: reader, record: first}, header, nil
+}
+
+// unreadReader returns `record` before the records of the wrapped reader.
+type unreadReader struct {
+	recordReader
+	record []string
+}
+
+func (r *unreadReader) Read() ([]string, error) {
+	if r.record != nil {
+		record := r.record
+		r.record = nil
+		return record, nil
+	}
+	return r.recordReader.Read()
+}
@@ -0,0 +1,352 @@
+package convert
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected []string
+		err      string
+	}{
+		{
+			name:     "match",
+			expected: []string{"network", "geoname_id"},
+		},
+		{
+			name:     "missing and unexpected",
+			expected: []string{"network", "registered_country_geoname_id"},
+			err: "header (network,geoname_id) does not match the expected header " +
+				"(network,registered_country_geoname_id): " +
+				"missing registered_country_geoname_id; unexpected geoname_id",
+		},
+		{
+			name:     "missing",
+			expected: []string{"network", "geoname_id", "postal_code"},
+			err: "header (network,geoname_id) does not match the expected header " +
+				"(network,geoname_id,postal_code): missing postal_code",
+		},
+		{
+			name:     "order",
+			expected: []string{"geoname_id", "network"},
+			err: "header (network,geoname_id) does not match the expected header " +
+				`(geoname_id,network): column 1 is "network" rather than "geoname_id"`,
+		},
+		{
+			name:     "duplicate",
+			expected: []string{"network", "network"},
+			err: "header (network,geoname_id) does not match the expected header " +
+				`(network,network): unexpected geoname_id`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var output strings.Builder
+			_, err := ConvertWithOptions(
+				strings.NewReader(ipv4BlocksInput),
+				&output,
+				Options{CIDR: true, ExpectHeader: test.expected},
+			)
+			if test.err == "" {
+				require.NoError(t, err)
+				assert.Equal(t, ipv4BlocksInput, output.String())
+				return
+			}
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestRequireColumns(t *testing.T) {
+	var output strings.Builder
+	_, err := ConvertWithOptions(
+		strings.NewReader(ipv4BlocksInput),
+		&output,
+		Options{CIDR: true, RequireColumns: []string{"geoname_id", "network"}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, ipv4BlocksInput, output.String())
+
+	_, err = ConvertWithOptions(
+		strings.NewReader(ipv4BlocksInput),
+		&output,
+		Options{CIDR: true, RequireColumns: []string{"postal_code", "geoname_id", "latitude"}},
+	)
+	assert.EqualError(
+		t,
+		err,
+		"header (network,geoname_id) is missing the required column(s): postal_code, latitude",
+	)
+}
+
+func TestStrictHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  Options
+		err   string
+	}{
+		{
+			name:  "block file",
+			input: ipv4BlocksInput,
+		},
+		{
+			name:  "locations file",
+			input: "geoname_id,locale_code,continent_code\n2077456,en,OC\n",
+			err: `header (geoname_id,locale_code,continent_code) has "geoname_id" rather than "network" ` +
+				"as column 1; the input may not be a block file",
+		},
+		{
+			name:  "network column",
+			input: "geoname_id,network\n2077456,1.0.0.0/24\n",
+			opts:  Options{NetworkColumn: 1},
+		},
+		{
+			name:  "negative network column",
+			input: ipv4BlocksInput,
+			opts:  Options{NetworkColumn: -1},
+			err:   "network column -1 is out of range for a header with 2 columns",
+		},
+		{
+			name:  "network column name",
+			input: "geoname_id,cidr\n2077456,1.0.0.0/24\n",
+			opts:  Options{NetworkColumnName: "cidr"},
+		},
+		{
+			name:  "input without a header",
+			input: "1.0.0.0/24,2077456\n",
+			opts:  Options{InputNoHeader: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := test.opts
+			opts.CIDR = true
+			opts.StrictHeader = true
+
+			_, err := ConvertWithOptions(strings.NewReader(test.input), io.Discard, opts)
+			if test.err == "" {
+				require.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestNetworkColumnHint(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  Options
+		err   string
+	}{
+		{
+			name:  "locations file",
+			input: "country_name,geoname_id\nUnited States,6252001\n",
+			err: `parsing network on line 2 (United States): netip.ParsePrefix("United States"): no '/'; ` +
+				`the header has "country_name" rather than "network" as column 1, so the input may not be a block file`,
+		},
+		{
+			name:  "block file",
+			input: "network,geoname_id\nUnited States,6252001\n",
+			err:   `parsing network on line 2 (United States): netip.ParsePrefix("United States"): no '/'`,
+		},
+		{
+			name:  "not the first record",
+			input: "cidr,geoname_id\n1.0.0.0/24,2077456\nUnited States,6252001\n",
+			err:   `parsing network on line 3 (United States): netip.ParsePrefix("United States"): no '/'`,
+		},
+		{
+			name:  "network column name",
+			input: "country_name,geoname_id\nUnited States,6252001\n",
+			opts:  Options{NetworkColumnName: "country_name"},
+			err:   `parsing network on line 2 (United States): netip.ParsePrefix("United States"): no '/'`,
+		},
+		{
+			name:  "host bits set",
+			input: "cidr,geoname_id\n1.0.0.1/24,2077456\n",
+			opts:  Options{RequireCanonical: true},
+			err:   "parsing network on line 2 (1.0.0.1/24): host bits are set; the canonical form is 1.0.0.0/24",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := test.opts
+			opts.CIDR = true
+
+			_, err := ConvertWithOptions(strings.NewReader(test.input), io.Discard, opts)
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestRenameColumns(t *testing.T) {
+	var output strings.Builder
+	_, err := ConvertWithOptions(
+		strings.NewReader(ipv4BlocksInput),
+		&output,
+		Options{
+			IPRange: true,
+			RenameColumns: map[string]string{
+				"network_start_ip": "ip_lo",
+				"network_last_ip":  "ip_hi",
+				"geoname_id":       "location_id",
+			},
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, `ip_lo,ip_hi,location_id
+1.0.0.0,1.0.0.255,2077456
+1.0.1.0,1.0.1.255,1814991
+`, output.String())
+
+	_, err = ConvertWithOptions(
+		strings.NewReader(ipv4BlocksInput),
+		&output,
+		Options{
+			CIDR:          true,
+			RenameColumns: map[string]string{"network_start_ip": "ip_lo", "geoname": "id", "geoname_id": "id"},
+		},
+	)
+	assert.EqualError(
+		t,
+		err,
+		"column(s) to rename not found in the output header (network,geoname_id): geoname, network_start_ip",
+	)
+}
+
+func TestColumnOrder(t *testing.T) {
+	var output strings.Builder
+	_, err := ConvertWithOptions(
+		strings.NewReader(ipv4BlocksInput),
+		&output,
+		Options{
+			CIDR:          true,
+			IntRange:      true,
+			RenameColumns: map[string]string{"geoname_id": "location_id"},
+			ColumnOrder:   []string{"location_id", "network"},
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, `location_id,network,network_start_integer,network_last_integer
+2077456,1.0.0.0/24,16777216,16777471
+1814991,1.0.1.0/24,16777472,16777727
+`, output.String())
+
+	// Ragged rows keep their extra columns at the end.
+	output.Reset()
+	_, err = ConvertWithOptions(
+		strings.NewReader("network,a,b\n1.0.0.0/24,1,2,3\n1.0.1.0/24,1\n"),
+		&output,
+		Options{CIDR: true, AllowRaggedRows: true, ColumnOrder: []string{"b", "a"}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "b,a,network\n2,1,1.0.0.0/24,3\n,1,1.0.1.0/24\n", output.String())
+
+	for order, expected := range map[string]string{
+		"geoname":         `column "geoname" to order not found in the output header (network,geoname_id)`,
+		"network,network": `column "network" is listed more than once in the column order`,
+	} {
+		_, err = ConvertWithOptions(
+			strings.NewReader(ipv4BlocksInput),
+			&output,
+			Options{CIDR: true, ColumnOrder: strings.Split(order, ",")},
+		)
+		assert.EqualError(t, err, expected)
+	}
+}
+
+func TestNoHeader(t *testing.T) {
+	var output strings.Builder
+	_, err := ConvertWithOptions(
+		strings.NewReader(`geoname_id,network,is_anonymous_proxy
+2077456,1.0.0.0/24,0
+1814991,1.0.1.0/24,1
+`),
+		&output,
+		Options{
+			IntRange:              true,
+			NetworkColumnName:     "network",
+			ExcludeAnonymousProxy: true,
+			NoHeader:              true,
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "16777216,16777471,2077456,0\n", output.String())
+
+	output.Reset()
+	_, err = ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n"),
+		&output,
+		Options{CIDR: true, NoHeader: true},
+	)
+	require.NoError(t, err)
+	assert.Empty(t, output.String())
+}
+
+func TestInputNoHeader(t *testing.T) {
+	input := "1.0.0.0/24,2077456,AU\n1.0.1.0/24,1814991,CN\n"
+
+	var output strings.Builder
+	_, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&output,
+		Options{CIDR: true, InputNoHeader: true, RenameColumns: map[string]string{"col3": "country"}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "network,col2,country\n"+input, output.String())
+
+	output.Reset()
+	_, err = ConvertWithOptions(
+		strings.NewReader("AU,1.0.0.0/24\nCN,1.0.1.0/24\n"),
+		&output,
+		Options{IntRange: true, NetworkColumn: 1, InputNoHeader: true, NoHeader: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "16777216,16777471,AU\n16777472,16777727,CN\n", output.String())
+
+	output.Reset()
+	_, err = ConvertMultipleWithOptions(
+		[]io.Reader{strings.NewReader("1.0.0.0/24\n"), strings.NewReader("2001:4220::/32\n")},
+		&output,
+		Options{CIDR: true, InputNoHeader: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "network\n1.0.0.0/24\n2001:4220::/32\n", output.String())
+
+	_, err = ConvertWithOptions(
+		strings.NewReader(input),
+		&output,
+		Options{CIDR: true, NetworkColumn: -1, InputNoHeader: true},
+	)
+	assert.EqualError(t, err, "network column -1 is out of range")
+
+	_, err = ConvertWithOptions(
+		strings.NewReader("1.0.0.0/24\nbad\n"),
+		&output,
+		Options{CIDR: true, InputNoHeader: true},
+	)
+	assert.EqualError(t, err, `parsing network on line 2 (bad): netip.ParsePrefix("bad"): no '/'`)
+
+	_, err = ConvertWithOptions(
+		strings.NewReader(ipv4BlocksInput),
+		&output,
+		Options{CIDR: true, InputNoHeader: true},
+	)
+	assert.EqualError(
+		t,
+		err,
+		"the network column of the first record (network) is not a network; does the input have a header? "+
+			`netip.ParsePrefix("network"): no '/'`,
+	)
+}
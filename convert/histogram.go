@@ -0,0 +1,92 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// PrefixHistogram is the number of networks of an input with each prefix
+// length, e.g., to spot an unexpected number of host networks.
+type PrefixHistogram struct {
+	// IPv4 is the number of IPv4 networks, indexed by prefix length.
+	IPv4 [33]int
+	// IPv6 is the number of IPv6 networks, indexed by prefix length.
+	IPv6 [129]int
+}
+
+// CountPrefixLengths reads the MaxMind GeoIP2 or GeoLite2 CSV in the
+// `input` io.Reader and returns the number of networks with each prefix
+// length. The network representation options are ignored.
+func CountPrefixLengths(input io.Reader, opts Options) (PrefixHistogram, error) {
+	opts.Sort = false
+
+	makeHeader, makeLine := buildFuncs(Options{RowIndex: opts.RowIndex})
+	rows, err := newRowConverter(input, opts, makeHeader, makeLine)
+	if err != nil {
+		return PrefixHistogram{}, err
+	}
+
+	var h PrefixHistogram
+	for {
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return PrefixHistogram{}, err
+		}
+
+		if row.network.Addr().Is4() {
+			h.IPv4[row.network.Bits()]++
+		} else {
+			h.IPv6[row.network.Bits()]++
+		}
+	}
+	return h, nil
+}
+
+// Merge returns the histogram of the networks of both `h` and `other`,
+// e.g., to count the networks of several inputs.
+func (h PrefixHistogram) Merge(other PrefixHistogram) PrefixHistogram {
+	for bits, count := range other.IPv4 {
+		h.IPv4[bits] += count
+	}
+	for bits, count := range other.IPv6 {
+		h.IPv6[bits] += count
+	}
+	return h
+}
+
+// WriteText writes the histogram to `w` as human-readable text, listing the
+// prefix lengths with at least one network from the shortest to the
+// longest, e.g.:
+//
+//	IPv4 /8:  1
+//	IPv4 /24: 2
+//	IPv6 /48: 3
+func (h PrefixHistogram) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	for _, family := range []struct {
+		name   string
+		counts []int
+	}{
+		{"IPv4", h.IPv4[:]},
+		{"IPv6", h.IPv6[:]},
+	} {
+		for bits, count := range family.counts {
+			if count == 0 {
+				continue
+			}
+			_, err := fmt.Fprintf(tw, "%s /%d:\t%s\n", family.name, bits, strconv.Itoa(count))
+			if err != nil {
+				return fmt.Errorf("writing prefix histogram: %w", err)
+			}
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing prefix histogram: %w", err)
+	}
+	return nil
+}
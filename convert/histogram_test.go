@@ -0,0 +1,62 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountPrefixLengths(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,1
+1.0.1.0/24,2
+2.0.0.0/8,3
+8.8.8.8/32,4
+2001:db8::/48,5
+2001:db8:1::/48,6
+::ffff:9.9.9.0/120,7
+`
+
+	h, err := CountPrefixLengths(strings.NewReader(input), Options{})
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, h.IPv4[8])
+	assert.Equal(t, 2, h.IPv4[24])
+	assert.Equal(t, 1, h.IPv4[32])
+	assert.Equal(t, 2, h.IPv6[48])
+	assert.Equal(t, 1, h.IPv6[120])
+
+	var buf bytes.Buffer
+	require.NoError(t, h.WriteText(&buf))
+	assert.Equal(t, `IPv4 /8:   1
+IPv4 /24:  2
+IPv4 /32:  1
+IPv6 /48:  2
+IPv6 /120: 1
+`, buf.String())
+}
+
+func TestCountPrefixLengthsEmpty(t *testing.T) {
+	h, err := CountPrefixLengths(strings.NewReader("network,geoname_id\n"), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, PrefixHistogram{}, h)
+
+	var buf bytes.Buffer
+	require.NoError(t, h.WriteText(&buf))
+	assert.Empty(t, buf.String())
+}
+
+func TestPrefixHistogramMerge(t *testing.T) {
+	a, err := CountPrefixLengths(strings.NewReader("network\n1.0.0.0/24\n"), Options{})
+	require.NoError(t, err)
+	b, err := CountPrefixLengths(strings.NewReader("network\n1.0.1.0/24\n::/0\n"), Options{})
+	require.NoError(t, err)
+
+	merged := a.Merge(b)
+	assert.Equal(t, 2, merged.IPv4[24])
+	assert.Equal(t, 1, merged.IPv6[0])
+	assert.Equal(t, 1, a.IPv4[24])
+}
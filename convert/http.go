@@ -0,0 +1,111 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// queryOptions are the query parameters accepted by Handler and the Options
+// fields they set. The names are those of the corresponding command-line
+// flags.
+var queryOptions = []struct {
+	name  string
+	field func(*Options) *bool
+}{
+	{"include-cidr", func(o *Options) *bool { return &o.CIDR }},
+	{"include-range", func(o *Options) *bool { return &o.IPRange }},
+	{"include-integer-range", func(o *Options) *bool { return &o.IntRange }},
+	{"include-integer-cidr", func(o *Options) *bool { return &o.IntegerCIDR }},
+	{"include-hex-range", func(o *Options) *bool { return &o.HexRange }},
+	{"range-as-host-cidr", func(o *Options) *bool { return &o.HostCIDRRange }},
+	{"range-exclusive-end", func(o *Options) *bool { return &o.ExclusiveEndRange }},
+	{"range-as-start-count", func(o *Options) *bool { return &o.StartCountRange }},
+	{"integer-range-combined", func(o *Options) *bool { return &o.IntRangeCombined }},
+	{"include-binary-range", func(o *Options) *bool { return &o.BinaryRange }},
+	{"include-base64-range", func(o *Options) *bool { return &o.Base64Range }},
+	{"include-ipv4-key", func(o *Options) *bool { return &o.IPv4Key }},
+	{"integer-range-by-family", func(o *Options) *bool { return &o.IntRangeByFamily }},
+	{"ipv6-expanded", func(o *Options) *bool { return &o.IPv6Expanded }},
+	{"hex-uppercase", func(o *Options) *bool { return &o.HexUppercase }},
+	{"ipv6-uppercase", func(o *Options) *bool { return &o.IPv6Uppercase }},
+	{"unmap", func(o *Options) *bool { return &o.Unmap }},
+}
+
+// Handler returns an http.Handler that converts the CSV in the body of a
+// POST request and streams the converted output in the response. The
+// conversion uses `opts`, with the network representation options
+// overridden by any query parameters named after the corresponding flags,
+// e.g., `?include-cidr=true&include-range=1`.
+//
+// If the conversion fails before any output is written, the error is
+// returned with a 400 status. Otherwise, the connection is aborted so that
+// the client does not mistake the partial output for a complete response.
+func Handler(opts Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "only POST requests are supported", http.StatusMethodNotAllowed)
+			return
+		}
+
+		reqOpts, err := optionsFromQuery(opts, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		contentType := "text/csv; charset=utf-8"
+		switch {
+		case reqOpts.Format == OutputFormatTSVRaw:
+			contentType = "text/tab-separated-values; charset=utf-8"
+		case !reqOpts.Format.HasColumns():
+			contentType = "text/plain; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+
+		output := &responseWriter{ResponseWriter: w}
+		_, err = ConvertWithOptions(r.Body, output, reqOpts)
+		if err == nil {
+			return
+		}
+
+		if output.written {
+			panic(http.ErrAbortHandler)
+		}
+		w.Header().Del("Content-Type")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	})
+}
+
+// optionsFromQuery returns `opts` updated with the query parameters of `r`.
+func optionsFromQuery(opts Options, r *http.Request) (Options, error) {
+	query := r.URL.Query()
+	for _, o := range queryOptions {
+		if !query.Has(o.name) {
+			continue
+		}
+		v, err := strconv.ParseBool(query.Get(o.name))
+		if err != nil {
+			return opts, fmt.Errorf("invalid value for %s: %q", o.name, query.Get(o.name))
+		}
+		*o.field(&opts) = v
+	}
+
+	if !opts.HasRepresentation() && opts.Format.HasColumns() {
+		return opts, errors.New("at least one network representation, e.g., include-cidr, is required")
+	}
+	return opts, nil
+}
+
+// responseWriter records whether any output has been written.
+type responseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,106 @@
+package convert
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(Handler(Options{CIDR: true}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		query       string
+		input       string
+		status      int
+		contentType string
+		expected    string
+	}{
+		{
+			name:        "default options",
+			input:       ipv4BlocksInput,
+			status:      http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			expected:    "network,geoname_id\n1.0.0.0/24,2077456\n1.0.1.0/24,1814991\n",
+		},
+		{
+			name:        "query options",
+			query:       "?include-cidr=false&include-range=true&include-integer-range=1",
+			input:       ipv6BlocksInput,
+			status:      http.StatusOK,
+			contentType: "text/csv; charset=utf-8",
+			expected: "network_start_ip,network_last_ip,network_start_integer,network_last_integer,geoname_id\n" +
+				"2001:4220::,2001:4220:ffff:ffff:ffff:ffff:ffff:ffff," +
+				"42541829336310884227257139937291534336,42541829415539046741521477530835484671,357994\n",
+		},
+		{
+			name:     "invalid query",
+			query:    "?include-range=maybe",
+			input:    ipv4BlocksInput,
+			status:   http.StatusBadRequest,
+			expected: "invalid value for include-range: \"maybe\"\n",
+		},
+		{
+			name:     "no representation",
+			query:    "?include-cidr=false",
+			input:    ipv4BlocksInput,
+			status:   http.StatusBadRequest,
+			expected: "at least one network representation, e.g., include-cidr, is required\n",
+		},
+		{
+			name:     "invalid input",
+			input:    "network,geoname_id\nbad,1\n",
+			status:   http.StatusBadRequest,
+			expected: "parsing network on line 2 (bad): netip.ParsePrefix(\"bad\"): no '/'\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := http.Post(server.URL+test.query, "text/csv", strings.NewReader(test.input))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.status, resp.StatusCode)
+			assert.Equal(t, test.expected, string(body))
+			if test.contentType != "" {
+				assert.Equal(t, test.contentType, resp.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestHandlerMethod(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler(Options{CIDR: true}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, http.MethodPost, rec.Header().Get("Allow"))
+}
+
+func TestHandlerAbortsPartialOutput(t *testing.T) {
+	// The error occurs after the buffered output has been flushed to the
+	// client.
+	input := generatedInput(5000) + "bad,1\n"
+
+	server := httptest.NewServer(Handler(Options{CIDR: true, BufferSize: 4096}))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "text/csv", strings.NewReader(input))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	_, err = io.ReadAll(resp.Body)
+	assert.Error(t, err)
+}
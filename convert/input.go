@@ -0,0 +1,53 @@
+package convert
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OpenInput opens the input file `name` for reading. If `name` is an
+// http:// or https:// URL, the file is downloaded with a GET request and the
+// response body is returned. Redirects are followed and a gzip
+// Content-Encoding is decoded transparently. Any other response status than
+// 200 is an error. Names with the URL scheme of a Storage registered with
+// RegisterStorage, e.g., s3://bucket/key, are opened with that Storage.
+func OpenInput(name string) (io.ReadCloser, error) {
+	if isURL(name) {
+		return openURL(name)
+	}
+	if storage, ok := storageFor(name); ok {
+		r, err := storage.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("opening input file (%s): %w", name, err)
+		}
+		return r, nil
+	}
+
+	f, err := os.Open(filepath.Clean(name))
+	if err != nil {
+		return nil, fmt.Errorf("opening input file (%s): %w", name, err)
+	}
+	return f, nil
+}
+
+func isURL(name string) bool {
+	return strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://")
+}
+
+func openURL(url string) (io.ReadCloser, error) {
+	//nolint:gosec,noctx // The URL is provided by the user, and downloads may take arbitrarily long.
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("fetching input file: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching input file (%s): unexpected status %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
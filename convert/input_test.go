@@ -0,0 +1,53 @@
+package convert
+
+import (
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertFileWithOptionsURL(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/blocks.csv", func(w http.ResponseWriter, _ *http.Request) {
+		_, err := w.Write([]byte(ipv4BlocksInput))
+		assert.NoError(t, err)
+	})
+	mux.HandleFunc("/gzip.csv", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		_, err := gz.Write([]byte(ipv4BlocksInput))
+		assert.NoError(t, err)
+		assert.NoError(t, gz.Close())
+	})
+	mux.Handle("/redirect", http.RedirectHandler("/blocks.csv", http.StatusFound))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	outputFile := filepath.Join(t.TempDir(), "output.csv")
+
+	for _, path := range []string{"/blocks.csv", "/gzip.csv", "/redirect"} {
+		t.Run(path, func(t *testing.T) {
+			stats, err := ConvertFileWithOptions(server.URL+path, outputFile, Options{CIDR: true})
+			require.NoError(t, err)
+			assert.Equal(t, 2, stats.RecordsProcessed)
+
+			b, err := os.ReadFile(outputFile)
+			require.NoError(t, err)
+			assert.Equal(t, ipv4BlocksInput, string(b))
+		})
+	}
+
+	_, err := ConvertFileWithOptions(server.URL+"/missing.csv", outputFile, Options{CIDR: true})
+	assert.EqualError(
+		t,
+		err,
+		"fetching input file ("+server.URL+"/missing.csv): unexpected status 404 Not Found",
+	)
+}
@@ -0,0 +1,108 @@
+package convert
+
+import (
+	"net/netip"
+	"strconv"
+
+	"go4.org/netipx"
+)
+
+// This file contains the representations that only apply to IPv4 networks.
+// The columns for these representations are empty for IPv6 networks unless
+// otherwise noted.
+
+func ipv4OctetsHeader(orig []string) []string {
+	return append([]string{"octet1", "octet2", "octet3", "octet4"}, orig...)
+}
+
+func ipv4OctetsLine(network netip.Prefix, columns []string) {
+	if !network.Addr().Is4() {
+		return
+	}
+
+	octets := network.Addr().As4()
+	for i, octet := range octets {
+		columns[i] = strconv.Itoa(int(octet))
+	}
+}
+
+// ipv4OnlyFilter is a rowFilter that excludes records with IPv6 networks.
+func ipv4OnlyFilter(network netip.Prefix, _ []string) (bool, error) {
+	return network.Addr().Is4(), nil
+}
+
+func netmaskHeader(orig []string) []string {
+	return append([]string{"netmask", "wildcard_mask"}, orig...)
+}
+
+func netmaskLine(network netip.Prefix, columns []string) {
+	if !network.Addr().Is4() {
+		return
+	}
+
+	mask := uint32(0xffffffff) << (32 - network.Bits())
+	if network.Bits() == 0 {
+		mask = 0
+	}
+
+	columns[0] = uint32ToIPv4(mask).String()
+	columns[1] = uint32ToIPv4(^mask).String()
+}
+
+func uint32ToIPv4(v uint32) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+}
+
+func broadcastHeader(orig []string) []string {
+	return append([]string{"broadcast"}, orig...)
+}
+
+func broadcastLine(network netip.Prefix, columns []string) {
+	if !network.Addr().Is4() {
+		return
+	}
+	lastAddressLine(network, columns)
+}
+
+func lastAddressLine(network netip.Prefix, columns []string) {
+	columns[0] = netipx.PrefixLastIP(network).String()
+}
+
+func ipv4Integer32Header(orig []string) []string {
+	return append([]string{"network_start_ipv4_integer", "network_last_ipv4_integer"}, orig...)
+}
+
+func ipv4Integer32Line(network netip.Prefix, columns []string) {
+	if !network.Addr().Is4() {
+		return
+	}
+
+	columns[0] = strconv.FormatUint(uint64(ipv4ToUint32(network.Addr())), 10)
+	columns[1] = strconv.FormatUint(uint64(ipv4ToUint32(netipx.PrefixLastIP(network))), 10)
+}
+
+func ipv4SignedInteger32Line(network netip.Prefix, columns []string) {
+	if !network.Addr().Is4() {
+		return
+	}
+
+	columns[0] = strconv.FormatInt(int64(int32(ipv4ToUint32(network.Addr()))), 10)
+	columns[1] = strconv.FormatInt(int64(int32(ipv4ToUint32(netipx.PrefixLastIP(network)))), 10)
+}
+
+func ipv4KeyHeader(orig []string) []string {
+	return append([]string{"network_ipv4_key"}, orig...)
+}
+
+func ipv4KeyLine(network netip.Prefix, columns []string) {
+	if !network.Addr().Is4() {
+		return
+	}
+
+	columns[0] = strconv.FormatUint(uint64(ipv4ToUint32(network.Addr()))<<32|uint64(network.Bits()), 10)
+}
+
+func ipv4ToUint32(ip netip.Addr) uint32 {
+	b := ip.As4()
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
@@ -0,0 +1,211 @@
+package convert
+
+import (
+	"bytes"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const mixedFamilyInput = `network,geoname_id
+1.0.0.0/24,2077456
+2001:4220::/32,357994
+203.0.113.128/25,6252001
+`
+
+func TestIPv4Octets(t *testing.T) {
+	checkHeader(
+		t,
+		ipv4OctetsHeader,
+		[]string{"octet1", "octet2", "octet3", "octet4"},
+	)
+
+	checkLine(
+		t,
+		ipv4OctetsLine,
+		"203.0.113.128/25",
+		[]string{"203", "0", "113", "128"},
+	)
+
+	checkLine(
+		t,
+		ipv4OctetsLine,
+		"2001:4220::/32",
+		[]string{"", "", "", ""},
+	)
+}
+
+func TestIPv4OctetsOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{
+			name: "IPv6 empty",
+			opts: Options{CIDR: true, IPv4Octets: true},
+			expected: `network,octet1,octet2,octet3,octet4,geoname_id
+1.0.0.0/24,1,0,0,0,2077456
+2001:4220::/32,,,,,357994
+203.0.113.128/25,203,0,113,128,6252001
+`,
+		},
+		{
+			name: "IPv6 skipped",
+			opts: Options{IPv4Octets: true, IPv4OctetsSkipIPv6: true},
+			expected: `octet1,octet2,octet3,octet4,geoname_id
+1,0,0,0,2077456
+203,0,113,128,6252001
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var outbuf bytes.Buffer
+			_, err := ConvertWithOptions(strings.NewReader(mixedFamilyInput), &outbuf, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, outbuf.String())
+		})
+	}
+}
+
+func TestNetmask(t *testing.T) {
+	checkHeader(
+		t,
+		netmaskHeader,
+		[]string{"netmask", "wildcard_mask"},
+	)
+
+	tests := []struct {
+		network  string
+		expected []string
+	}{
+		{"0.0.0.0/0", []string{"0.0.0.0", "255.255.255.255"}},
+		{"10.0.0.0/8", []string{"255.0.0.0", "0.255.255.255"}},
+		{"1.0.0.0/24", []string{"255.255.255.0", "0.0.0.255"}},
+		{"203.0.113.128/25", []string{"255.255.255.128", "0.0.0.127"}},
+		{"203.0.113.1/32", []string{"255.255.255.255", "0.0.0.0"}},
+		{"2001:4220::/32", []string{"", ""}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.network, func(t *testing.T) {
+			checkLine(t, netmaskLine, test.network, test.expected)
+		})
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	checkHeader(
+		t,
+		broadcastHeader,
+		[]string{"broadcast"},
+	)
+
+	tests := []struct {
+		network  string
+		line     columnsFunc
+		expected []string
+	}{
+		{"1.0.0.0/24", broadcastLine, []string{"1.0.0.255"}},
+		{"203.0.113.2/31", broadcastLine, []string{"203.0.113.3"}},
+		{"203.0.113.1/32", broadcastLine, []string{"203.0.113.1"}},
+		{"2001:4220::/32", broadcastLine, []string{""}},
+		{"2001:4220::/32", lastAddressLine, []string{"2001:4220:ffff:ffff:ffff:ffff:ffff:ffff"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.network, func(t *testing.T) {
+			checkLine(t, test.line, test.network, test.expected)
+		})
+	}
+}
+
+func TestIPv4Integer32(t *testing.T) {
+	checkHeader(
+		t,
+		ipv4Integer32Header,
+		[]string{"network_start_ipv4_integer", "network_last_ipv4_integer"},
+	)
+
+	tests := []struct {
+		network  string
+		expected []string
+	}{
+		{"1.0.0.0/24", []string{"16777216", "16777471"}},
+		{"0.0.0.0/0", []string{"0", "4294967295"}},
+		{"255.255.255.255/32", []string{"4294967295", "4294967295"}},
+		{"2001:4220::/32", []string{"", ""}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.network, func(t *testing.T) {
+			checkLine(t, ipv4Integer32Line, test.network, test.expected)
+		})
+	}
+}
+
+func TestIPv4Key(t *testing.T) {
+	checkHeader(t, ipv4KeyHeader, []string{"network_ipv4_key"})
+
+	tests := []struct {
+		network  string
+		expected []string
+	}{
+		{"1.0.0.0/24", []string{"72057594037927960"}},
+		{"0.0.0.0/0", []string{"0"}},
+		{"0.0.0.0/8", []string{"8"}},
+		{"255.255.255.255/32", []string{"18446744069414584352"}},
+		{"2001:4220::/32", []string{""}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.network, func(t *testing.T) {
+			checkLine(t, ipv4KeyLine, test.network, test.expected)
+		})
+	}
+
+	// The keys sort like Sort, and the network can be recovered from them.
+	networks := []string{"1.0.0.0/16", "1.0.0.0/24", "1.0.1.0/24", "2.0.0.0/8"}
+	var previous uint64
+	for i, network := range networks {
+		reps := Representations(netip.MustParsePrefix(network), Options{IPv4Key: true})
+		key, err := strconv.ParseUint(reps["network_ipv4_key"], 10, 64)
+		require.NoError(t, err)
+		if i > 0 {
+			assert.Greater(t, key, previous)
+		}
+		previous = key
+
+		start := uint32(key >> 32)
+		addr := netip.AddrFrom4([4]byte{byte(start >> 24), byte(start >> 16), byte(start >> 8), byte(start)})
+		assert.Equal(t, network, netip.PrefixFrom(addr, int(key&0xff)).String())
+	}
+}
+
+func TestIPv4SignedInteger32(t *testing.T) {
+	tests := []struct {
+		network  string
+		expected []string
+	}{
+		{"1.0.0.0/24", []string{"16777216", "16777471"}},
+		{"127.255.255.0/24", []string{"2147483392", "2147483647"}},
+		{"127.255.255.255/32", []string{"2147483647", "2147483647"}},
+		{"128.0.0.0/32", []string{"-2147483648", "-2147483648"}},
+		{"128.0.0.0/1", []string{"-2147483648", "-1"}},
+		{"0.0.0.0/0", []string{"0", "-1"}},
+		{"255.255.255.255/32", []string{"-1", "-1"}},
+		{"2001:4220::/32", []string{"", ""}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.network, func(t *testing.T) {
+			checkLine(t, ipv4SignedInteger32Line, test.network, test.expected)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// locationHeader contains the names of the columns added when joining with
+// Locations.
+var locationHeader = []string{"country_iso_code", "country_name"}
+
+// Location contains the data from a MaxMind Locations CSV used to enrich
+// block records.
+type Location struct {
+	CountryISOCode string
+	CountryName    string
+}
+
+// Locations maps a geoname ID to its Location.
+type Locations map[string]Location
+
+// ReadLocationsFile reads the MaxMind GeoIP2 or GeoLite2 Locations CSV file
+// `locationsFile`. See ReadLocations.
+func ReadLocationsFile(locationsFile string) (Locations, error) {
+	f, err := os.Open(filepath.Clean(locationsFile))
+	if err != nil {
+		return nil, fmt.Errorf("opening locations file (%s): %w", locationsFile, err)
+	}
+
+	locations, err := ReadLocations(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("closing file (%s): %w", locationsFile, err)
+	}
+	return locations, nil
+}
+
+// ReadLocations reads a MaxMind GeoIP2 or GeoLite2 Locations CSV from
+// `input`. The CSV must have `geoname_id`, `country_iso_code`, and
+// `country_name` columns. A leading UTF-8 byte order mark is skipped.
+func ReadLocations(input io.Reader) (Locations, error) {
+	buffered := bufio.NewReader(input)
+	err := skipBOM(buffered)
+	if err != nil {
+		return nil, err
+	}
+	reader := csv.NewReader(buffered)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading locations CSV header: %w", err)
+	}
+
+	var columns [3]int
+	for i, name := range []string{"geoname_id", "country_iso_code", "country_name"} {
+		columns[i] = columnIndex(header, name)
+		if columns[i] < 0 {
+			return nil, fmt.Errorf("column %q not found in locations header", name)
+		}
+	}
+
+	locations := Locations{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return locations, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("reading locations CSV: %w", err)
+		}
+
+		locations[record[columns[0]]] = Location{
+			CountryISOCode: record[columns[1]],
+			CountryName:    record[columns[2]],
+		}
+	}
+}
+
+// lookup returns the values of the location columns for the geoname ID
+// `geonameID`. Empty values are returned if there is no such location.
+func (l Locations) lookup(geonameID string) []string {
+	loc := l[geonameID]
+	return []string{loc.CountryISOCode, loc.CountryName}
+}
@@ -0,0 +1,74 @@
+package convert
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:lll
+const locationsInput = `geoname_id,locale_code,continent_code,continent_name,country_iso_code,country_name,is_in_european_union
+2077456,en,OC,Oceania,AU,Australia,0
+6252001,en,NA,"North America",US,"United States",0
+`
+
+func TestReadLocations(t *testing.T) {
+	locations, err := ReadLocations(strings.NewReader(locationsInput))
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		Locations{
+			"2077456": {CountryISOCode: "AU", CountryName: "Australia"},
+			"6252001": {CountryISOCode: "US", CountryName: "United States"},
+		},
+		locations,
+	)
+
+	bomLocations, err := ReadLocations(strings.NewReader(utf8BOM + locationsInput))
+	require.NoError(t, err)
+	assert.Equal(t, locations, bomLocations)
+
+	_, err = ReadLocations(strings.NewReader("geoname_id,country_name\n"))
+	require.EqualError(t, err, `column "country_iso_code" not found in locations header`)
+}
+
+func TestLocationsJoin(t *testing.T) {
+	locations, err := ReadLocations(strings.NewReader(locationsInput))
+	require.NoError(t, err)
+
+	input := `network,geoname_id,registered_country_geoname_id
+1.0.0.0/24,2077456,2077456
+4.69.140.16/29,6252001,6252001
+5.61.192.0/21,,2635167
+2001:4220::/32,357994,357994
+`
+
+	var outbuf bytes.Buffer
+	_, err = ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		Options{CIDR: true, Locations: locations},
+	)
+	require.NoError(t, err)
+
+	expected := `network,geoname_id,registered_country_geoname_id,country_iso_code,country_name
+1.0.0.0/24,2077456,2077456,AU,Australia
+4.69.140.16/29,6252001,6252001,US,United States
+5.61.192.0/21,,2635167,,
+2001:4220::/32,357994,357994,,
+`
+	assert.Equal(t, expected, outbuf.String())
+}
+
+func TestLocationsJoinMissingColumn(t *testing.T) {
+	_, err := ConvertWithOptions(
+		strings.NewReader("network,autonomous_system_number\n"),
+		&bytes.Buffer{},
+		Options{CIDR: true, Locations: Locations{}},
+	)
+	require.EqualError(t, err, `column "geoname_id" not found in header`)
+}
@@ -0,0 +1,32 @@
+package convert
+
+import "time"
+
+// Logger receives progress messages about a conversion, such as the number
+// of records read and the time taken by each phase. It is implemented by
+// *log.Logger.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// logf logs a message to Options.Logger, if it is set.
+func (o Options) logf(format string, v ...any) {
+	if o.Logger != nil {
+		o.Logger.Printf(format, v...)
+	}
+}
+
+// logDone logs the statistics of the records written to `outputs` since the
+// RowConverter was created.
+func (c *RowConverter) logDone(outputs string) {
+	c.opts.logf(
+		"wrote %d record(s) (%d IPv4, %d IPv6) to %s in %s; %d filtered, %d skipped",
+		c.stats.RecordsProcessed,
+		c.stats.IPv4Count,
+		c.stats.IPv6Count,
+		outputs,
+		time.Since(c.started).Round(time.Millisecond),
+		c.stats.FilteredRecords,
+		c.stats.SkippedRecords,
+	)
+}
@@ -0,0 +1,67 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testLogger collects the logged messages.
+type testLogger struct {
+	messages []string
+}
+
+func (l *testLogger) Printf(format string, v ...any) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func TestLogger(t *testing.T) {
+	input := `network,geoname_id
+2001:db8::/32,3
+1.0.1.0/24,2
+1.0.0.0/24,1
+`
+
+	logger := &testLogger{}
+	var output bytes.Buffer
+	_, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&output,
+		Options{CIDR: true, Sort: true, Logger: logger},
+	)
+	require.NoError(t, err)
+
+	require.Len(t, logger.messages, 5)
+	assert.Equal(t, "started converting an input with 2 column(s)", logger.messages[0])
+	assert.Equal(t, "reading the records to sort", logger.messages[1])
+	assert.Regexp(t, `^read 3 record\(s\) in \S+$`, logger.messages[2])
+	assert.Regexp(t, `^sorted 3 record\(s\) in \S+$`, logger.messages[3])
+	assert.Regexp(
+		t,
+		`^wrote 3 record\(s\) \(2 IPv4, 1 IPv6\) to the output in \S+; 0 filtered, 0 skipped$`,
+		logger.messages[4],
+	)
+
+	// Nothing is logged, and nothing else changes, without a Logger.
+	var unlogged bytes.Buffer
+	_, err = ConvertWithOptions(strings.NewReader(input), &unlogged, Options{CIDR: true, Sort: true})
+	require.NoError(t, err)
+	assert.Equal(t, output.String(), unlogged.String())
+}
+
+func TestLoggerError(t *testing.T) {
+	logger := &testLogger{}
+	_, err := ConvertWithOptions(
+		strings.NewReader("network,geoname_id\nabc,1\n"),
+		&bytes.Buffer{},
+		Options{CIDR: true, Logger: logger},
+	)
+	require.Error(t, err)
+
+	// The end is not logged if the conversion fails.
+	assert.Equal(t, []string{"started converting an input with 2 column(s)"}, logger.messages)
+}
@@ -0,0 +1,212 @@
+package convert
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ConvertFilesWithOptions converts the MaxMind GeoIP2 or GeoLite2 CSV files
+// in `inputFiles`, in order, to a single `outputFile`, e.g., to convert the
+// IPv4 and IPv6 block files in one pass. The header is written once. Every
+// input must have the same header as the first one, and the header rows of
+// the later inputs are skipped. The inputs may be HTTP(S) URLs. See
+// OpenInput.
+func ConvertFilesWithOptions(
+	inputFiles []string,
+	outputFile string,
+	opts Options,
+) (Stats, error) {
+	if err := checkNotInput(outputFile, inputFiles); err != nil {
+		return Stats{}, err
+	}
+
+	return WriteOutputFile(outputFile, opts, func(output io.Writer) (Stats, error) {
+		return withInputFiles(inputFiles, func(inputs []io.Reader) (Stats, error) {
+			return convertMultiple(inputs, inputFiles, output, opts)
+		})
+	})
+}
+
+// withInputFiles opens `inputFiles` with OpenInput, calls `f` with them, and
+// closes them.
+func withInputFiles(
+	inputFiles []string,
+	f func(inputs []io.Reader) (Stats, error),
+) (Stats, error) {
+	var inputs []io.Reader
+	var files []io.ReadCloser
+	closeFiles := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+
+	for _, inputFile := range inputFiles {
+		file, err := OpenInput(inputFile)
+		if err != nil {
+			closeFiles()
+			return Stats{}, err
+		}
+		files = append(files, file)
+		inputs = append(inputs, file)
+	}
+
+	stats, err := f(inputs)
+	if err != nil {
+		closeFiles()
+		return stats, err
+	}
+	for i, file := range files {
+		if err := file.Close(); err != nil {
+			closeFiles()
+			return stats, fmt.Errorf("closing file (%s): %w", inputFiles[i], err)
+		}
+	}
+	return stats, nil
+}
+
+// ConvertMultipleWithOptions writes the MaxMind GeoIP2 or GeoLite2 CSVs in
+// `inputs`, in order, to `output` as a single CSV. See
+// ConvertFilesWithOptions.
+func ConvertMultipleWithOptions(
+	inputs []io.Reader,
+	output io.Writer,
+	opts Options,
+) (Stats, error) {
+	return convertMultiple(inputs, inputNames(len(inputs)), output, opts)
+}
+
+// inputNames returns the names used in error messages for `n` inputs that
+// have no file name.
+func inputNames(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = "input " + strconv.Itoa(i+1)
+	}
+	return names
+}
+
+func convertMultiple(
+	inputs []io.Reader,
+	names []string,
+	output io.Writer,
+	opts Options,
+) (Stats, error) {
+	return withMultiReader(inputs, names, opts, func(rows *RowConverter) (Stats, error) {
+		return writeRows(rows, output, opts)
+	})
+}
+
+// withMultiReader calls `f` with a RowConverter reading `inputs` in order.
+// Errors are prefixed with the name of the input being read.
+func withMultiReader(
+	inputs []io.Reader,
+	names []string,
+	opts Options,
+	f func(rows *RowConverter) (Stats, error),
+) (Stats, error) {
+	if len(inputs) == 0 {
+		return Stats{}, errors.New("no inputs to convert")
+	}
+
+	reader := &multiReader{inputs: inputs, names: names, opts: opts}
+
+	makeHeader, makeLine := buildFuncs(opts)
+	rows, err := newRowConverterWithReader(reader, opts, makeHeader, makeLine)
+	if err != nil {
+		return Stats{}, reader.wrap(err)
+	}
+
+	stats, err := f(rows)
+	if err != nil {
+		return stats, reader.wrap(err)
+	}
+	return stats, nil
+}
+
+// multiReader reads the CSV records of several inputs in order. The header
+// row of the first non-empty input is returned by the first call to Read,
+// and the header rows of the other inputs are checked against it and
+// skipped. Empty inputs are skipped. With Options.InputNoHeader, the first
+// row of each input is returned as a record.
+type multiReader struct {
+	inputs  []io.Reader
+	names   []string
+	opts    Options
+	current int
+	reader  *csv.Reader
+	header  []string
+}
+
+func (m *multiReader) Read() ([]string, error) {
+	for {
+		if m.reader == nil {
+			if m.current >= len(m.inputs) {
+				return nil, io.EOF
+			}
+
+			reader, err := newCSVReader(m.inputs[m.current], m.opts)
+			if err != nil {
+				return nil, err
+			}
+			m.reader = reader
+
+			header, err := m.reader.Read()
+			if errors.Is(err, io.EOF) {
+				m.next()
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("reading CSV header: %w", err)
+			}
+
+			if m.header == nil {
+				m.header = header
+				return header, nil
+			}
+			// Without headers, the first row is a record.
+			if m.opts.InputNoHeader {
+				return header, nil
+			}
+			if !slices.Equal(header, m.header) {
+				return nil, fmt.Errorf(
+					"header (%s) does not match the header of %s (%s)",
+					strings.Join(header, ","),
+					m.names[0],
+					strings.Join(m.header, ","),
+				)
+			}
+		}
+
+		record, err := m.reader.Read()
+		if errors.Is(err, io.EOF) {
+			m.next()
+			continue
+		}
+		return record, err
+	}
+}
+
+func (m *multiReader) FieldPos(field int) (line, column int) {
+	if m.reader == nil {
+		return 0, 0
+	}
+	return m.reader.FieldPos(field)
+}
+
+func (m *multiReader) next() {
+	m.reader = nil
+	m.current++
+}
+
+// wrap adds the name of the current input to `err`.
+func (m *multiReader) wrap(err error) error {
+	if m.current >= len(m.names) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", m.names[m.current], err)
+}
@@ -0,0 +1,125 @@
+package convert
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	ipv4BlocksInput = `network,geoname_id
+1.0.0.0/24,2077456
+1.0.1.0/24,1814991
+`
+	ipv6BlocksInput = `network,geoname_id
+2001:4220::/32,357994
+`
+)
+
+func TestConvertMultipleWithOptions(t *testing.T) {
+	var outbuf bytes.Buffer
+	stats, err := ConvertMultipleWithOptions(
+		[]io.Reader{
+			strings.NewReader(ipv4BlocksInput),
+			strings.NewReader(""),
+			strings.NewReader(ipv6BlocksInput),
+		},
+		&outbuf,
+		Options{CIDR: true, IPRange: true},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, `network,network_start_ip,network_last_ip,geoname_id
+1.0.0.0/24,1.0.0.0,1.0.0.255,2077456
+1.0.1.0/24,1.0.1.0,1.0.1.255,1814991
+2001:4220::/32,2001:4220::,2001:4220:ffff:ffff:ffff:ffff:ffff:ffff,357994
+`, outbuf.String())
+	assert.Equal(t, 3, stats.RecordsProcessed)
+	assert.Equal(t, 2, stats.IPv4Count)
+	assert.Equal(t, 1, stats.IPv6Count)
+}
+
+func TestConvertMultipleWithOptionsErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs []string
+		err    string
+	}{
+		{
+			name:   "header mismatch",
+			inputs: []string{ipv4BlocksInput, "network,geoname_id,is_anycast\n2001:4220::/32,357994,\n"},
+			err: "input 2: reading CSV: header (network,geoname_id,is_anycast) " +
+				"does not match the header of input 1 (network,geoname_id)",
+		},
+		{
+			name:   "invalid network",
+			inputs: []string{ipv4BlocksInput, "network,geoname_id\n2001:4220::/32,357994\nbad,1\n"},
+			err: "input 2: parsing network on line 3 (bad): " +
+				`netip.ParsePrefix("bad"): no '/'`,
+		},
+		{
+			name: "no inputs",
+			err:  "no inputs to convert",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var inputs []io.Reader
+			for _, input := range test.inputs {
+				inputs = append(inputs, strings.NewReader(input))
+			}
+
+			_, err := ConvertMultipleWithOptions(inputs, &bytes.Buffer{}, Options{CIDR: true})
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestConvertMultipleWithOptionsAllEmpty(t *testing.T) {
+	inputs := []io.Reader{strings.NewReader(""), strings.NewReader("")}
+
+	var outbuf bytes.Buffer
+	_, err := ConvertMultipleWithOptions(inputs, &outbuf, Options{CIDR: true})
+	require.NoError(t, err)
+	assert.Empty(t, outbuf.String())
+
+	inputs = []io.Reader{strings.NewReader(""), strings.NewReader("")}
+	_, err = ConvertMultipleWithOptions(inputs, &outbuf, Options{CIDR: true, ErrorOnEmpty: true})
+	assert.True(t, errors.Is(err, ErrEmptyInput))
+}
+
+func TestConvertFilesWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	ipv4File := filepath.Join(dir, "Blocks-IPv4.csv")
+	ipv6File := filepath.Join(dir, "Blocks-IPv6.csv")
+	outputFile := filepath.Join(dir, "output.csv")
+
+	require.NoError(t, os.WriteFile(ipv4File, []byte(ipv4BlocksInput), 0o600))
+	require.NoError(t, os.WriteFile(ipv6File, []byte(ipv6BlocksInput), 0o600))
+
+	_, err := ConvertFilesWithOptions([]string{ipv4File, ipv6File}, outputFile, Options{CIDR: true})
+	require.NoError(t, err)
+
+	output, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, `network,geoname_id
+1.0.0.0/24,2077456
+1.0.1.0/24,1814991
+2001:4220::/32,357994
+`, string(output))
+
+	_, err = ConvertFilesWithOptions(
+		[]string{ipv4File, filepath.Join(dir, "missing.csv")},
+		outputFile,
+		Options{CIDR: true},
+	)
+	assert.ErrorContains(t, err, "opening input file")
+}
@@ -0,0 +1,275 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// outputBufferSize is the number of records buffered for each output of
+// ConvertToOutputsWithOptions so that the outputs do not have to read in
+// lockstep.
+const outputBufferSize = 1024
+
+// errOutputFailed is returned to the other outputs of
+// ConvertToOutputsWithOptions when one of them fails.
+var errOutputFailed = errors.New("another output failed")
+
+// OutputSpec is one of the Options.Outputs written by
+// ConvertToOutputsWithOptions.
+type OutputSpec struct {
+	// Path is the output file. It is written as by WriteOutputFile, e.g.,
+	// to a temporary file that only replaces it once the conversion has
+	// succeeded.
+	Path string
+	// Options are the options of the output, such as its network
+	// representations. The options used to read the input, such as
+	// InputNoHeader, Comment, and AutoDecompress, are taken from the Options
+	// of the conversion instead, as is RejectOutput. Outputs must not be
+	// set.
+	Options Options
+}
+
+// ParseOutputSpec parses an output in the form used by the -emit flag of
+// the binary, "REPRESENTATIONS:PATH", e.g., "cidr,integer-range:blocks.csv".
+// REPRESENTATIONS is a comma-separated list of the names of the boolean
+// flags that set an Options field, with or without their "include-"
+// prefix, e.g., "cidr", "range", "hex-range", or "ipv6-expanded". The
+// Options of the output are `opts` with only the network representations
+// in the list selected.
+func ParseOutputSpec(spec string, opts Options) (OutputSpec, error) {
+	names, path, ok := strings.Cut(spec, ":")
+	if !ok || names == "" || path == "" {
+		return OutputSpec{}, fmt.Errorf("output %q is not of the form REPRESENTATIONS:PATH", spec)
+	}
+
+	opts.clearRepresentations()
+	opts.Outputs = nil
+	for _, name := range strings.Split(names, ",") {
+		field := boolOption(&opts, name)
+		if field == nil {
+			return OutputSpec{}, fmt.Errorf("unknown representation %q in output %q", name, spec)
+		}
+		*field = true
+	}
+	return OutputSpec{Path: path, Options: opts}, nil
+}
+
+// boolOption returns the field of `opts` set by the boolean flag named
+// `name` or "include-" followed by `name`, or nil if there is none.
+func boolOption(opts *Options, name string) *bool {
+	for _, f := range optionFlags {
+		if f.name != name && f.name != "include-"+name {
+			continue
+		}
+		if field, ok := f.field(opts).(*bool); ok {
+			return field
+		}
+	}
+	return nil
+}
+
+// ConvertToOutputsWithOptions converts the MaxMind GeoIP2 or GeoLite2 CSVs
+// in `inputs`, in order, to each of Options.Outputs, e.g., to write CIDR
+// and integer range files of the same block file. The inputs are only read
+// once. Each record is then converted separately for each output, which
+// holds its own records if it is sorted. If an output fails before the
+// input has been read, the others are abandoned rather than replacing their
+// files with outputs that stop early. The Stats are those of the first
+// output.
+func ConvertToOutputsWithOptions(inputs []io.Reader, opts Options) (Stats, error) {
+	return convertToOutputs(inputs, inputNames(len(inputs)), opts)
+}
+
+// ConvertFilesToOutputsWithOptions converts `inputFiles`, in order, as
+// ConvertToOutputsWithOptions does. The inputs may be HTTP(S) URLs. See
+// OpenInput.
+func ConvertFilesToOutputsWithOptions(inputFiles []string, opts Options) (Stats, error) {
+	for _, output := range opts.Outputs {
+		if err := checkNotInput(output.Path, inputFiles); err != nil {
+			return Stats{}, err
+		}
+	}
+	return withInputFiles(inputFiles, func(inputs []io.Reader) (Stats, error) {
+		return convertToOutputs(inputs, inputFiles, opts)
+	})
+}
+
+func convertToOutputs(inputs []io.Reader, names []string, opts Options) (Stats, error) {
+	if len(inputs) == 0 {
+		return Stats{}, errors.New("no inputs to convert")
+	}
+	if len(opts.Outputs) == 0 {
+		return Stats{}, errors.New("no outputs to write")
+	}
+
+	f := &fanOut{failed: make(chan struct{})}
+	stats := make([]Stats, len(opts.Outputs))
+	errs := make([]error, len(opts.Outputs))
+
+	var wg sync.WaitGroup
+	for i, output := range opts.Outputs {
+		outOpts := output.Options
+		outOpts.InputNoHeader = opts.InputNoHeader
+		outOpts.RejectOutput = nil
+		if i == 0 {
+			outOpts.RejectOutput = opts.RejectOutput
+		}
+
+		reader := &outputReader{
+			records: make(chan outputRecord, outputBufferSize),
+			done:    make(chan struct{}),
+		}
+		f.readers = append(f.readers, reader)
+
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			defer close(reader.done)
+
+			stats[i], errs[i] = writeOutput(path, outOpts, reader)
+			if errs[i] != nil {
+				f.fail()
+			}
+		}(i, output.Path)
+	}
+
+	f.run(&multiReader{inputs: inputs, names: names, opts: opts})
+	wg.Wait()
+
+	// The error of the output that failed first is more useful than the
+	// errors of the outputs abandoned because of it.
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, errOutputFailed) {
+			return stats[0], err
+		}
+	}
+	return stats[0], nil
+}
+
+// writeOutput writes the records returned by `reader` to `path`.
+func writeOutput(path string, opts Options, reader *outputReader) (Stats, error) {
+	stats, err := WriteOutputFile(path, opts, func(output io.Writer) (Stats, error) {
+		makeHeader, makeLine := buildFuncs(opts)
+		rows, err := newRowConverterWithReader(reader, opts, makeHeader, makeLine)
+		if err != nil {
+			return Stats{}, err
+		}
+		return writeRows(rows, output, opts)
+	})
+	if err != nil {
+		return stats, reader.wrap(err)
+	}
+	return stats, nil
+}
+
+// fanOut sends each record read from the input to the readers of the
+// outputs.
+type fanOut struct {
+	readers []*outputReader
+	// failed is closed once any output fails.
+	failed     chan struct{}
+	failedOnce sync.Once
+}
+
+func (f *fanOut) fail() {
+	f.failedOnce.Do(func() { close(f.failed) })
+}
+
+// run reads the records of `input` and sends them to the readers until the
+// end of the input, an error reading it, or an output failing.
+func (f *fanOut) run(input *multiReader) {
+	defer func() {
+		for _, r := range f.readers {
+			close(r.records)
+		}
+	}()
+
+	for {
+		record, err := input.Read()
+		if errors.Is(err, io.EOF) {
+			return
+		}
+
+		line, _ := input.FieldPos(0)
+		name := ""
+		if input.current < len(input.names) {
+			name = input.names[input.current]
+		}
+
+		select {
+		case <-f.failed:
+			f.send(outputRecord{err: errOutputFailed})
+			return
+		default:
+		}
+
+		reading := f.send(outputRecord{record: record, line: line, input: name, err: err})
+		if err != nil || !reading {
+			return
+		}
+	}
+}
+
+// send sends `record` to each reader whose output is still reading and
+// returns false if there are none, e.g., as they have all reached
+// Options.Limit. Each reader gets its own copy of the record as the
+// conversion may modify it.
+func (f *fanOut) send(record outputRecord) bool {
+	reading := false
+	for _, r := range f.readers {
+		rec := record
+		rec.record = slices.Clone(record.record)
+		select {
+		case r.records <- rec:
+			reading = true
+		case <-r.done:
+		}
+	}
+	return reading
+}
+
+// outputRecord is a record read from the input, or the error reading it.
+type outputRecord struct {
+	record []string
+	line   int
+	// input is the name of the input it was read from.
+	input string
+	err   error
+}
+
+// outputReader is the recordReader of one output of
+// ConvertToOutputsWithOptions. It returns the records sent by fanOut.
+type outputReader struct {
+	records chan outputRecord
+	// done is closed once the output has stopped reading.
+	done  chan struct{}
+	line  int
+	input string
+}
+
+func (r *outputReader) Read() ([]string, error) {
+	rec, ok := <-r.records
+	if !ok {
+		return nil, io.EOF
+	}
+	r.line = rec.line
+	r.input = rec.input
+	return rec.record, rec.err
+}
+
+func (r *outputReader) FieldPos(int) (line, column int) {
+	return r.line, 0
+}
+
+// wrap adds the name of the input of the last record read to `err`, as
+// multiReader.wrap does.
+func (r *outputReader) wrap(err error) error {
+	if r.input == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", r.input, err)
+}
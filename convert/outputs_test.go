@@ -0,0 +1,132 @@
+package convert
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutputSpec(t *testing.T) {
+	spec, err := ParseOutputSpec("cidr,integer-range,ipv6-expanded:s3://bucket/blocks.csv", Options{
+		IPRange: true,
+		Sort:    true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "s3://bucket/blocks.csv", spec.Path)
+	assert.Equal(t, Options{CIDR: true, IntRange: true, IPv6Expanded: true, Sort: true}, spec.Options)
+
+	for spec, expected := range map[string]string{
+		"blocks.csv":   `output "blocks.csv" is not of the form REPRESENTATIONS:PATH`,
+		"cidr:":        `output "cidr:" is not of the form REPRESENTATIONS:PATH`,
+		"cidr,:a.csv":  `unknown representation "" in output "cidr,:a.csv"`,
+		"bogus:a.csv":  `unknown representation "bogus" in output "bogus:a.csv"`,
+		"shards:a.csv": `unknown representation "shards" in output "shards:a.csv"`,
+	} {
+		_, err := ParseOutputSpec(spec, Options{})
+		assert.EqualError(t, err, expected, spec)
+	}
+}
+
+func TestConvertToOutputs(t *testing.T) {
+	dir := t.TempDir()
+	cidrFile := filepath.Join(dir, "cidr.csv")
+	rangeFile := filepath.Join(dir, "range.csv")
+
+	var rejects bytes.Buffer
+	stats, err := ConvertToOutputsWithOptions(
+		[]io.Reader{
+			strings.NewReader("network,geoname_id\n2001:db8::/32,2\nbad,3\n"),
+			strings.NewReader("network,geoname_id\n1.0.0.0/24,1\n"),
+		},
+		Options{
+			SkipInvalid:  true,
+			RejectOutput: &rejects,
+			Outputs: []OutputSpec{
+				{Path: cidrFile, Options: Options{CIDR: true, SkipInvalid: true, NoSync: true}},
+				{Path: rangeFile, Options: Options{IPRange: true, Sort: true, SkipInvalid: true, NoSync: true}},
+			},
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.RecordsProcessed)
+	assert.Equal(t, 1, stats.SkippedRecords)
+
+	b, err := os.ReadFile(cidrFile)
+	require.NoError(t, err)
+	assert.Equal(t, "network,geoname_id\n2001:db8::/32,2\n1.0.0.0/24,1\n", string(b))
+
+	b, err = os.ReadFile(rangeFile)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"network_start_ip,network_last_ip,geoname_id\n"+
+			"1.0.0.0,1.0.0.255,1\n"+
+			"2001:db8::,2001:db8:ffff:ffff:ffff:ffff:ffff:ffff,2\n",
+		string(b),
+	)
+
+	// The rejected records are only written once.
+	assert.Equal(t, "network,geoname_id\nbad,3\n", rejects.String())
+}
+
+func TestConvertToOutputsLimit(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "input.csv")
+	outputFile := filepath.Join(dir, "output.csv")
+	require.NoError(t, os.WriteFile(inputFile, []byte(generatedInput(5000)), 0o600))
+
+	// The input is read to the end though the output stops reading early.
+	_, err := ConvertFilesToOutputsWithOptions(
+		[]string{inputFile},
+		Options{Outputs: []OutputSpec{
+			{Path: outputFile, Options: Options{CIDR: true, Limit: 1, OnlyNetwork: true}},
+		}},
+	)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(b), "\n"))
+}
+
+func TestConvertToOutputsErrors(t *testing.T) {
+	dir := t.TempDir()
+	cidrFile := filepath.Join(dir, "cidr.csv")
+	geoFile := filepath.Join(dir, "geo.conf")
+	require.NoError(t, os.WriteFile(cidrFile, []byte("existing"), 0o600))
+
+	_, err := ConvertToOutputsWithOptions(
+		[]io.Reader{strings.NewReader(generatedInput(5000))},
+		Options{Outputs: []OutputSpec{
+			{Path: cidrFile, Options: Options{CIDR: true}},
+			{Path: geoFile, Options: Options{Format: OutputFormatNginxGeo, ValueColumn: "city"}},
+		}},
+	)
+	assert.EqualError(t, err, `input 1: writing nginx-geo header: value column "city" not found in header`)
+
+	// The other output is abandoned rather than replacing the existing file.
+	b, err := os.ReadFile(cidrFile)
+	require.NoError(t, err)
+	assert.Equal(t, "existing", string(b))
+	assert.NoFileExists(t, geoFile)
+
+	_, err = ConvertToOutputsWithOptions([]io.Reader{strings.NewReader("")}, Options{})
+	assert.EqualError(t, err, "no outputs to write")
+
+	_, err = ConvertWithOptions(
+		strings.NewReader(""),
+		io.Discard,
+		Options{Outputs: []OutputSpec{{Path: cidrFile}}},
+	)
+	assert.EqualError(
+		t,
+		err,
+		"Outputs may only be used with ConvertToOutputsWithOptions and ConvertFilesToOutputsWithOptions",
+	)
+}
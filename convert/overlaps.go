@@ -0,0 +1,82 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// Overlap describes an input network that overlaps an earlier, larger or
+// equal, network in the input.
+type Overlap struct {
+	// Network is the overlapping network.
+	Network netip.Prefix
+	// Line is the line number of the record containing Network.
+	Line int
+	// Container is the network that contains Network.
+	Container netip.Prefix
+	// ContainerLine is the line number of the record containing Container.
+	ContainerLine int
+}
+
+func (o Overlap) String() string {
+	return fmt.Sprintf(
+		"%s on line %d overlaps %s on line %d",
+		o.Network,
+		o.Line,
+		o.Container,
+		o.ContainerLine,
+	)
+}
+
+// CheckOverlaps reads the MaxMind GeoIP2 or GeoLite2 CSV in the `input`
+// io.Reader and returns the networks that overlap another network in the
+// input. As two networks overlap only if one contains the other, each
+// overlapping network is reported along with the widest network containing
+// it. The networks, but not the other columns, are held in memory, up to
+// Options.MaxBufferedRows. The network representation options are ignored.
+func CheckOverlaps(input io.Reader, opts Options) ([]Overlap, error) {
+	opts.Sort = false
+
+	makeHeader, makeLine := buildFuncs(Options{RowIndex: opts.RowIndex})
+	rows, err := newRowConverter(input, opts, makeHeader, makeLine)
+	if err != nil {
+		return nil, err
+	}
+
+	var networks []convertedRow
+	for {
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if opts.MaxBufferedRows > 0 && len(networks) == opts.MaxBufferedRows {
+			return nil, bufferLimitError("checking for overlaps in", opts.MaxBufferedRows)
+		}
+		networks = append(networks, convertedRow{network: row.network.Masked(), line: row.line})
+	}
+
+	// After sorting, a network overlaps an earlier one if and only if it is
+	// contained by the widest network seen since the last gap.
+	sortRows(networks, false)
+
+	var overlaps []Overlap
+	var container convertedRow
+	for i, row := range networks {
+		if i > 0 && container.network.Overlaps(row.network) {
+			overlaps = append(overlaps, Overlap{
+				Network:       row.network,
+				Line:          row.line,
+				Container:     container.network,
+				ContainerLine: container.line,
+			})
+			continue
+		}
+		container = row
+	}
+
+	return overlaps, nil
+}
@@ -0,0 +1,80 @@
+package convert
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckOverlaps(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,1
+2001:4220::/32,2
+1.0.0.128/25,3
+1.0.1.0/24,4
+1.0.0.0/16,5
+2001:4220::/32,6
+`
+
+	overlaps, err := CheckOverlaps(strings.NewReader(input), Options{})
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		[]Overlap{
+			{
+				Network:       netip.MustParsePrefix("1.0.0.0/24"),
+				Line:          2,
+				Container:     netip.MustParsePrefix("1.0.0.0/16"),
+				ContainerLine: 6,
+			},
+			{
+				Network:       netip.MustParsePrefix("1.0.0.128/25"),
+				Line:          4,
+				Container:     netip.MustParsePrefix("1.0.0.0/16"),
+				ContainerLine: 6,
+			},
+			{
+				Network:       netip.MustParsePrefix("1.0.1.0/24"),
+				Line:          5,
+				Container:     netip.MustParsePrefix("1.0.0.0/16"),
+				ContainerLine: 6,
+			},
+			{
+				Network:       netip.MustParsePrefix("2001:4220::/32"),
+				Line:          7,
+				Container:     netip.MustParsePrefix("2001:4220::/32"),
+				ContainerLine: 3,
+			},
+		},
+		overlaps,
+	)
+
+	assert.Equal(t, "1.0.0.0/24 on line 2 overlaps 1.0.0.0/16 on line 6", overlaps[0].String())
+}
+
+func TestCheckOverlapsMaxBufferedRows(t *testing.T) {
+	input := "network,geoname_id\n1.0.0.0/24,1\n1.0.0.0/16,2\n"
+
+	overlaps, err := CheckOverlaps(strings.NewReader(input), Options{MaxBufferedRows: 2})
+	require.NoError(t, err)
+	assert.Len(t, overlaps, 1)
+
+	_, err = CheckOverlaps(strings.NewReader(input), Options{MaxBufferedRows: 1})
+	require.ErrorIs(t, err, ErrBufferLimit)
+}
+
+func TestCheckOverlapsNone(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,1
+1.0.1.0/24,2
+::/0,3
+`
+
+	overlaps, err := CheckOverlaps(strings.NewReader(input), Options{})
+	require.NoError(t, err)
+	assert.Empty(t, overlaps)
+}
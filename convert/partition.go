@@ -0,0 +1,433 @@
+package convert
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxOpenFiles is the number of partition files kept open when
+// Options.MaxOpenFiles is not set.
+const defaultMaxOpenFiles = 64
+
+// emptyPartition is the name of the partition of records with an empty
+// Options.PartitionBy column.
+const emptyPartition = "_empty"
+
+// ConvertPartitionedWithOptions converts the MaxMind GeoIP2 or GeoLite2 CSVs
+// in `inputs`, in order, into one file per value of the output column named
+// by Options.PartitionBy, e.g., "US.csv" and "DE.csv" for
+// "country_iso_code". The files are written to `outputDir`, which is created
+// if needed. Each file starts with the header unless Options.NoHeader is set.
+// Records with an empty value are written to "_empty.csv", so "_empty" may
+// not be used as a value. The extension is that of Options.Format. Existing
+// files for the values seen are replaced, but other files in `outputDir` are
+// left as they are.
+//
+// As with WriteOutputFile, each file is written to a temporary file in
+// `outputDir`. The temporary files are only renamed once every file has been
+// written, and are removed if the conversion fails.
+//
+// At most Options.MaxOpenFiles files are kept open at once. When another
+// file is needed, the least recently used one is closed and later reopened
+// for appending if needed. Options.NoTrailingNewline may not be used.
+func ConvertPartitionedWithOptions(
+	inputs []io.Reader,
+	outputDir string,
+	opts Options,
+) (Stats, error) {
+	return withMultiReader(inputs, inputNames(len(inputs)), opts, func(rows *RowConverter) (Stats, error) {
+		return writePartitions(rows, outputDir, opts)
+	})
+}
+
+// ConvertFilesPartitionedWithOptions converts `inputFiles`, in order, as
+// ConvertPartitionedWithOptions does. The inputs may be HTTP(S) URLs. See
+// OpenInput.
+func ConvertFilesPartitionedWithOptions(
+	inputFiles []string,
+	outputDir string,
+	opts Options,
+) (Stats, error) {
+	return withInputFiles(inputFiles, func(inputs []io.Reader) (Stats, error) {
+		return withMultiReader(inputs, inputFiles, opts, func(rows *RowConverter) (Stats, error) {
+			return writePartitions(rows, outputDir, opts)
+		})
+	})
+}
+
+// ConvertShardedWithOptions converts the MaxMind GeoIP2 or GeoLite2 CSVs in
+// `inputs`, in order, into Options.Shards files. The files are named by
+// formatting `outputTemplate` with the shard number, starting at 0, e.g.,
+// "out-%d.csv" or "out-%03d.csv". Each file starts with the header unless
+// Options.NoHeader is set.
+//
+// The records are striped across the shards: the first record is written to
+// shard 0, the second to shard 1, and so on, wrapping around after the last
+// shard. The output is therefore deterministic and the shards differ in size
+// by at most one record. Every shard is created, even if it has no records.
+// As with ConvertPartitionedWithOptions, the files are written to temporary
+// files that are renamed once every shard has been written, at most
+// Options.MaxOpenFiles files are kept open at once, and
+// Options.NoTrailingNewline may not be used.
+func ConvertShardedWithOptions(
+	inputs []io.Reader,
+	outputTemplate string,
+	opts Options,
+) (Stats, error) {
+	return withMultiReader(inputs, inputNames(len(inputs)), opts, func(rows *RowConverter) (Stats, error) {
+		return writeShards(rows, outputTemplate, opts)
+	})
+}
+
+// ConvertFilesShardedWithOptions converts `inputFiles`, in order, as
+// ConvertShardedWithOptions does. The inputs may be HTTP(S) URLs. See
+// OpenInput.
+func ConvertFilesShardedWithOptions(
+	inputFiles []string,
+	outputTemplate string,
+	opts Options,
+) (Stats, error) {
+	return withInputFiles(inputFiles, func(inputs []io.Reader) (Stats, error) {
+		return withMultiReader(inputs, inputFiles, opts, func(rows *RowConverter) (Stats, error) {
+			return writeShards(rows, outputTemplate, opts)
+		})
+	})
+}
+
+// ShardPath returns the path of shard `shard` for `outputTemplate`. It
+// returns an error if the template does not have exactly one integer verb,
+// such as %d, that varies with the shard number.
+func ShardPath(outputTemplate string, shard int) (string, error) {
+	path := fmt.Sprintf(outputTemplate, shard)
+	if strings.Contains(path, "%!") || path == fmt.Sprintf(outputTemplate, shard+1) {
+		return "", fmt.Errorf("output template %q must have a single %%d verb for the shard number", outputTemplate)
+	}
+	return path, nil
+}
+
+func writePartitions(rows *RowConverter, outputDir string, opts Options) (Stats, error) {
+	if opts.PartitionBy == "" {
+		return rows.stats, errors.New("PartitionBy must be set to partition the output")
+	}
+	if opts.NoTrailingNewline {
+		return rows.stats, errors.New("NoTrailingNewline may not be used when partitioning the output")
+	}
+	if rows.empty {
+		return rows.stats, nil
+	}
+
+	column := columnIndex(rows.header, opts.PartitionBy)
+	if column < 0 {
+		return rows.stats, fmt.Errorf("partition column %q not found in header", opts.PartitionBy)
+	}
+
+	//nolint:gosec // The output directory should be readable by others, as created files are.
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return rows.stats, fmt.Errorf("creating output directory (%s): %w", outputDir, err)
+	}
+
+	p := newPartitions(rows.header, opts)
+	return rows.stats, p.writeRows(rows, func(row convertedRow) (*partitionFile, error) {
+		value := field(row.record, column)
+		name := value
+		if name == "" {
+			name = emptyPartition
+		}
+		_, ok := p.files[name]
+		if value == emptyPartition || !ok && !isPartitionName(name) {
+			return nil, fmt.Errorf(
+				"%s value %q on line %d may not be used as a file name",
+				opts.PartitionBy,
+				value,
+				rows.line,
+			)
+		}
+		return p.file(name, filepath.Join(outputDir, name+opts.Format.extension()))
+	})
+}
+
+func writeShards(rows *RowConverter, outputTemplate string, opts Options) (Stats, error) {
+	if opts.Shards < 1 {
+		return rows.stats, errors.New("at least one shard is required to shard the output")
+	}
+	if opts.NoTrailingNewline {
+		return rows.stats, errors.New("NoTrailingNewline may not be used when sharding the output")
+	}
+
+	paths := make([]string, opts.Shards)
+	for i := range paths {
+		path, err := ShardPath(outputTemplate, i)
+		if err != nil {
+			return rows.stats, err
+		}
+		paths[i] = path
+	}
+
+	// Completely empty input has no header, so its shards are empty.
+	if rows.empty {
+		opts.NoHeader = true
+	}
+	p := newPartitions(rows.header, opts)
+
+	// Every shard is created up front so that shards without records exist
+	// and have a header.
+	for i, path := range paths {
+		if _, err := p.file(strconv.Itoa(i), path); err != nil {
+			p.abort()
+			return rows.stats, err
+		}
+	}
+
+	next := 0
+	return rows.stats, p.writeRows(rows, func(convertedRow) (*partitionFile, error) {
+		shard := next
+		next = (next + 1) % len(paths)
+		return p.file(strconv.Itoa(shard), paths[shard])
+	})
+}
+
+// partitions are the output files of a partitioned or sharded conversion.
+type partitions struct {
+	header []string
+	opts   Options
+	// files are the partition files by name.
+	files map[string]*partitionFile
+	open  int
+	uses  int
+	// syncFile syncs a file before it is closed. It is replaced in tests.
+	syncFile func(*os.File) error
+}
+
+func newPartitions(header []string, opts Options) *partitions {
+	return &partitions{
+		header:   header,
+		opts:     opts,
+		files:    map[string]*partitionFile{},
+		syncFile: (*os.File).Sync,
+	}
+}
+
+// writeRows writes each row of `rows` to the file returned by `fileFor` and
+// closes the files.
+func (p *partitions) writeRows(
+	rows *RowConverter,
+	fileFor func(convertedRow) (*partitionFile, error),
+) error {
+	for {
+		row, err := rows.nextRow()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			p.abort()
+			return err
+		}
+
+		f, err := fileFor(row)
+		if err != nil {
+			p.abort()
+			return err
+		}
+
+		err = f.writer.write(row)
+		if err != nil {
+			p.abort()
+			return fmt.Errorf("writing %s to %s on line %d: %w", p.opts.Format, f.path, rows.line, err)
+		}
+	}
+
+	if err := p.close(); err != nil {
+		return err
+	}
+	rows.logDone(strconv.Itoa(len(p.files)) + " file(s)")
+	return nil
+}
+
+// partitionFile is the output file for one value of the partition column.
+// It is written to `temp`, which is renamed to `path` once every file has
+// been written. `file` is nil while the file is closed.
+type partitionFile struct {
+	path     string
+	temp     string
+	file     *os.File
+	buffered *bufio.Writer
+	writer   recordWriter
+	lastUse  int
+}
+
+// file returns the open file named `name`, creating it at `path` or
+// reopening it if needed.
+func (p *partitions) file(name, path string) (*partitionFile, error) {
+	p.uses++
+
+	f, ok := p.files[name]
+	if ok && f.file != nil {
+		f.lastUse = p.uses
+		return f, nil
+	}
+
+	if !ok {
+		f = &partitionFile{path: path}
+		p.files[name] = f
+	}
+
+	if p.open >= p.opts.maxOpenFiles() {
+		if err := p.closeLeastRecentlyUsed(); err != nil {
+			return nil, err
+		}
+	}
+
+	// The file is only created and its header written the first time it is
+	// opened. It is appended to when reopened.
+	opts := p.opts
+	var file *os.File
+	var err error
+	if !ok {
+		file, err = createPartitionTemp(f.path, opts)
+	} else {
+		opts.NoHeader = true
+		file, err = os.OpenFile(f.temp, os.O_WRONLY|os.O_APPEND, 0)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening output file (%s): %w", f.path, err)
+	}
+	f.temp = file.Name()
+	f.file = file
+	f.buffered = bufio.NewWriterSize(file, opts.bufferSize())
+	f.writer = newRecordWriter(f.buffered, opts)
+	f.lastUse = p.uses
+	p.open++
+
+	err = f.writer.writeHeader(p.header)
+	if err != nil {
+		return nil, fmt.Errorf("writing %s header to %s: %w", opts.Format, f.path, err)
+	}
+	return f, nil
+}
+
+// createPartitionTemp creates the temporary file for `path`. It is an error
+// for `path` to exist if Options.NoClobber is set. An existing file keeps its
+// permissions.
+func createPartitionTemp(path string, opts Options) (*os.File, error) {
+	info, err := os.Lstat(path)
+	switch {
+	case err == nil && opts.NoClobber:
+		return nil, os.ErrExist
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	file, err := createTemp(path)
+	if err != nil {
+		return nil, err
+	}
+	if info != nil {
+		if err := file.Chmod(info.Mode().Perm()); err != nil {
+			file.Close()
+			os.Remove(file.Name())
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+func (p *partitions) closeLeastRecentlyUsed() error {
+	var lru *partitionFile
+	for _, f := range p.files {
+		if f.file != nil && (lru == nil || f.lastUse < lru.lastUse) {
+			lru = f
+		}
+	}
+	return p.closeFile(lru, !p.opts.NoSync)
+}
+
+// closeFile flushes and closes `f`, syncing it first if `sync` is set.
+func (p *partitions) closeFile(f *partitionFile, sync bool) error {
+	file := f.file
+	f.file = nil
+	p.open--
+
+	if err := f.writer.flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("flushing %s to %s: %w", p.opts.Format, f.path, err)
+	}
+	if err := f.buffered.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("flushing output file (%s): %w", f.path, err)
+	}
+	if sync {
+		if err := p.syncFile(file); err != nil {
+			file.Close()
+			return fmt.Errorf("syncing file (%s): %w", f.path, err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("closing file (%s): %w", f.path, err)
+	}
+	return nil
+}
+
+// close closes the open files and renames the temporary files, returning
+// the first error. The temporary files are removed if any file fails.
+func (p *partitions) close() error {
+	var firstErr error
+	for _, f := range p.files {
+		if f.file == nil {
+			continue
+		}
+		if err := p.closeFile(f, !p.opts.NoSync); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		p.abort()
+		return firstErr
+	}
+
+	for _, f := range p.files {
+		if err := os.Rename(f.temp, f.path); err != nil {
+			p.abort()
+			return fmt.Errorf("renaming output file (%s): %w", f.path, err)
+		}
+		f.temp = ""
+	}
+	return nil
+}
+
+// abort closes the open files after an error and removes the temporary
+// files not yet renamed.
+func (p *partitions) abort() {
+	for _, f := range p.files {
+		if f.file != nil {
+			f.file.Close()
+			f.file = nil
+		}
+		if f.temp != "" {
+			os.Remove(f.temp)
+			f.temp = ""
+		}
+	}
+}
+
+// isPartitionName returns true if `name` may be used as the name of a
+// partition file. Only ASCII letters, digits, "-", "_", and "." are allowed
+// to keep the files within the output directory and portable.
+func isPartitionName(name string) bool {
+	if name == "." || name == ".." {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-' || r == '_' || r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,253 @@
+package convert
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const partitionInput = `network,country
+1.0.0.0/24,AU
+1.0.1.0/24,CN
+1.0.2.0/24,AU
+1.0.3.0/24,
+1.0.4.0/24,US
+1.0.5.0/24,CN
+`
+
+func readPartitions(t *testing.T, dir string) map[string]string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+
+	files := map[string]string{}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		require.NoError(t, err)
+		files[e.Name()] = string(b)
+	}
+	return files
+}
+
+func TestConvertPartitioned(t *testing.T) {
+	expected := map[string]string{
+		"AU.csv":     "network,country\n1.0.0.0/24,AU\n1.0.2.0/24,AU\n",
+		"CN.csv":     "network,country\n1.0.1.0/24,CN\n1.0.5.0/24,CN\n",
+		"US.csv":     "network,country\n1.0.4.0/24,US\n",
+		"_empty.csv": "network,country\n1.0.3.0/24,\n",
+	}
+
+	// With a single open file, every change of country reopens a file.
+	for _, maxOpenFiles := range []int{0, 1, 2} {
+		dir := filepath.Join(t.TempDir(), "out")
+		stats, err := ConvertPartitionedWithOptions(
+			[]io.Reader{strings.NewReader(partitionInput)},
+			dir,
+			Options{CIDR: true, PartitionBy: "country", MaxOpenFiles: maxOpenFiles},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 6, stats.RecordsProcessed)
+		assert.Equal(t, expected, readPartitions(t, dir), maxOpenFiles)
+	}
+
+	dir := t.TempDir()
+	_, err := ConvertPartitionedWithOptions(
+		[]io.Reader{strings.NewReader(partitionInput)},
+		dir,
+		Options{IPRange: true, PartitionBy: "country", NoHeader: true, Format: OutputFormatTSVRaw, MaxOpenFiles: 1},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.4.0\t1.0.4.255\tUS\n", readPartitions(t, dir)["US.tsv"])
+}
+
+func TestPartitionsSyncEvicted(t *testing.T) {
+	for _, noSync := range []bool{false, true} {
+		dir := t.TempDir()
+		p := newPartitions([]string{"network"}, Options{CIDR: true, MaxOpenFiles: 1, NoSync: noSync})
+
+		var synced []string
+		p.syncFile = func(f *os.File) error {
+			synced = append(synced, f.Name())
+			return nil
+		}
+
+		// Opening b.csv evicts a.csv.
+		for _, name := range []string{"a", "b"} {
+			_, err := p.file(name, filepath.Join(dir, name+".csv"))
+			require.NoError(t, err)
+		}
+		temps := []string{p.files["a"].temp, p.files["b"].temp}
+		require.NoError(t, p.close())
+
+		if noSync {
+			assert.Empty(t, synced)
+		} else {
+			assert.Equal(t, temps, synced)
+		}
+	}
+}
+
+func TestConvertFilesPartitioned(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "blocks.csv")
+	require.NoError(t, os.WriteFile(input, []byte(partitionInput), 0o600))
+
+	outputDir := filepath.Join(dir, "out")
+	_, err := ConvertFilesPartitionedWithOptions(
+		[]string{input, input},
+		outputDir,
+		Options{CIDR: true, PartitionBy: "country"},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "network,country\n1.0.4.0/24,US\n1.0.4.0/24,US\n", readPartitions(t, outputDir)["US.csv"])
+}
+
+func TestConvertPartitionedErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  Options
+		err   string
+	}{
+		{
+			name:  "missing column",
+			input: partitionInput,
+			opts:  Options{CIDR: true, PartitionBy: "country_iso_code"},
+			err:   `input 1: partition column "country_iso_code" not found in header`,
+		},
+		{
+			name:  "no column",
+			input: partitionInput,
+			opts:  Options{CIDR: true},
+			err:   "input 1: PartitionBy must be set to partition the output",
+		},
+		{
+			name:  "unsafe value",
+			input: "network,country\n1.0.0.0/24,../AU\n",
+			opts:  Options{CIDR: true, PartitionBy: "country"},
+			err:   `input 1: country value "../AU" on line 2 may not be used as a file name`,
+		},
+		{
+			name:  "empty partition value",
+			input: "network,country\n1.0.0.0/24,\n1.0.1.0/24,_empty\n",
+			opts:  Options{CIDR: true, PartitionBy: "country"},
+			err:   `input 1: country value "_empty" on line 3 may not be used as a file name`,
+		},
+		{
+			name:  "dot value",
+			input: "network,country\n1.0.0.0/24,..\n",
+			opts:  Options{CIDR: true, PartitionBy: "country"},
+			err:   `input 1: country value ".." on line 2 may not be used as a file name`,
+		},
+		{
+			name:  "no trailing newline",
+			input: partitionInput,
+			opts:  Options{CIDR: true, PartitionBy: "country", NoTrailingNewline: true},
+			err:   "input 1: NoTrailingNewline may not be used when partitioning the output",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			_, err := ConvertPartitionedWithOptions([]io.Reader{strings.NewReader(test.input)}, dir, test.opts)
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestConvertPartitionedFailure(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "AU.csv"), []byte("existing"), 0o600))
+
+	// The existing file is kept and no temporary files are left behind.
+	_, err := ConvertPartitionedWithOptions(
+		[]io.Reader{strings.NewReader("network,country\n1.0.0.0/24,AU\n1.0.1.0/24,CN\nbad,AU\n")},
+		dir,
+		Options{CIDR: true, PartitionBy: "country"},
+	)
+	require.Error(t, err)
+	assert.Equal(t, map[string]string{"AU.csv": "existing"}, readPartitions(t, dir))
+
+	_, err = ConvertPartitionedWithOptions(
+		[]io.Reader{strings.NewReader(partitionInput)},
+		dir,
+		Options{CIDR: true, PartitionBy: "country", NoClobber: true},
+	)
+	assert.ErrorIs(t, err, os.ErrExist)
+	assert.Equal(t, map[string]string{"AU.csv": "existing"}, readPartitions(t, dir))
+}
+
+func TestConvertSharded(t *testing.T) {
+	for _, maxOpenFiles := range []int{0, 1} {
+		dir := t.TempDir()
+		stats, err := ConvertShardedWithOptions(
+			[]io.Reader{strings.NewReader(partitionInput)},
+			filepath.Join(dir, "out-%02d.csv"),
+			Options{CIDR: true, Shards: 4, MaxOpenFiles: maxOpenFiles},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, 6, stats.RecordsProcessed)
+		assert.Equal(t, map[string]string{
+			"out-00.csv": "network,country\n1.0.0.0/24,AU\n1.0.4.0/24,US\n",
+			"out-01.csv": "network,country\n1.0.1.0/24,CN\n1.0.5.0/24,CN\n",
+			"out-02.csv": "network,country\n1.0.2.0/24,AU\n",
+			"out-03.csv": "network,country\n1.0.3.0/24,\n",
+		}, readPartitions(t, dir), maxOpenFiles)
+	}
+
+	// Shards without records are still created.
+	dir := t.TempDir()
+	_, err := ConvertShardedWithOptions(
+		[]io.Reader{strings.NewReader(ipv4BlocksInput)},
+		filepath.Join(dir, "out-%d.csv"),
+		Options{CIDR: true, Shards: 3},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "network,geoname_id\n", readPartitions(t, dir)["out-2.csv"])
+
+	// Every shard of an empty input is created, with a header if there is
+	// one.
+	for input, expected := range map[string]string{"": "", "network,geoname_id\n": "network,geoname_id\n"} {
+		dir := t.TempDir()
+		_, err := ConvertShardedWithOptions(
+			[]io.Reader{strings.NewReader(input)},
+			filepath.Join(dir, "out-%d.csv"),
+			Options{CIDR: true, Shards: 2},
+		)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"out-0.csv": expected, "out-1.csv": expected}, readPartitions(t, dir))
+	}
+}
+
+func TestConvertShardedErrors(t *testing.T) {
+	for template, opts := range map[string]Options{
+		"out.csv":    {CIDR: true, Shards: 2},
+		"out-%s.csv": {CIDR: true, Shards: 2},
+		"%d-%d.csv":  {CIDR: true, Shards: 2},
+	} {
+		_, err := ConvertShardedWithOptions(
+			[]io.Reader{strings.NewReader(partitionInput)},
+			filepath.Join(t.TempDir(), template),
+			opts,
+		)
+		assert.ErrorContains(t, err, "must have a single %d verb for the shard number", template)
+	}
+
+	_, err := ConvertShardedWithOptions(
+		[]io.Reader{strings.NewReader(partitionInput)},
+		filepath.Join(t.TempDir(), "out-%d.csv"),
+		Options{CIDR: true},
+	)
+	assert.EqualError(t, err, "input 1: at least one shard is required to shard the output")
+}
+
+func TestShardPath(t *testing.T) {
+	path, err := ShardPath("out-%03d.csv", 7)
+	require.NoError(t, err)
+	assert.Equal(t, "out-007.csv", path)
+}
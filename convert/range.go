@@ -0,0 +1,36 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"go4.org/netipx"
+)
+
+// RangeToPrefixes returns the smallest list of networks covering the
+// addresses from `start` to `end`, inclusive, in ascending order. It returns
+// an error if either address is invalid or has a zone, if the addresses are
+// of different families, or if `start` is after `end`. An IPv4-mapped IPv6
+// address is an IPv6 address and is not of the same family as an IPv4
+// address.
+func RangeToPrefixes(start, end netip.Addr) ([]netip.Prefix, error) {
+	if !start.IsValid() || !end.IsValid() {
+		return nil, errors.New("the start and end of the range must be valid addresses")
+	}
+	if start.Zone() != "" || end.Zone() != "" {
+		return nil, fmt.Errorf("the range %s-%s may not have a zone", start, end)
+	}
+	if start.Is4() != end.Is4() {
+		return nil, fmt.Errorf(
+			"the start (%s) and end (%s) of the range must both be IPv4 or both be IPv6",
+			start,
+			end,
+		)
+	}
+	if end.Less(start) {
+		return nil, fmt.Errorf("the start (%s) of the range is after its end (%s)", start, end)
+	}
+
+	return netipx.IPRangeFrom(start, end).Prefixes(), nil
+}
@@ -0,0 +1,78 @@
+package convert
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRangeToPrefixes(t *testing.T) {
+	tests := []struct {
+		start    string
+		end      string
+		expected []string
+	}{
+		{"1.0.0.0", "1.0.0.255", []string{"1.0.0.0/24"}},
+		{"1.0.0.1", "1.0.0.1", []string{"1.0.0.1/32"}},
+		{"1.0.0.1", "1.0.0.6", []string{"1.0.0.1/32", "1.0.0.2/31", "1.0.0.4/31", "1.0.0.6/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"127.255.255.255", "128.0.0.0", []string{"127.255.255.255/32", "128.0.0.0/32"}},
+		{"2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", []string{"2001:db8::/32"}},
+		{"2001:db8::1", "2001:db8::3", []string{"2001:db8::1/128", "2001:db8::2/127"}},
+		{"::", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", []string{"::/0"}},
+		{"::ffff:1.0.0.0", "::ffff:1.0.0.255", []string{"::ffff:1.0.0.0/120"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.start+"-"+test.end, func(t *testing.T) {
+			prefixes, err := RangeToPrefixes(netip.MustParseAddr(test.start), netip.MustParseAddr(test.end))
+			require.NoError(t, err)
+
+			var actual []string
+			for _, p := range prefixes {
+				actual = append(actual, p.String())
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestRangeToPrefixesErrors(t *testing.T) {
+	tests := []struct {
+		start netip.Addr
+		end   netip.Addr
+		err   string
+	}{
+		{
+			start: netip.MustParseAddr("1.0.0.0"),
+			end:   netip.MustParseAddr("2001:db8::"),
+			err:   "the start (1.0.0.0) and end (2001:db8::) of the range must both be IPv4 or both be IPv6",
+		},
+		{
+			start: netip.MustParseAddr("1.0.0.0"),
+			end:   netip.MustParseAddr("::ffff:1.0.0.255"),
+			err:   "the start (1.0.0.0) and end (::ffff:1.0.0.255) of the range must both be IPv4 or both be IPv6",
+		},
+		{
+			start: netip.MustParseAddr("1.0.0.1"),
+			end:   netip.MustParseAddr("1.0.0.0"),
+			err:   "the start (1.0.0.1) of the range is after its end (1.0.0.0)",
+		},
+		{
+			start: netip.MustParseAddr("fe80::1%eth0"),
+			end:   netip.MustParseAddr("fe80::2%eth0"),
+			err:   "the range fe80::1%eth0-fe80::2%eth0 may not have a zone",
+		},
+		{
+			end: netip.MustParseAddr("1.0.0.0"),
+			err: "the start and end of the range must be valid addresses",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := RangeToPrefixes(test.start, test.end)
+		assert.EqualError(t, err, test.err)
+	}
+}
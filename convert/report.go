@@ -0,0 +1,101 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Report summarizes a conversion, e.g., to keep a record of each conversion
+// without having to read the output.
+type Report struct {
+	// Inputs are the names of the input files.
+	Inputs []string
+	// Output is the name of the output file.
+	Output string
+	// Stats are the statistics returned by the conversion.
+	Stats Stats
+	// Elapsed is how long the conversion took.
+	Elapsed time.Duration
+}
+
+// jsonReport is the JSON representation of a Report. TotalAddresses is a
+// string as it may exceed the integers JSON parsers support.
+type jsonReport struct {
+	Inputs          []string `json:"inputs"`
+	Output          string   `json:"output"`
+	Records         int      `json:"records"`
+	IPv4Records     int      `json:"ipv4_records"`
+	IPv6Records     int      `json:"ipv6_records"`
+	TotalAddresses  string   `json:"total_addresses"`
+	FilteredRecords int      `json:"filtered_records"`
+	SkippedRecords  int      `json:"skipped_records"`
+	SkippedLines    []int    `json:"skipped_lines"`
+	ElapsedSeconds  float64  `json:"elapsed_seconds"`
+}
+
+// WriteJSON writes the report to `w` as a JSON object.
+func (r Report) WriteJSON(w io.Writer) error {
+	report := jsonReport{
+		Inputs:          r.Inputs,
+		Output:          r.Output,
+		Records:         r.Stats.RecordsProcessed,
+		IPv4Records:     r.Stats.IPv4Count,
+		IPv6Records:     r.Stats.IPv6Count,
+		TotalAddresses:  r.totalAddresses(),
+		FilteredRecords: r.Stats.FilteredRecords,
+		SkippedRecords:  r.Stats.SkippedRecords,
+		SkippedLines:    r.Stats.SkippedLines,
+		ElapsedSeconds:  r.Elapsed.Seconds(),
+	}
+	if report.Inputs == nil {
+		report.Inputs = []string{}
+	}
+	if report.SkippedLines == nil {
+		report.SkippedLines = []int{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+// WriteText writes the report to `w` as human-readable text, one field per
+// line.
+func (r Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	lines := [][2]string{
+		{"Inputs:", strings.Join(r.Inputs, ", ")},
+		{"Output:", r.Output},
+		{"Records:", strconv.Itoa(r.Stats.RecordsProcessed)},
+		{"IPv4 records:", strconv.Itoa(r.Stats.IPv4Count)},
+		{"IPv6 records:", strconv.Itoa(r.Stats.IPv6Count)},
+		{"Total addresses:", r.totalAddresses()},
+		{"Filtered records:", strconv.Itoa(r.Stats.FilteredRecords)},
+		{"Skipped records:", strconv.Itoa(r.Stats.SkippedRecords)},
+		{"Elapsed:", r.Elapsed.String()},
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintf(tw, "%s\t%s\n", line[0], line[1]); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+func (r Report) totalAddresses() string {
+	if r.Stats.TotalAddresses == nil {
+		return "0"
+	}
+	return r.Stats.TotalAddresses.String()
+}
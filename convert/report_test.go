@@ -0,0 +1,60 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	var output strings.Builder
+	stats, err := ConvertWithOptions(
+		strings.NewReader(ipv4BlocksInput+"bad,1\n"),
+		&output,
+		Options{CIDR: true, SkipInvalid: true},
+	)
+	require.NoError(t, err)
+
+	report := Report{
+		Inputs:  []string{"blocks.csv"},
+		Output:  "output.csv",
+		Stats:   stats,
+		Elapsed: 1500 * time.Millisecond,
+	}
+
+	var text strings.Builder
+	require.NoError(t, report.WriteText(&text))
+	assert.Equal(t, `Inputs:           blocks.csv
+Output:           output.csv
+Records:          2
+IPv4 records:     2
+IPv6 records:     0
+Total addresses:  512
+Filtered records: 0
+Skipped records:  1
+Elapsed:          1.5s
+`, text.String())
+
+	var json strings.Builder
+	require.NoError(t, report.WriteJSON(&json))
+	assert.JSONEq(t, `{
+  "inputs": ["blocks.csv"],
+  "output": "output.csv",
+  "records": 2,
+  "ipv4_records": 2,
+  "ipv6_records": 0,
+  "total_addresses": "512",
+  "filtered_records": 0,
+  "skipped_records": 1,
+  "skipped_lines": [4],
+  "elapsed_seconds": 1.5
+}`, json.String())
+
+	json.Reset()
+	require.NoError(t, Report{}.WriteJSON(&json))
+	assert.Contains(t, json.String(), `"inputs": []`)
+	assert.Contains(t, json.String(), `"total_addresses": "0"`)
+}
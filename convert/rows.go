@@ -0,0 +1,719 @@
+package convert
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/netip"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RowConverter reads a MaxMind GeoIP2 or GeoLite2 CSV and returns each
+// record converted as specified by the Options it was created with. It
+// allows the converted records to be processed without writing them as CSV.
+type RowConverter struct {
+	reader       recordReader
+	rejectWriter *csv.Writer
+	opts         Options
+	makeLine     lineFunc
+	filters      []rowFilter
+	sorted       []convertedRow
+	batch        []convertedRow
+	batchErr     error
+	empty        bool
+
+	header        []string
+	networkColumn int
+	// networkName is the name of the network column in the input header.
+	networkName   string
+	columnOrder   []int
+	geonameColumn int
+	line          int
+	started       time.Time
+	records       int
+	skipped       int
+	returned      int
+	stats         Stats
+}
+
+// parallelBatchSize is the number of records read and then converted at once
+// when Options.Workers is set.
+const parallelBatchSize = 4096
+
+// ErrEmptyInput is returned when the input is completely empty and
+// Options.ErrorOnEmpty is set.
+var ErrEmptyInput = errors.New("input is empty")
+
+// ErrEmptyOutput is returned when no records would be written to the output
+// and Options.ErrorOnEmptyOutput is set.
+var ErrEmptyOutput = errors.New("no records would be written to the output")
+
+// ErrBufferLimit is returned when more than Options.MaxBufferedRows records
+// would be held in memory.
+var ErrBufferLimit = errors.New("too many records to hold in memory")
+
+// bufferLimitError returns the error for exceeding a `max` of
+// Options.MaxBufferedRows while `doing` something, e.g., "sorting".
+func bufferLimitError(doing string, max int) error {
+	return fmt.Errorf("%s more than %d records: %w", doing, max, ErrBufferLimit)
+}
+
+// NewRowConverter returns a RowConverter reading the CSV from `input`. The
+// header row is read before NewRowConverter returns. If the input is
+// completely empty, Header returns nil and Next returns io.EOF unless
+// Options.ErrorOnEmpty is set.
+func NewRowConverter(input io.Reader, opts Options) (*RowConverter, error) {
+	makeHeader, makeLine := buildFuncs(opts)
+
+	return newRowConverter(input, opts, makeHeader, makeLine)
+}
+
+// recordReader reads CSV records. It is implemented by *csv.Reader and
+// *multiReader.
+type recordReader interface {
+	Read() ([]string, error)
+	FieldPos(field int) (line, column int)
+}
+
+// newCSVReader returns a csv.Reader reading `input` as specified by `opts`.
+// A leading UTF-8 byte order mark is skipped.
+func newCSVReader(input io.Reader, opts Options) (*csv.Reader, error) {
+	buffered := bufio.NewReaderSize(input, opts.bufferSize())
+
+	if opts.AutoDecompress {
+		decompressed, err := DecompressReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		buffered = bufio.NewReaderSize(decompressed, opts.bufferSize())
+	}
+
+	err := skipBOM(buffered)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(buffered)
+	if opts.AllowRaggedRows {
+		reader.FieldsPerRecord = -1
+	}
+	reader.Comment = opts.Comment
+
+	return reader, nil
+}
+
+// utf8BOM is the UTF-8 encoding of the byte order mark, which some Windows
+// tools write at the start of CSV files.
+const utf8BOM = "\ufeff"
+
+// skipBOM discards a UTF-8 byte order mark at the start of `r`.
+func skipBOM(r *bufio.Reader) error {
+	start, err := r.Peek(len(utf8BOM))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("reading CSV: %w", err)
+	}
+	if string(start) == utf8BOM {
+		_, err = r.Discard(len(utf8BOM))
+		return err
+	}
+	return nil
+}
+
+func newRowConverter(
+	input io.Reader,
+	opts Options,
+	makeHeader headerFunc,
+	makeLine lineFunc,
+) (*RowConverter, error) {
+	reader, err := newCSVReader(input, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRowConverterWithReader(reader, opts, makeHeader, makeLine)
+}
+
+func newRowConverterWithReader(
+	reader recordReader,
+	opts Options,
+	makeHeader headerFunc,
+	makeLine lineFunc,
+) (*RowConverter, error) {
+	if len(opts.Outputs) > 0 {
+		return nil, errors.New(
+			"Outputs may only be used with ConvertToOutputsWithOptions and ConvertFilesToOutputsWithOptions",
+		)
+	}
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		if opts.ErrorOnEmpty {
+			return nil, ErrEmptyInput
+		}
+		if opts.ErrorOnEmptyOutput {
+			return nil, ErrEmptyOutput
+		}
+		return &RowConverter{empty: true, stats: Stats{TotalAddresses: new(big.Int)}}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	if opts.InputNoHeader {
+		reader, header, err = headerlessReader(reader, header, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = validateHeader(header, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	networkColumn, err := findNetworkColumn(header, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &RowConverter{
+		reader:        reader,
+		opts:          opts,
+		makeLine:      makeLine,
+		networkColumn: networkColumn,
+		networkName:   header[networkColumn],
+		line:          1,
+		started:       time.Now(),
+		stats:         Stats{TotalAddresses: new(big.Int)},
+	}
+
+	if opts.IntegerBase != 0 && opts.integerBase() != opts.IntegerBase {
+		return nil, fmt.Errorf("the integer base must be between 2 and 36, not %d", opts.IntegerBase)
+	}
+
+	if opts.OnlyNetwork {
+		switch {
+		case !opts.HasRepresentation():
+			return nil, errors.New("a network representation is required when only including the network")
+		case opts.RetainNetworkColumn || opts.Locations != nil:
+			return nil, errors.New(
+				"the network column and locations may not be retained when only including the network",
+			)
+		}
+	}
+
+	if opts.IPRange && opts.ExclusiveEndRange {
+		return nil, errors.New("the IP range may not be used with the exclusive end range")
+	}
+
+	if opts.StartCountRange && (opts.IPRange || opts.ExclusiveEndRange) {
+		return nil, errors.New("the start and count range may not be used with the IP range or exclusive end range")
+	}
+
+	if opts.IntRange && !opts.IntRangeByFamily && opts.IntegerCIDR {
+		return nil, errors.New("the integer range may not be used with the integer CIDR")
+	}
+
+	if opts.RetainNetworkColumn && opts.CIDR {
+		return nil, errors.New("the CIDR representation may not be used when retaining the network column")
+	}
+
+	_, rest := c.splitRecord(header)
+	if opts.OnlyNetwork {
+		c.header = makeHeader(nil)
+	} else {
+		c.header = makeHeader(rest)
+	}
+
+	if opts.RowIndex {
+		c.header = append([]string{rowIndexColumn}, c.header...)
+	}
+
+	if opts.asnMode() {
+		asnColumn := columnIndex(rest, asnColumnName)
+		if asnColumn < 0 {
+			return nil, fmt.Errorf("column %q not found in header", asnColumnName)
+		}
+		c.filters = append(c.filters, asnFilter(opts, asnColumn))
+	}
+
+	if len(opts.Within) > 0 {
+		f, err := withinFilter(opts.Within, opts.WithinOverlap)
+		if err != nil {
+			return nil, err
+		}
+		c.filters = append(c.filters, f)
+	}
+
+	if opts.ExcludeReserved {
+		f, err := excludeReservedFilter()
+		if err != nil {
+			return nil, err
+		}
+		c.filters = append(c.filters, f)
+	}
+
+	for _, exclude := range []struct {
+		enabled bool
+		column  string
+	}{
+		{opts.ExcludeAnonymousProxy, "is_anonymous_proxy"},
+		{opts.ExcludeSatelliteProvider, "is_satellite_provider"},
+	} {
+		if !exclude.enabled {
+			continue
+		}
+		column := columnIndex(rest, exclude.column)
+		if column < 0 {
+			return nil, fmt.Errorf("column %q not found in header", exclude.column)
+		}
+		c.filters = append(c.filters, flagColumnFilter(column))
+	}
+
+	if opts.IPv4Octets && opts.IPv4OctetsSkipIPv6 {
+		c.filters = append(c.filters, ipv4OnlyFilter)
+	}
+
+	switch {
+	case opts.DedupAssumeSorted:
+		c.filters = append(c.filters, sortedDedupFilter())
+	case opts.Dedup:
+		c.filters = append(c.filters, dedupFilter(opts.MaxBufferedRows))
+	}
+
+	if opts.SampleEvery > 1 {
+		c.filters = append(c.filters, sampleEveryFilter(opts.SampleEvery))
+	}
+
+	if opts.SampleRate > 0 {
+		c.filters = append(c.filters, sampleRateFilter(opts.SampleRate, opts.SampleSeed))
+	}
+
+	if opts.Locations != nil {
+		c.geonameColumn = columnIndex(rest, "geoname_id")
+		if c.geonameColumn < 0 {
+			return nil, errors.New(`column "geoname_id" not found in header`)
+		}
+		c.header = append(c.header, locationHeader...)
+	}
+
+	if len(opts.RenameColumns) > 0 {
+		c.header, err = renameColumns(c.header, opts.RenameColumns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(opts.ColumnOrder) > 0 {
+		c.columnOrder, err = columnOrder(c.header, opts.ColumnOrder)
+		if err != nil {
+			return nil, err
+		}
+		c.header = reorder(c.header, c.columnOrder)
+	}
+
+	if opts.SkipInvalid && opts.RejectOutput != nil {
+		c.rejectWriter = csv.NewWriter(opts.RejectOutput)
+
+		if !opts.InputNoHeader {
+			err = c.rejectWriter.Write(header)
+			if err != nil {
+				return nil, fmt.Errorf("writing reject CSV header: %w", err)
+			}
+		}
+	}
+
+	opts.logf("started converting an input with %d column(s)", len(header))
+	return c, nil
+}
+
+// Header returns the converted header row. It is available as soon as the
+// RowConverter is created and does not change between calls.
+func (c *RowConverter) Header() []string {
+	if c.empty {
+		return nil
+	}
+	return append([]string(nil), c.header...)
+}
+
+// Next returns the next converted record. It returns io.EOF once the input
+// has been exhausted.
+func (c *RowConverter) Next() ([]string, error) {
+	row, err := c.nextRow()
+	return row.record, err
+}
+
+func (c *RowConverter) nextRow() (convertedRow, error) {
+	if c.empty {
+		return convertedRow{}, io.EOF
+	}
+
+	if c.opts.Limit > 0 && c.returned >= c.opts.Limit {
+		return convertedRow{}, c.finish()
+	}
+
+	for c.skipped < c.opts.Skip {
+		_, err := c.nextUnlimited()
+		if errors.Is(err, io.EOF) && c.returned == 0 && c.opts.ErrorOnEmptyOutput {
+			return convertedRow{}, ErrEmptyOutput
+		} else if err != nil {
+			return convertedRow{}, err
+		}
+		c.skipped++
+	}
+
+	row, err := c.nextUnlimited()
+	if errors.Is(err, io.EOF) && c.returned == 0 && c.opts.ErrorOnEmptyOutput {
+		return row, ErrEmptyOutput
+	} else if err != nil {
+		return row, err
+	}
+
+	c.returned++
+	c.count(row.network)
+
+	return row, nil
+}
+
+// nextUnlimited returns the next record without regard to Options.Skip and
+// Options.Limit.
+func (c *RowConverter) nextUnlimited() (convertedRow, error) {
+	if c.opts.Sort {
+		return c.nextSorted()
+	}
+	return c.next()
+}
+
+// rowIndexColumn is the name of the Options.RowIndex column.
+const rowIndexColumn = "row_index"
+
+// convertedRow is a converted record along with its network, the line it
+// was read from, and its position in the input.
+type convertedRow struct {
+	network netip.Prefix
+	line    int
+	index   int
+	record  []string
+
+	// rest is the unconverted columns other than the network column.
+	rest []string
+}
+
+func (c *RowConverter) next() (convertedRow, error) {
+	if c.opts.Workers > 1 {
+		return c.nextParallel()
+	}
+
+	row, err := c.read()
+	if err != nil {
+		return row, err
+	}
+	c.convert(&row)
+	return row, nil
+}
+
+// nextParallel returns the next record, converting the records in batches
+// using Options.Workers goroutines. The records are read and filtered
+// sequentially, so the order of the output is the same as without workers.
+func (c *RowConverter) nextParallel() (convertedRow, error) {
+	if len(c.batch) == 0 && c.batchErr == nil {
+		batch := make([]convertedRow, 0, parallelBatchSize)
+		for len(batch) < parallelBatchSize {
+			row, err := c.read()
+			if err != nil {
+				c.batchErr = err
+				break
+			}
+			batch = append(batch, row)
+		}
+		c.convertBatch(batch)
+		c.batch = batch
+	}
+
+	if len(c.batch) == 0 {
+		return convertedRow{}, c.batchErr
+	}
+
+	row := c.batch[0]
+	c.batch = c.batch[1:]
+	c.line = row.line
+
+	return row, nil
+}
+
+// convertBatch converts `rows`, splitting them between Options.Workers
+// goroutines.
+func (c *RowConverter) convertBatch(rows []convertedRow) {
+	chunk := (len(rows) + c.opts.Workers - 1) / c.opts.Workers
+
+	var wg sync.WaitGroup
+	for start := 0; start < len(rows); start += chunk {
+		end := start + chunk
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		wg.Add(1)
+		go func(rows []convertedRow) {
+			defer wg.Done()
+			for i := range rows {
+				c.convert(&rows[i])
+			}
+		}(rows[start:end])
+	}
+	wg.Wait()
+}
+
+// convert sets the converted record of `row`. It is safe to call
+// concurrently.
+func (c *RowConverter) convert(row *convertedRow) {
+	if c.opts.OnlyNetwork {
+		row.record = c.makeLine(row.network, nil)
+	} else {
+		row.record = c.makeLine(row.network, c.nullValues(row.rest))
+	}
+
+	// The lineFunc leaves the first column empty for the index.
+	if c.opts.RowIndex {
+		row.record[0] = strconv.Itoa(c.opts.RowIndexStart + row.index)
+	}
+
+	if c.opts.Locations != nil {
+		row.record = append(row.record, c.nullValues(c.opts.Locations.lookup(field(row.rest, c.geonameColumn)))...)
+	}
+
+	if c.columnOrder != nil {
+		row.record = reorder(row.record, c.columnOrder)
+	}
+	row.rest = nil
+}
+
+// nullValues returns `values` with the empty values replaced by
+// Options.NullValue. `values` is not modified.
+func (c *RowConverter) nullValues(values []string) []string {
+	if c.opts.NullValue == "" || !c.opts.Format.HasColumns() {
+		return values
+	}
+
+	var replaced []string
+	for i, v := range values {
+		if v != "" {
+			continue
+		}
+		if replaced == nil {
+			replaced = append([]string(nil), values...)
+		}
+		replaced[i] = c.opts.NullValue
+	}
+	if replaced == nil {
+		return values
+	}
+	return replaced
+}
+
+// read returns the next record that is not skipped or filtered without
+// converting it.
+func (c *RowConverter) read() (convertedRow, error) {
+	for {
+		record, err := c.reader.Read()
+		if errors.Is(err, io.EOF) {
+			return convertedRow{}, c.finish()
+		} else if err != nil {
+			return convertedRow{}, fmt.Errorf("reading CSV: %w", err)
+		}
+
+		c.line, _ = c.reader.FieldPos(0)
+
+		if isBlank(record) {
+			continue
+		}
+		index := c.records
+		c.records++
+
+		if c.networkColumn >= len(record) {
+			return convertedRow{}, fmt.Errorf("record on line %d has no network column", c.line)
+		}
+
+		network, rest := c.splitRecord(record)
+
+		prefix, err := ParseNetwork(network)
+		notNetwork := err != nil
+		if err == nil && prefix != prefix.Masked() {
+			if c.opts.Normalize {
+				prefix = prefix.Masked()
+			} else if c.opts.RequireCanonical {
+				err = fmt.Errorf("host bits are set; the canonical form is %s", prefix.Masked())
+			}
+		}
+		if err != nil {
+			if !c.opts.SkipInvalid {
+				err = fmt.Errorf("parsing network on line %d (%s): %w", c.line, network, err)
+				if notNetwork && index == 0 {
+					err = c.networkColumnHint(err)
+				}
+				return convertedRow{}, err
+			}
+
+			err = c.reject(record)
+			if err != nil {
+				return convertedRow{}, err
+			}
+			continue
+		}
+
+		if c.opts.Unmap {
+			prefix = unmapPrefix(prefix)
+		}
+
+		keep, err := c.filter(prefix, rest)
+		if err != nil {
+			return convertedRow{}, err
+		}
+		if !keep {
+			c.stats.FilteredRecords++
+			continue
+		}
+
+		return convertedRow{network: prefix, line: c.line, index: index, rest: rest}, nil
+	}
+}
+
+// nextSorted returns the next record sorted by network. All of the records
+// are read and buffered on the first call.
+func (c *RowConverter) nextSorted() (convertedRow, error) {
+	if c.sorted == nil {
+		c.opts.logf("reading the records to sort")
+		rows := []convertedRow{}
+		for {
+			row, err := c.next()
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return convertedRow{}, err
+			}
+			if c.opts.MaxBufferedRows > 0 && len(rows) == c.opts.MaxBufferedRows {
+				return convertedRow{}, bufferLimitError("sorting", c.opts.MaxBufferedRows)
+			}
+			rows = append(rows, row)
+		}
+
+		c.opts.logf("read %d record(s) in %s", len(rows), time.Since(c.started).Round(time.Millisecond))
+		start := time.Now()
+		sortRows(rows, c.opts.SortIPv6First)
+		c.opts.logf("sorted %d record(s) in %s", len(rows), time.Since(start).Round(time.Millisecond))
+		c.sorted = rows
+	}
+
+	if len(c.sorted) == 0 {
+		return convertedRow{}, io.EOF
+	}
+
+	row := c.sorted[0]
+	c.sorted = c.sorted[1:]
+	c.line = row.line
+
+	return row, nil
+}
+
+// sortRows sorts `rows` by the start address of their networks and then by
+// prefix length. IPv4 networks sort before IPv6 networks unless `ipv6First`
+// is true.
+func sortRows(rows []convertedRow, ipv6First bool) {
+	slices.SortStableFunc(rows, func(a, b convertedRow) int {
+		aAddr, bAddr := a.network.Addr(), b.network.Addr()
+		if ipv6First && aAddr.BitLen() != bAddr.BitLen() {
+			return bAddr.BitLen() - aAddr.BitLen()
+		}
+		if cmp := aAddr.Compare(bAddr); cmp != 0 {
+			return cmp
+		}
+		return a.network.Bits() - b.network.Bits()
+	})
+}
+
+// splitRecord returns the network and the remaining columns of `record`.
+// The remaining columns include the network column if
+// Options.RetainNetworkColumn is set.
+func (c *RowConverter) splitRecord(record []string) (string, []string) {
+	if c.opts.RetainNetworkColumn {
+		return record[c.networkColumn], record
+	}
+	return splitRecord(record, c.networkColumn)
+}
+
+// isBlank returns true if every column of `record` is empty, e.g., for a
+// line containing only commas.
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Stats returns the statistics for the records read so far.
+func (c *RowConverter) Stats() Stats {
+	stats := c.stats
+	stats.TotalAddresses = new(big.Int).Set(c.stats.TotalAddresses)
+	stats.SkippedLines = append([]int(nil), c.stats.SkippedLines...)
+	return stats
+}
+
+func (c *RowConverter) filter(network netip.Prefix, rest []string) (bool, error) {
+	for _, f := range c.filters {
+		keep, err := f(network, rest)
+		if err != nil {
+			return false, fmt.Errorf("filtering record on line %d: %w", c.line, err)
+		}
+		if !keep {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (c *RowConverter) count(network netip.Prefix) {
+	c.stats.RecordsProcessed++
+	if network.Addr().Is4() {
+		c.stats.IPv4Count++
+	} else {
+		c.stats.IPv6Count++
+	}
+
+	c.stats.TotalAddresses.Add(c.stats.TotalAddresses, addressCount(network))
+}
+
+func (c *RowConverter) reject(record []string) error {
+	c.stats.SkippedRecords++
+	if len(c.stats.SkippedLines) < maxSkippedLines {
+		c.stats.SkippedLines = append(c.stats.SkippedLines, c.line)
+	}
+
+	if c.rejectWriter == nil {
+		return nil
+	}
+
+	err := c.rejectWriter.Write(record)
+	if err != nil {
+		return fmt.Errorf("writing reject CSV on line %d: %w", c.line, err)
+	}
+	return nil
+}
+
+// finish flushes any pending output and returns io.EOF on success.
+func (c *RowConverter) finish() error {
+	if c.rejectWriter != nil {
+		c.rejectWriter.Flush()
+
+		if err := c.rejectWriter.Error(); err != nil {
+			return fmt.Errorf("flushing reject CSV: %w", err)
+		}
+	}
+	return io.EOF
+}
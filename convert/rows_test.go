@@ -0,0 +1,508 @@
+package convert
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRowConverter(t *testing.T) {
+	input := `network,geoname_id
+1.0.0.0/24,2077456
+2001:4220::/32,357994
+`
+
+	rows, err := NewRowConverter(strings.NewReader(input), Options{IPRange: true})
+	require.NoError(t, err)
+
+	// The header is available before any records are read.
+	expectedHeader := []string{"network_start_ip", "network_last_ip", "geoname_id"}
+	assert.Equal(t, expectedHeader, rows.Header())
+
+	record, err := rows.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0.0", "1.0.0.255", "2077456"}, record)
+
+	// Reading records does not change the header.
+	assert.Equal(t, expectedHeader, rows.Header())
+
+	record, err = rows.Next()
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{"2001:4220::", "2001:4220:ffff:ffff:ffff:ffff:ffff:ffff", "357994"},
+		record,
+	)
+
+	_, err = rows.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestRowConverterBOM(t *testing.T) {
+	input := utf8BOM + "network,geoname_id\n1.0.0.0/24,2077456\n"
+
+	rows, err := NewRowConverter(strings.NewReader(input), Options{CIDR: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"network", "geoname_id"}, rows.Header())
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err = gz.Write([]byte(input))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	rows, err = NewRowConverter(&compressed, Options{CIDR: true, AutoDecompress: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"network", "geoname_id"}, rows.Header())
+
+	rows, err = NewRowConverter(
+		strings.NewReader(utf8BOM+"1.0.0.0/24\n"),
+		Options{CIDR: true, InputNoHeader: true},
+	)
+	require.NoError(t, err)
+	record, err := rows.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0.0/24"}, record)
+
+	// Only a byte order mark at the start of the input is skipped.
+	rows, err = NewRowConverter(
+		strings.NewReader(utf8BOM+utf8BOM+"geoname_id,network\n"),
+		Options{CIDR: true, NetworkColumn: 1},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"network", utf8BOM + "geoname_id"}, rows.Header())
+
+	rows, err = NewRowConverter(strings.NewReader(utf8BOM), Options{CIDR: true})
+	require.NoError(t, err)
+	assert.Nil(t, rows.Header())
+}
+
+func TestRowConverterHeaderCopy(t *testing.T) {
+	rows, err := NewRowConverter(strings.NewReader("network,geoname_id\n"), Options{CIDR: true})
+	require.NoError(t, err)
+
+	header := rows.Header()
+	header[0] = "changed"
+
+	assert.Equal(t, []string{"network", "geoname_id"}, rows.Header())
+}
+
+func TestRowConverterErrors(t *testing.T) {
+	_, err := NewRowConverter(strings.NewReader(""), Options{CIDR: true, ErrorOnEmpty: true})
+	require.ErrorIs(t, err, ErrEmptyInput)
+
+	rows, err := NewRowConverter(strings.NewReader("network\nbad\n"), Options{CIDR: true})
+	require.NoError(t, err)
+
+	_, err = rows.Next()
+	require.EqualError(t, err, `parsing network on line 2 (bad): netip.ParsePrefix("bad"): no '/'`)
+}
+
+func TestRowConverterSort(t *testing.T) {
+	input := `network,geoname_id
+2001:4220::/32,1
+5.61.192.0/21,2
+1.0.0.0/24,3
+::ffff:1.0.0.0/120,4
+1.0.0.0/16,5
+2.0.0.0/8,6
+`
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected []string
+	}{
+		{
+			name: "IPv4 first",
+			opts: Options{CIDR: true, Sort: true},
+			expected: []string{
+				"1.0.0.0/16",
+				"1.0.0.0/24",
+				"2.0.0.0/8",
+				"5.61.192.0/21",
+				"::ffff:1.0.0.0/120",
+				"2001:4220::/32",
+			},
+		},
+		{
+			name: "IPv6 first",
+			opts: Options{CIDR: true, Sort: true, SortIPv6First: true},
+			expected: []string{
+				"::ffff:1.0.0.0/120",
+				"2001:4220::/32",
+				"1.0.0.0/16",
+				"1.0.0.0/24",
+				"2.0.0.0/8",
+				"5.61.192.0/21",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, err := NewRowConverter(strings.NewReader(input), test.opts)
+			require.NoError(t, err)
+
+			var networks []string
+			for {
+				record, err := rows.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				networks = append(networks, record[0])
+			}
+
+			assert.Equal(t, test.expected, networks)
+		})
+	}
+}
+
+func TestRowConverterSortMaxBufferedRows(t *testing.T) {
+	input := "network,geoname_id\n2.0.0.0/8,1\n1.0.0.0/24,2\n3.0.0.0/8,3\n"
+
+	var output strings.Builder
+	_, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&output,
+		Options{CIDR: true, Sort: true, MaxBufferedRows: 3},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "network,geoname_id\n1.0.0.0/24,2\n2.0.0.0/8,1\n3.0.0.0/8,3\n", output.String())
+
+	_, err = ConvertWithOptions(
+		strings.NewReader(input),
+		io.Discard,
+		Options{CIDR: true, Sort: true, MaxBufferedRows: 2},
+	)
+	require.ErrorIs(t, err, ErrBufferLimit)
+	assert.EqualError(t, err, "sorting more than 2 records: too many records to hold in memory")
+}
+
+func TestRowConverterRowIndex(t *testing.T) {
+	input := `network,geoname_id
+2.0.0.0/8,1
+10.0.0.0/8,2
+
+1.0.0.0/24,3
+bad,4
+192.168.0.0/16,5
+`
+
+	var outbuf bytes.Buffer
+	_, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		Options{CIDR: true, RowIndex: true, Sort: true, SkipInvalid: true, ExcludeReserved: true},
+	)
+	require.NoError(t, err)
+	// Rejected and filtered records are counted but blank lines are not.
+	assert.Equal(t, "row_index,network,geoname_id\n2,1.0.0.0/24,3\n0,2.0.0.0/8,1\n", outbuf.String())
+
+	rows, err := NewRowConverter(
+		strings.NewReader(input),
+		Options{CIDR: true, RowIndex: true, RowIndexStart: 1, SkipInvalid: true, Skip: 1, Workers: 2},
+	)
+	require.NoError(t, err)
+	var indexes []string
+	for {
+		record, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		indexes = append(indexes, record[0])
+	}
+	assert.Equal(t, []string{"2", "3", "5"}, indexes)
+
+	// The index has a column of its own without a network representation.
+	outbuf.Reset()
+	_, err = ConvertWithOptions(
+		strings.NewReader("network,geoname_id\n1.0.0.0/24,1\n"),
+		&outbuf,
+		Options{RowIndex: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "row_index,geoname_id\n0,1\n", outbuf.String())
+
+	_, err = CheckOverlaps(strings.NewReader("network\n1.0.0.0/24\n"), Options{RowIndex: true})
+	require.NoError(t, err)
+}
+
+func TestRowConverterOnlyNetwork(t *testing.T) {
+	input := `network,geoname_id,is_anonymous_proxy
+1.0.0.0/24,2077456,0
+1.0.1.0/24,,1
+2001:4220::/32,357994,0
+`
+
+	var outbuf bytes.Buffer
+	_, err := ConvertWithOptions(
+		strings.NewReader(input),
+		&outbuf,
+		Options{CIDR: true, IntRange: true, OnlyNetwork: true, ExcludeAnonymousProxy: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, `network,network_start_integer,network_last_integer
+1.0.0.0/24,16777216,16777471
+2001:4220::/32,42541829336310884227257139937291534336,42541829415539046741521477530835484671
+`, outbuf.String())
+
+	for _, opts := range []Options{
+		{OnlyNetwork: true},
+		{OnlyNetwork: true, IPRange: true, RetainNetworkColumn: true},
+		{OnlyNetwork: true, IPRange: true, Locations: Locations{}},
+	} {
+		_, err := NewRowConverter(strings.NewReader(input), opts)
+		assert.Error(t, err)
+	}
+}
+
+func TestRowConverterEmpty(t *testing.T) {
+	rows, err := NewRowConverter(strings.NewReader(""), Options{CIDR: true})
+	require.NoError(t, err)
+
+	assert.Nil(t, rows.Header())
+
+	_, err = rows.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestRowConverterRaggedRows(t *testing.T) {
+	input := `network,geoname_id,registered_country_geoname_id
+1.0.0.0/24,2077456
+2001:4220::/32,357994,357994,
+`
+
+	_, err := Validate(strings.NewReader(input), Options{})
+	require.ErrorContains(t, err, "wrong number of fields")
+
+	rows, err := NewRowConverter(strings.NewReader(input), Options{CIDR: true, AllowRaggedRows: true})
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		[]string{"network", "geoname_id", "registered_country_geoname_id"},
+		rows.Header(),
+	)
+
+	record, err := rows.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0.0/24", "2077456"}, record)
+
+	record, err = rows.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2001:4220::/32", "357994", "357994", ""}, record)
+
+	_, err = rows.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestRowConverterRaggedRowsMissingNetwork(t *testing.T) {
+	input := `geoname_id,network
+2077456
+`
+
+	rows, err := NewRowConverter(
+		strings.NewReader(input),
+		Options{CIDR: true, NetworkColumn: 1, AllowRaggedRows: true},
+	)
+	require.NoError(t, err)
+
+	_, err = rows.Next()
+	require.EqualError(t, err, "record on line 2 has no network column")
+}
+
+func TestRowConverterCommentsAndBlankLines(t *testing.T) {
+	input := `network,description
+# Comment before the first record
+1.0.0.0/24,"first # not a comment"
+
+,
+2001:4220::/32,#also not a comment
+`
+
+	rows, err := NewRowConverter(strings.NewReader(input), Options{CIDR: true, Comment: '#'})
+	require.NoError(t, err)
+
+	record, err := rows.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0.0/24", "first # not a comment"}, record)
+
+	record, err = rows.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2001:4220::/32", "#also not a comment"}, record)
+
+	_, err = rows.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestRowConverterRetainNetworkColumn(t *testing.T) {
+	input := `geoname_id,network,is_anycast
+2077456,1.0.0.0/24,0
+`
+
+	rows, err := NewRowConverter(
+		strings.NewReader(input),
+		Options{IPRange: true, NetworkColumn: 1, RetainNetworkColumn: true},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		[]string{"network_start_ip", "network_last_ip", "geoname_id", "network", "is_anycast"},
+		rows.Header(),
+	)
+
+	record, err := rows.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0.0.0", "1.0.0.255", "2077456", "1.0.0.0/24", "0"}, record)
+
+	_, err = NewRowConverter(
+		strings.NewReader(input),
+		Options{CIDR: true, NetworkColumn: 1, RetainNetworkColumn: true},
+	)
+	require.EqualError(t, err, "the CIDR representation may not be used when retaining the network column")
+}
+
+const limitInput = `network
+1.0.0.0/24
+1.0.1.0/24
+1.0.2.0/23
+1.0.4.0/22
+1.0.8.0/21
+`
+
+func TestRowConverterLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     Options
+		expected []string
+	}{
+		{
+			name:     "limit",
+			input:    limitInput,
+			opts:     Options{CIDR: true, Limit: 2},
+			expected: []string{"1.0.0.0/24", "1.0.1.0/24"},
+		},
+		{
+			name:     "limit larger than input",
+			input:    limitInput,
+			opts:     Options{CIDR: true, Limit: 10},
+			expected: []string{"1.0.0.0/24", "1.0.1.0/24", "1.0.2.0/23", "1.0.4.0/22", "1.0.8.0/21"},
+		},
+		{
+			name:     "rest of input is not read",
+			input:    "network\n1.0.0.0/24\nbad\n",
+			opts:     Options{CIDR: true, Limit: 1},
+			expected: []string{"1.0.0.0/24"},
+		},
+		{
+			name:     "skip",
+			input:    limitInput,
+			opts:     Options{CIDR: true, Skip: 3},
+			expected: []string{"1.0.4.0/22", "1.0.8.0/21"},
+		},
+		{
+			name:     "skip and limit",
+			input:    limitInput,
+			opts:     Options{CIDR: true, Skip: 1, Limit: 2},
+			expected: []string{"1.0.1.0/24", "1.0.2.0/23"},
+		},
+		{
+			name:  "skip past end",
+			input: limitInput,
+			opts:  Options{CIDR: true, Skip: 10},
+		},
+		{
+			name:     "skip after sort",
+			input:    "network\n1.0.8.0/21\n1.0.0.0/24\n1.0.4.0/22\n",
+			opts:     Options{CIDR: true, Skip: 1, Sort: true},
+			expected: []string{"1.0.4.0/22", "1.0.8.0/21"},
+		},
+		{
+			name:     "limit after sort",
+			input:    "network\n1.0.8.0/21\n1.0.0.0/24\n1.0.4.0/22\n",
+			opts:     Options{CIDR: true, Limit: 2, Sort: true},
+			expected: []string{"1.0.0.0/24", "1.0.4.0/22"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, err := NewRowConverter(strings.NewReader(test.input), test.opts)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.expected, readNetworks(t, rows))
+			assert.Equal(t, len(test.expected), rows.Stats().RecordsProcessed)
+		})
+	}
+}
+
+// readNetworks returns the first column of each record returned by `rows`.
+func readNetworks(t *testing.T, rows *RowConverter) []string {
+	var networks []string
+	for {
+		record, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			return networks
+		}
+		require.NoError(t, err)
+		networks = append(networks, record[0])
+	}
+}
+
+// generatedInput returns a block CSV with `n` IPv4 and `n` IPv6 networks.
+func generatedInput(n int) string {
+	var b strings.Builder
+	b.WriteString("network,geoname_id\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "%d.%d.%d.0/24,%d\n", 1+i>>16&0xff, i>>8&0xff, i&0xff, i)
+		fmt.Fprintf(&b, "2001:%x:%x::/48,%d\n", i>>16&0xffff, i&0xffff, i)
+	}
+	return b.String()
+}
+
+func TestRowConverterWorkers(t *testing.T) {
+	input := generatedInput(5000)
+
+	for _, opts := range []Options{
+		{CIDR: true, IntRange: true, HexRange: true},
+		{IPRange: true, Sort: true, SortIPv6First: true},
+		{CIDR: true, SampleEvery: 3, Skip: 10, Limit: 5000},
+	} {
+		var expected bytes.Buffer
+		_, err := ConvertWithOptions(strings.NewReader(input), &expected, opts)
+		require.NoError(t, err)
+
+		for _, workers := range []int{2, 3, 8} {
+			opts.Workers = workers
+
+			var outbuf bytes.Buffer
+			stats, err := ConvertWithOptions(strings.NewReader(input), &outbuf, opts)
+			require.NoError(t, err)
+			assert.Equal(t, expected.String(), outbuf.String(), "workers = %d", workers)
+			assert.Equal(t, strings.Count(expected.String(), "\n")-1, stats.RecordsProcessed)
+		}
+	}
+}
+
+func TestRowConverterWorkersError(t *testing.T) {
+	input := generatedInput(5000) + "bad,1\n"
+
+	_, err := ConvertWithOptions(strings.NewReader(input), io.Discard, Options{CIDR: true, Workers: 4})
+	require.EqualError(
+		t,
+		err,
+		`parsing network on line 10002 (bad): netip.ParsePrefix("bad"): no '/'`,
+	)
+}
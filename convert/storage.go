@@ -0,0 +1,82 @@
+package convert
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Storage reads inputs from and writes outputs to a remote store, e.g., S3.
+// A Storage handles the names with the URL scheme it is registered for with
+// RegisterStorage.
+type Storage interface {
+	// Open opens the object `name` for reading.
+	Open(name string) (io.ReadCloser, error)
+	// Create creates or replaces the object `name`. The object is only
+	// complete once Close returns without an error. If the writer also has a
+	// CloseWithError(error) error method, as *io.PipeWriter does, it is
+	// called instead of Close when the conversion fails so that the partial
+	// object may be discarded.
+	Create(name string) (io.WriteCloser, error)
+}
+
+var (
+	storagesMu sync.RWMutex
+	storages   = map[string]Storage{}
+)
+
+// RegisterStorage makes `storage` handle the input and output names with the
+// URL scheme `scheme`, e.g., "s3" for s3://bucket/key. It is typically called
+// from the init function of the package implementing the Storage. It panics
+// if a Storage is already registered for `scheme`.
+func RegisterStorage(scheme string, storage Storage) {
+	storagesMu.Lock()
+	defer storagesMu.Unlock()
+
+	if _, ok := storages[scheme]; ok {
+		panic("convert: RegisterStorage called twice for scheme " + scheme)
+	}
+	storages[scheme] = storage
+}
+
+// storageFor returns the Storage registered for the URL scheme of `name`, if
+// any.
+func storageFor(name string) (Storage, bool) {
+	scheme, _, ok := strings.Cut(name, "://")
+	if !ok {
+		return nil, false
+	}
+
+	storagesMu.RLock()
+	defer storagesMu.RUnlock()
+
+	storage, ok := storages[scheme]
+	return storage, ok
+}
+
+// writeStorage writes the output of `write` to `outputFile` in `storage`.
+func writeStorage(
+	storage Storage,
+	outputFile string,
+	write func(io.Writer) (Stats, error),
+) (Stats, error) {
+	output, err := storage.Create(outputFile)
+	if err != nil {
+		return Stats{}, fmt.Errorf("creating output file (%s): %w", outputFile, err)
+	}
+
+	stats, err := write(output)
+	if err != nil {
+		if o, ok := output.(interface{ CloseWithError(error) error }); ok {
+			o.CloseWithError(err)
+		} else {
+			output.Close()
+		}
+		return stats, err
+	}
+	if err := output.Close(); err != nil {
+		return stats, fmt.Errorf("writing output file (%s): %w", outputFile, err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,83 @@
+package convert
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStorage is a Storage that keeps its objects in memory.
+type memStorage struct {
+	objects map[string]string
+	aborted []string
+}
+
+func (s *memStorage) Open(name string) (io.ReadCloser, error) {
+	o, ok := s.objects[name]
+	if !ok {
+		return nil, errors.New("no such object")
+	}
+	return io.NopCloser(strings.NewReader(o)), nil
+}
+
+func (s *memStorage) Create(name string) (io.WriteCloser, error) {
+	return &memObject{storage: s, name: name}, nil
+}
+
+type memObject struct {
+	bytes.Buffer
+	storage *memStorage
+	name    string
+}
+
+func (o *memObject) Close() error {
+	o.storage.objects[o.name] = o.String()
+	return nil
+}
+
+func (o *memObject) CloseWithError(error) error {
+	o.storage.aborted = append(o.storage.aborted, o.name)
+	return nil
+}
+
+var testStorage = &memStorage{objects: map[string]string{}}
+
+func init() {
+	RegisterStorage("mem", testStorage)
+}
+
+func TestStorage(t *testing.T) {
+	testStorage.objects["mem://in/blocks.csv"] = ipv4BlocksInput
+	testStorage.objects["mem://in/bad.csv"] = "network\nnot-a-network\n"
+
+	stats, err := ConvertFileWithOptions("mem://in/blocks.csv", "mem://out/blocks.csv", Options{CIDR: true})
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.RecordsProcessed)
+	assert.Equal(t, ipv4BlocksInput, testStorage.objects["mem://out/blocks.csv"])
+
+	_, err = ConvertFileWithOptions("mem://in/bad.csv", "mem://out/bad.csv", Options{CIDR: true})
+	require.Error(t, err)
+	assert.NotContains(t, testStorage.objects, "mem://out/bad.csv")
+	assert.Equal(t, []string{"mem://out/bad.csv"}, testStorage.aborted)
+
+	_, err = ConvertFileWithOptions("mem://in/missing.csv", "mem://out/missing.csv", Options{CIDR: true})
+	assert.EqualError(t, err, "opening input file (mem://in/missing.csv): no such object")
+
+	_, err = ConvertFileWithOptions(
+		"mem://in/blocks.csv",
+		"mem://out/blocks.csv",
+		Options{CIDR: true, NoClobber: true},
+	)
+	assert.EqualError(
+		t,
+		err,
+		"creating output file (mem://out/blocks.csv): NoClobber is not supported by its storage",
+	)
+
+	assert.Panics(t, func() { RegisterStorage("mem", testStorage) })
+}
@@ -0,0 +1,124 @@
+package convert
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultZipMember is the pattern used to find the block file in a zip
+// archive when no member is specified.
+const defaultZipMember = "*Blocks*.csv"
+
+// ConvertZipFileWithOptions converts the member `member` of the zip archive
+// `zipFile`, such as a MaxMind CSV database download, to `outputFile` as
+// specified by `opts`. See OpenZipMember for how the member is selected.
+func ConvertZipFileWithOptions(
+	zipFile string,
+	member string,
+	outputFile string,
+	opts Options,
+) (Stats, error) {
+	return convertToFile(
+		func() (io.ReadCloser, error) { return OpenZipMember(zipFile, member) },
+		zipFile,
+		outputFile,
+		opts,
+	)
+}
+
+// OpenZipMember opens the member of the zip archive `zipFile` matching
+// `member`. `member` may be the exact name of the member or a path.Match
+// pattern matched against either the full name or the base name of each
+// member. If `member` is empty, the archive must contain exactly one member
+// whose base name matches "*Blocks*.csv". Closing the returned ReadCloser
+// also closes the archive.
+func OpenZipMember(zipFile, member string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(filepath.Clean(zipFile))
+	if err != nil {
+		return nil, fmt.Errorf("opening zip file (%s): %w", zipFile, err)
+	}
+
+	f, err := findZipMember(archive.File, member)
+	if err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("finding member in zip file (%s): %w", zipFile, err)
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		archive.Close()
+		return nil, fmt.Errorf("opening zip member (%s): %w", f.Name, err)
+	}
+
+	return &zipMemberReader{ReadCloser: r, archive: archive}, nil
+}
+
+func findZipMember(files []*zip.File, member string) (*zip.File, error) {
+	pattern := member
+	if pattern == "" {
+		pattern = defaultZipMember
+	}
+
+	var names []string
+	var matches []*zip.File
+	for _, f := range files {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		names = append(names, f.Name)
+
+		if f.Name == pattern {
+			return f, nil
+		}
+
+		fullMatch, err := path.Match(pattern, f.Name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid member pattern (%s): %w", pattern, err)
+		}
+		baseMatch, err := path.Match(pattern, path.Base(f.Name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid member pattern (%s): %w", pattern, err)
+		}
+		if fullMatch || baseMatch {
+			matches = append(matches, f)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf(
+			"no member matches %q; members: %s",
+			pattern,
+			strings.Join(names, ", "),
+		)
+	case 1:
+		return matches[0], nil
+	default:
+		matchNames := make([]string, 0, len(matches))
+		for _, f := range matches {
+			matchNames = append(matchNames, f.Name)
+		}
+		return nil, fmt.Errorf(
+			"multiple members match %q: %s",
+			pattern,
+			strings.Join(matchNames, ", "),
+		)
+	}
+}
+
+type zipMemberReader struct {
+	io.ReadCloser
+	archive *zip.ReadCloser
+}
+
+func (r *zipMemberReader) Close() error {
+	err := r.ReadCloser.Close()
+	if archiveErr := r.archive.Close(); err == nil {
+		err = archiveErr
+	}
+	return err
+}
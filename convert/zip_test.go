@@ -0,0 +1,95 @@
+package convert
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeZip(t *testing.T, members map[string]string) string {
+	zipFile := filepath.Join(t.TempDir(), "db.zip")
+
+	f, err := os.Create(zipFile)
+	require.NoError(t, err)
+
+	w := zip.NewWriter(f)
+	for name, contents := range members {
+		mw, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = mw.Write([]byte(contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+	require.NoError(t, f.Close())
+
+	return zipFile
+}
+
+func readZipMember(t *testing.T, zipFile, member string) string {
+	r, err := OpenZipMember(zipFile, member)
+	require.NoError(t, err)
+
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	return string(b)
+}
+
+func TestOpenZipMember(t *testing.T) {
+	zipFile := writeZip(t, map[string]string{
+		"GeoLite2-City-CSV_20240101/GeoLite2-City-Blocks-IPv4.csv":     "ipv4",
+		"GeoLite2-City-CSV_20240101/GeoLite2-City-Blocks-IPv6.csv":     "ipv6",
+		"GeoLite2-City-CSV_20240101/GeoLite2-City-Locations-en.csv":    "locations",
+		"GeoLite2-City-CSV_20240101/COPYRIGHT.txt":                     "copyright",
+		"GeoLite2-City-CSV_20240101/GeoLite2-City-Locations-de.csv":    "locations-de",
+		"GeoLite2-City-CSV_20240101/GeoLite2-City-Locations-pt-BR.csv": "locations-pt-br",
+	})
+
+	assert.Equal(
+		t,
+		"ipv4",
+		readZipMember(t, zipFile, "GeoLite2-City-CSV_20240101/GeoLite2-City-Blocks-IPv4.csv"),
+	)
+	assert.Equal(t, "ipv6", readZipMember(t, zipFile, "*Blocks-IPv6.csv"))
+	assert.Equal(t, "locations", readZipMember(t, zipFile, "*/*-en.csv"))
+
+	_, err := OpenZipMember(zipFile, "")
+	require.ErrorContains(t, err, `multiple members match "*Blocks*.csv"`)
+
+	_, err = OpenZipMember(zipFile, "missing.csv")
+	require.ErrorContains(t, err, `no member matches "missing.csv"; members: `)
+}
+
+func TestOpenZipMemberDefault(t *testing.T) {
+	zipFile := writeZip(t, map[string]string{
+		"GeoLite2-ASN-CSV_20240101/GeoLite2-ASN-Blocks-IPv4.csv": "ipv4",
+		"GeoLite2-ASN-CSV_20240101/COPYRIGHT.txt":                "copyright",
+	})
+
+	assert.Equal(t, "ipv4", readZipMember(t, zipFile, ""))
+}
+
+func TestConvertZipFile(t *testing.T) {
+	zipFile := writeZip(t, map[string]string{
+		"GeoLite2-Country-Blocks-IPv4.csv": "network,geoname_id\n1.0.0.0/24,2077456\n",
+	})
+	outFile := filepath.Join(t.TempDir(), "out.csv")
+
+	stats, err := ConvertZipFileWithOptions(zipFile, "", outFile, Options{IPRange: true})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.RecordsProcessed)
+
+	b, err := os.ReadFile(outFile)
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		"network_start_ip,network_last_ip,geoname_id\n1.0.0.0,1.0.0.255,2077456\n",
+		string(b),
+	)
+}
@@ -4,41 +4,413 @@
 package main
 
 import (
+	"encoding/csv"
 	"flag"
 	"fmt"
+	"io"
+	"log"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/maxmind/geoip2-csv-converter/convert"
 )
 
+// version is set at build time using the linker, e.g.,
+// `-ldflags "-X main.version=1.5.0"`, as GoReleaser does.
+var version string
+
 func main() {
-	input := flag.String("block-file", "", "The path to the block CSV file to use as input (REQUIRED)")
+	// The flags that map directly to an Options field are shared with the
+	// convert package.
+	convert.RegisterFlags(flag.CommandLine)
+
+	var blockFiles filesFlag
+	flag.Var(
+		&blockFiles,
+		"block-file",
+		"The path or glob pattern of the block CSV file to use as input (REQUIRED). "+
+			"May be repeated or a comma-separated list",
+	)
+	zipFile := flag.String("zip-file", "", "The path to a zip archive containing the block CSV file to use as input")
+	zipMember := flag.String(
+		"zip-member",
+		"",
+		"The name or glob pattern of the block CSV file in the -zip-file archive",
+	)
 	output := flag.String("output-file", "", "The path to the output CSV (REQUIRED)")
-	ipRange := flag.Bool("include-range", false, "Include the IP range of the network in string format")
-	intRange := flag.Bool("include-integer-range", false, "Include the IP range of the network in integer format")
-	hexRange := flag.Bool("include-hex-range", false, "Include the IP range of the network in hexadecimal format")
-	cidr := flag.Bool("include-cidr", false, "Include the network in CIDR format")
+	var emits emitsFlag
+	flag.Var(
+		&emits,
+		"emit",
+		"Write an output with its own network representations, e.g., cidr,range:blocks.csv, "+
+			"reading the input once. May be repeated in place of -output-file",
+	)
+	outputDir := flag.String(
+		"output-dir",
+		"",
+		"The directory to write one file per -partition-by value to, in place of -output-file",
+	)
+	locationsFile := flag.String(
+		"locations-file",
+		"",
+		"The path to a Locations CSV file used to add the country_iso_code and country_name columns",
+	)
+	asnFormat := flag.String(
+		"asn-format",
+		"plain",
+		"The format of the autonomous system number: plain, padded, or prefixed",
+	)
+	asnFilter := flag.String(
+		"asn-filter",
+		"",
+		"A comma-separated list of autonomous system numbers to limit the output to",
+	)
+	var within prefixesFlag
+	flag.Var(&within, "within", "Only include networks within this network. May be repeated")
+	withinMode := flag.String(
+		"within-mode",
+		"contained",
+		"Whether -within keeps networks fully contained by (contained) or overlapping (overlap) it",
+	)
+	commentChar := flag.String("comment-char", "", "Skip input lines starting with this character, e.g., #")
+	expectHeader := flag.String(
+		"expect-header",
+		"",
+		"Exit with an error unless the input header is exactly this comma-separated list of columns",
+	)
+	requireColumns := flag.String(
+		"require-columns",
+		"",
+		"Exit with an error unless the input header has these comma-separated columns, in any order",
+	)
+	renames := renamesFlag{}
+	flag.Var(&renames, "rename-column", "Rename an output column, e.g., network_start_ip=ip_lo. May be repeated")
+	columnOrderList := flag.String(
+		"column-order",
+		"",
+		"Write these comma-separated output columns first, in this order, followed by the others",
+	)
+	format := flag.String(
+		"format",
+		"csv",
+		"The output format: csv, tsv-raw, ipset, iptables, nginx-geo, parquet, or sqlite",
+	)
+	rejectFile := flag.String("reject-file", "", "The path to write records skipped by -skip-invalid to")
+	validate := flag.Bool("validate", false, "Check that the block file can be converted without writing any output")
+	checkOverlaps := flag.Bool(
+		"check-overlaps",
+		false,
+		"Report networks in the block file that overlap another network without writing any output",
+	)
+	summarizeCoverage := flag.Bool(
+		"summarize-coverage",
+		false,
+		"Report the address space covered by the block file without writing any output",
+	)
+	prefixHistogram := flag.Bool(
+		"prefix-histogram",
+		false,
+		"Report the number of networks with each prefix length without writing any output",
+	)
+	reportFile := flag.String("report-file", "", "The path to write a summary of the conversion to")
+	reportFormat := flag.String("report-format", "text", "The format of the -report-file: text or json")
+	quiet := flag.Bool("quiet", false, "Print nothing but errors")
+	verbose := flag.Bool(
+		"verbose",
+		false,
+		"Log the progress of the conversion, such as record counts and timing, to stderr",
+	)
+	showVersion := flag.Bool("version", false, "Print the version and exit")
 
 	flag.Parse()
 
-	var errors []string
+	if *showVersion {
+		fmt.Println("geoip2-csv-converter " + versionString())
+		os.Exit(0)
+	}
+
+	opts := convert.OptionsFromFlags(flag.CommandLine)
+	opts.Within = within
+	opts.WithinOverlap = *withinMode == "overlap"
+	opts.AutoDecompress = true
 
-	if *input == "" {
+	errors := setASNOptions(&opts, *asnFormat, *asnFilter)
+	errors = append(errors, setFormatOptions(&opts, *format)...)
+
+	blockFiles, globErr := expandGlobs(blockFiles)
+	if globErr != nil {
+		errors = append(errors, "-block-file: "+globErr.Error())
+	}
+
+	src := source{
+		blockFiles: blockFiles,
+		zipFile:    *zipFile,
+		zipMember:  *zipMember,
+		fileFormat: fileFormats[*format],
+	}
+
+	if src.fileFormat != nil {
+		if len(blockFiles) > 1 {
+			errors = append(errors, "-format "+*format+" may only be used with a single -block-file")
+		}
+		if opts.PartitionBy != "" || isFlagSet("shards") {
+			errors = append(errors, "-format "+*format+" may not be used with -partition-by or -shards")
+		}
+		if opts.Append {
+			errors = append(errors, "-format "+*format+" may not be used with -append")
+		}
+	}
+
+	if opts.Append && (opts.PartitionBy != "" || isFlagSet("shards")) {
+		errors = append(errors, "-append may not be used with -partition-by or -shards")
+	}
+
+	if len(blockFiles) == 0 && *zipFile == "" {
 		errors = append(errors, "-block-file is required")
 	}
 
-	if *output == "" {
+	if len(blockFiles) != 0 && *zipFile != "" {
+		errors = append(errors, "-block-file and -zip-file may not both be set")
+	}
+
+	if *zipMember != "" && *zipFile == "" {
+		errors = append(errors, "-zip-member requires -zip-file")
+	}
+
+	// These modes analyze the input without writing any output.
+	analyzeModes := 0
+	for _, set := range []bool{*validate, *checkOverlaps, *summarizeCoverage, *prefixHistogram} {
+		if set {
+			analyzeModes++
+		}
+	}
+	analyze := analyzeModes > 0
+	analyzeFlags := "-validate, -check-overlaps, -summarize-coverage, or -prefix-histogram"
+
+	if analyzeModes > 1 {
+		errors = append(errors, "only one of "+analyzeFlags+" may be set")
+	}
+
+	if *output == "" && *outputDir == "" && len(emits) == 0 && !analyze {
 		errors = append(errors, "-output-file is required")
 	}
 
-	if *input != "" && *output != "" && *output == *input {
+	if len(emits) > 0 {
+		switch {
+		case *output != "" || *outputDir != "":
+			errors = append(errors, "-emit may not be used with -output-file or -output-dir")
+		case opts.PartitionBy != "" || isFlagSet("shards"):
+			errors = append(errors, "-emit may not be used with -partition-by or -shards")
+		case src.fileFormat != nil:
+			errors = append(errors, "-format "+*format+" may not be used with -emit")
+		case analyze:
+			errors = append(errors, "-emit may not be used with "+analyzeFlags)
+		}
+		specs, err := outputSpecs(emits, opts)
+		if err != nil {
+			errors = append(errors, "-emit: "+err.Error())
+		}
+		for _, spec := range specs {
+			if sameFileAsAny(spec.Path, src.paths()) {
+				errors = append(errors, "Your output file must be different than your block file(input file).")
+				break
+			}
+		}
+	}
+
+	if *output != "" && *outputDir != "" {
+		errors = append(errors, "-output-file and -output-dir may not both be set")
+	}
+
+	if (opts.PartitionBy == "") != (*outputDir == "") {
+		errors = append(errors, "-partition-by and -output-dir must be used together")
+	}
+
+	if opts.PartitionBy != "" {
+		if analyze {
+			errors = append(errors, "-partition-by may not be used with "+analyzeFlags)
+		}
+		if opts.NoTrailingNewline {
+			errors = append(errors, "-no-trailing-newline may not be used with -partition-by")
+		}
+	}
+
+	if isFlagSet("shards") {
+		if opts.Shards < 1 {
+			errors = append(errors, "-shards must be at least 1")
+		}
+		if opts.PartitionBy != "" {
+			errors = append(errors, "-shards and -partition-by may not both be set")
+		}
+		if analyze {
+			errors = append(errors, "-shards may not be used with "+analyzeFlags)
+		}
+		if opts.NoTrailingNewline {
+			errors = append(errors, "-no-trailing-newline may not be used with -shards")
+		}
+		if _, err := convert.ShardPath(*output, 0); *output != "" && err != nil {
+			errors = append(errors, "-output-file must have a single %d verb for the shard number with -shards")
+		}
+	}
+
+	if opts.MaxOpenFiles < 1 {
+		errors = append(errors, "-max-open-files must be at least 1")
+	}
+
+	if *output != "" && sameFileAsAny(*output, src.paths()) {
 		errors = append(errors, "Your output file must be different than your block file(input file).")
 	}
 
-	if !*ipRange && !*intRange && !*cidr && !*hexRange {
+	if *locationsFile != "" && *output != "" && convert.SameFile(*locationsFile, *output) {
+		errors = append(errors, "Your output file must be different than your locations file.")
+	}
+
+	if *withinMode != "contained" && *withinMode != "overlap" {
+		errors = append(errors, "-within-mode must be contained or overlap")
+	}
+
+	if opts.SortIPv6First && !opts.Sort {
+		errors = append(errors, "-sort-ipv6-first requires -sort")
+	}
+
+	if *commentChar != "" {
+		comment := []rune(*commentChar)
+		if len(comment) != 1 || comment[0] == ',' || comment[0] == '"' || unicode.IsSpace(comment[0]) {
+			errors = append(errors, "-comment-char must be a single character other than a comma, quote, or space")
+		} else {
+			opts.Comment = comment[0]
+		}
+	}
+
+	if *expectHeader != "" {
+		header, err := csv.NewReader(strings.NewReader(*expectHeader)).Read()
+		if err != nil {
+			errors = append(errors, "-expect-header: "+err.Error())
+		}
+		opts.ExpectHeader = header
+	}
+
+	if *requireColumns != "" {
+		opts.RequireColumns = strings.Split(*requireColumns, ",")
+	}
+
+	if len(renames) > 0 {
+		opts.RenameColumns = renames
+	}
+
+	if *columnOrderList != "" {
+		opts.ColumnOrder = strings.Split(*columnOrderList, ",")
+	}
+
+	if opts.InputNoHeader && opts.NetworkColumnName != "" {
+		errors = append(errors, "-network-column-name may not be used with -input-no-header")
+	}
+
+	if opts.OnlyNetwork && (opts.RetainNetworkColumn || *locationsFile != "") {
+		errors = append(errors, "-only-network may not be used with -retain-network-column or -locations-file")
+	}
+
+	if opts.OnlyNetwork && !opts.HasRepresentation() {
+		errors = append(errors, "-only-network requires a network representation flag such as -include-cidr")
+	}
+
+	if opts.RetainNetworkColumn && opts.CIDR {
+		errors = append(errors, "-retain-network-column may not be used with -include-cidr")
+	}
+
+	if opts.IPv4OctetsSkipIPv6 && !opts.IPv4Octets {
+		errors = append(errors, "-ipv4-octets-skip-ipv6 requires -ipv4-octets")
+	}
+
+	if opts.IntegerBase < 2 || opts.IntegerBase > 36 {
+		errors = append(errors, "-integer-base must be between 2 and 36")
+	} else if isFlagSet("integer-base") && !opts.IntRange && !opts.IntRangeCombined && !opts.IntegerCIDR {
+		errors = append(
+			errors,
+			"-integer-base requires -include-integer-range, -integer-range-combined, or -include-integer-cidr",
+		)
+	}
+
+	if opts.IntRangeByFamily && !opts.IntRange {
+		errors = append(errors, "-integer-range-by-family requires -include-integer-range")
+	}
+
+	if opts.IPv4Signed && !opts.IPv4Integer32 {
+		errors = append(errors, "-ipv4-signed requires -ipv4-integer32")
+	}
+
+	if opts.BroadcastIPv6 && !opts.Broadcast {
+		errors = append(errors, "-broadcast-ipv6 requires -include-broadcast")
+	}
+
+	if opts.Limit < 0 || opts.Skip < 0 || opts.SampleEvery < 0 {
+		errors = append(errors, "-limit, -skip, and -sample-every must not be negative")
+	}
+
+	if opts.BufferSize < 0 {
+		errors = append(errors, "-buffer-size must not be negative")
+	}
+
+	if opts.Workers < 1 {
+		errors = append(errors, "-workers must be at least 1")
+	}
+
+	if opts.SampleRate < 0 || opts.SampleRate > 1 {
+		errors = append(errors, "-sample-rate must be between 0 and 1")
+	}
+
+	// OptionsFromFlags seeds randomly if -seed is not set.
+	if isFlagSet("seed") && opts.SampleRate == 0 {
+		errors = append(errors, "-seed requires -sample-rate")
+	}
+
+	if *reportFormat != "text" && *reportFormat != "json" {
+		errors = append(errors, "-report-format must be text or json")
+	}
+
+	if *reportFile != "" {
+		if analyze {
+			errors = append(errors, "-report-file may not be used with "+analyzeFlags)
+		}
+		if sameFileAsAny(*reportFile, src.paths()) || *output != "" && convert.SameFile(*reportFile, *output) {
+			errors = append(errors, "Your report file must be different than your block file and output file.")
+		}
+	}
+
+	if *quiet && *verbose {
+		errors = append(errors, "-quiet may not be used with -verbose")
+	}
+	if *verbose {
+		opts.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+
+	if isFlagSet("index-start") && !opts.RowIndex {
+		errors = append(errors, "-index-start requires -include-index")
+	}
+
+	if opts.NetworkColumn < 0 {
+		errors = append(errors, "-network-column must not be negative")
+	}
+
+	if *rejectFile != "" {
+		if !opts.SkipInvalid {
+			errors = append(errors, "-reject-file requires -skip-invalid")
+		}
+		if sameFileAsAny(*rejectFile, src.paths()) || *output != "" && convert.SameFile(*rejectFile, *output) {
+			errors = append(errors, "Your reject file must be different than your block file and output file.")
+		}
+	}
+
+	if !opts.HasRepresentation() && opts.Format.HasColumns() && len(emits) == 0 && !analyze {
 		errors = append(errors, "-include-cidr, -include-range, -include-integer-range,"+
-			" or -include-hex-range is required")
+			" -include-hex-range, or another network representation flag is required")
 	}
 
 	args := flag.Args()
@@ -51,12 +423,601 @@ func main() {
 		os.Exit(1)
 	}
 
-	err := convert.ConvertFile(*input, *output, *cidr, *ipRange, *intRange, *hexRange)
+	if *locationsFile != "" {
+		locations, err := convert.ReadLocationsFile(*locationsFile)
+		if err != nil {
+			//nolint:errcheck // We are exiting and there isn't much we can do.
+			fmt.Fprintf(flag.CommandLine.Output(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.Locations = locations
+	}
+
+	if *validate {
+		count, err := src.validate(opts)
+		if err != nil {
+			//nolint:errcheck // We are exiting and there isn't much we can do.
+			fmt.Fprintf(flag.CommandLine.Output(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if !*quiet {
+			fmt.Printf("%d valid record(s)\n", count)
+		}
+		return
+	}
+
+	if *checkOverlaps {
+		overlaps, err := src.checkOverlaps(opts)
+		if err != nil {
+			//nolint:errcheck // We are exiting and there isn't much we can do.
+			fmt.Fprintf(flag.CommandLine.Output(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, overlap := range overlaps {
+			fmt.Println(overlap)
+		}
+		if len(overlaps) > 0 {
+			//nolint:errcheck // We are exiting and there isn't much we can do.
+			fmt.Fprintf(flag.CommandLine.Output(), "Found %d overlapping network(s)\n", len(overlaps))
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *summarizeCoverage {
+		coverage, err := src.summarizeCoverage(opts)
+		if err == nil {
+			err = coverage.WriteText(os.Stdout)
+		}
+		if err != nil {
+			//nolint:errcheck // We are exiting and there isn't much we can do.
+			fmt.Fprintf(flag.CommandLine.Output(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *prefixHistogram {
+		histogram, err := src.countPrefixLengths(opts)
+		if err == nil {
+			err = histogram.WriteText(os.Stdout)
+		}
+		if err != nil {
+			//nolint:errcheck // We are exiting and there isn't much we can do.
+			fmt.Fprintf(flag.CommandLine.Output(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	target := *output
+	if *outputDir != "" {
+		target = *outputDir
+	}
+	if len(emits) > 0 {
+		// The specs were checked above, and are parsed again now that all the
+		// options, such as the locations, are set.
+		opts.Outputs, _ = outputSpecs(emits, opts)
+
+		paths := make([]string, len(opts.Outputs))
+		for i, spec := range opts.Outputs {
+			paths[i] = spec.Path
+		}
+		target = strings.Join(paths, ",")
+	}
+
+	start := time.Now()
+	stats, err := convertFile(src, target, *rejectFile, opts)
 	if err != nil {
 		//nolint:errcheck // We are exiting and there isn't much we can do.
 		fmt.Fprintf(flag.CommandLine.Output(), "Error: %v\n", err)
 		os.Exit(1)
 	}
+
+	if *reportFile != "" {
+		report := convert.Report{
+			Inputs:  src.paths(),
+			Output:  target,
+			Stats:   stats,
+			Elapsed: time.Since(start),
+		}
+		err = writeReport(*reportFile, *reportFormat, report)
+		if err != nil {
+			//nolint:errcheck // We are exiting and there isn't much we can do.
+			fmt.Fprintf(flag.CommandLine.Output(), "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if stats.SkippedRecords > 0 && !*quiet {
+		printSkipped(stats)
+	}
+}
+
+// prefixesFlag is a flag.Value for a repeatable network flag.
+type prefixesFlag []netip.Prefix
+
+func (f *prefixesFlag) String() string {
+	networks := make([]string, 0, len(*f))
+	for _, p := range *f {
+		networks = append(networks, p.String())
+	}
+	return strings.Join(networks, ", ")
+}
+
+func (f *prefixesFlag) Set(value string) error {
+	p, err := netip.ParsePrefix(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, p)
+	return nil
+}
+
+// filesFlag is a flag.Value for a repeatable flag taking a comma-separated
+// list of files.
+type filesFlag []string
+
+func (f *filesFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *filesFlag) Set(value string) error {
+	for _, file := range strings.Split(value, ",") {
+		if file == "" {
+			return fmt.Errorf("empty file name in %q", value)
+		}
+		*f = append(*f, file)
+	}
+	return nil
+}
+
+// sameFileAsAny reports whether `name` is the same file as any of `names`.
+// See convert.SameFile.
+func sameFileAsAny(name string, names []string) bool {
+	for _, other := range names {
+		if convert.SameFile(name, other) {
+			return true
+		}
+	}
+	return false
+}
+
+// emitsFlag is a flag.Value for the repeatable -emit flag. The values are
+// parsed with outputSpecs once the other flags have been parsed.
+type emitsFlag []string
+
+func (f *emitsFlag) String() string {
+	return strings.Join(*f, " ")
+}
+
+func (f *emitsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// outputSpecs parses the -emit values `emits`, applying the other options
+// in `opts` to each output.
+func outputSpecs(emits []string, opts convert.Options) ([]convert.OutputSpec, error) {
+	specs := make([]convert.OutputSpec, 0, len(emits))
+	for _, emit := range emits {
+		spec, err := convert.ParseOutputSpec(emit, opts)
+		if err != nil {
+			return nil, err
+		}
+		if !spec.Options.HasRepresentation() && spec.Options.Format.HasColumns() {
+			return nil, fmt.Errorf("output %q has no network representation", emit)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// renamesFlag is a flag.Value for a repeatable old=new column rename flag.
+type renamesFlag map[string]string
+
+func (f renamesFlag) String() string {
+	renames := make([]string, 0, len(f))
+	for old, name := range f {
+		renames = append(renames, old+"="+name)
+	}
+	slices.Sort(renames)
+	return strings.Join(renames, ", ")
+}
+
+func (f renamesFlag) Set(value string) error {
+	old, name, ok := strings.Cut(value, "=")
+	if !ok || old == "" || name == "" {
+		return fmt.Errorf("%q is not of the form old=new", value)
+	}
+	if _, ok := f[old]; ok {
+		return fmt.Errorf("column %q is renamed more than once", old)
+	}
+	f[old] = name
+	return nil
+}
+
+// expandGlobs replaces each glob pattern in `files` with the files matching
+// it in sorted order. Other file names and URLs are kept as they are.
+func expandGlobs(files []string) ([]string, error) {
+	var expanded []string
+	for _, file := range files {
+		if strings.Contains(file, "://") || !strings.ContainsAny(file, "*?[") {
+			expanded = append(expanded, file)
+			continue
+		}
+
+		matches, err := filepath.Glob(file)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", file, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %q", file)
+		}
+		slices.Sort(matches)
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// source is the input to convert, either one or more block files or a member
+// of a zip archive.
+type source struct {
+	blockFiles []string
+	zipFile    string
+	zipMember  string
+	// fileFormat, if set, is used to write the output rather than the
+	// convert package.
+	fileFormat fileFormat
+}
+
+// paths returns the paths of the files read by the source.
+func (s source) paths() []string {
+	if s.zipFile != "" {
+		return []string{s.zipFile}
+	}
+	return s.blockFiles
+}
+
+// convert converts the source to `output`, which is the output directory
+// when Options.PartitionBy is set and the output template when
+// Options.Shards is set.
+func (s source) convert(output string, opts convert.Options) (convert.Stats, error) {
+	if s.fileFormat != nil {
+		return s.convertFileFormat(output, opts)
+	}
+	if len(opts.Outputs) > 0 {
+		return s.convertToOutputs(opts)
+	}
+	if opts.PartitionBy != "" {
+		return s.convertPartitioned(output, opts)
+	}
+	if opts.Shards > 0 {
+		return s.convertSharded(output, opts)
+	}
+	if s.zipFile != "" {
+		return convert.ConvertZipFileWithOptions(s.zipFile, s.zipMember, output, opts)
+	}
+	if len(s.blockFiles) == 1 {
+		return convert.ConvertFileWithOptions(s.blockFiles[0], output, opts)
+	}
+	return convert.ConvertFilesWithOptions(s.blockFiles, output, opts)
+}
+
+func (s source) convertPartitioned(outputDir string, opts convert.Options) (convert.Stats, error) {
+	if s.zipFile == "" {
+		return convert.ConvertFilesPartitionedWithOptions(s.blockFiles, outputDir, opts)
+	}
+
+	var stats convert.Stats
+	err := s.each(func(r io.Reader) error {
+		var err error
+		stats, err = convert.ConvertPartitionedWithOptions([]io.Reader{r}, outputDir, opts)
+		return err
+	})
+	return stats, err
+}
+
+// convertToOutputs converts the inputs of the source to each of
+// Options.Outputs.
+func (s source) convertToOutputs(opts convert.Options) (convert.Stats, error) {
+	if s.zipFile == "" {
+		return convert.ConvertFilesToOutputsWithOptions(s.blockFiles, opts)
+	}
+
+	var stats convert.Stats
+	err := s.each(func(r io.Reader) error {
+		var err error
+		stats, err = convert.ConvertToOutputsWithOptions([]io.Reader{r}, opts)
+		return err
+	})
+	return stats, err
+}
+
+// convertFileFormat converts the single input of the source with its
+// fileFormat.
+func (s source) convertFileFormat(output string, opts convert.Options) (convert.Stats, error) {
+	var stats convert.Stats
+	err := s.each(func(r io.Reader) error {
+		var err error
+		stats, err = s.fileFormat(r, output, opts)
+		return err
+	})
+	return stats, err
+}
+
+func (s source) convertSharded(outputTemplate string, opts convert.Options) (convert.Stats, error) {
+	if s.zipFile == "" {
+		return convert.ConvertFilesShardedWithOptions(s.blockFiles, outputTemplate, opts)
+	}
+
+	var stats convert.Stats
+	err := s.each(func(r io.Reader) error {
+		var err error
+		stats, err = convert.ConvertShardedWithOptions([]io.Reader{r}, outputTemplate, opts)
+		return err
+	})
+	return stats, err
+}
+
+// each calls `f` with each input of the source in turn.
+func (s source) each(f func(io.Reader) error) error {
+	if s.zipFile != "" {
+		r, err := convert.OpenZipMember(s.zipFile, s.zipMember)
+		if err != nil {
+			return err
+		}
+		return readAndClose(r, s.zipFile, f)
+	}
+
+	for _, blockFile := range s.blockFiles {
+		r, err := convert.OpenInput(blockFile)
+		if err != nil {
+			return err
+		}
+		err = readAndClose(r, blockFile, f)
+		if err != nil && len(s.blockFiles) > 1 {
+			return fmt.Errorf("%s: %w", blockFile, err)
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readAndClose(r io.ReadCloser, name string, f func(io.Reader) error) error {
+	if err := f(r); err != nil {
+		r.Close()
+		return err
+	}
+	if err := r.Close(); err != nil {
+		return fmt.Errorf("closing file (%s): %w", name, err)
+	}
+	return nil
+}
+
+func (s source) validate(opts convert.Options) (int, error) {
+	total := 0
+	err := s.each(func(r io.Reader) error {
+		count, err := convert.Validate(r, opts)
+		total += count
+		return err
+	})
+	return total, err
+}
+
+// checkOverlaps checks each input of the source for overlapping networks.
+// Networks in different inputs are not compared.
+func (s source) checkOverlaps(opts convert.Options) ([]convert.Overlap, error) {
+	var overlaps []convert.Overlap
+	err := s.each(func(r io.Reader) error {
+		o, err := convert.CheckOverlaps(r, opts)
+		overlaps = append(overlaps, o...)
+		return err
+	})
+	return overlaps, err
+}
+
+// summarizeCoverage summarizes the address space covered by all the inputs
+// of the source.
+func (s source) summarizeCoverage(opts convert.Options) (convert.Coverage, error) {
+	var coverage convert.Coverage
+	err := s.each(func(r io.Reader) error {
+		c, err := convert.SummarizeCoverage(r, opts)
+		if err != nil {
+			return err
+		}
+		coverage, err = coverage.Merge(c)
+		return err
+	})
+	return coverage, err
+}
+
+// countPrefixLengths counts the networks with each prefix length across all
+// the inputs of the source.
+func (s source) countPrefixLengths(opts convert.Options) (convert.PrefixHistogram, error) {
+	var histogram convert.PrefixHistogram
+	err := s.each(func(r io.Reader) error {
+		h, err := convert.CountPrefixLengths(r, opts)
+		histogram = histogram.Merge(h)
+		return err
+	})
+	return histogram, err
+}
+
+func convertFile(
+	src source,
+	output string,
+	rejectFile string,
+	opts convert.Options,
+) (convert.Stats, error) {
+	if rejectFile == "" {
+		return src.convert(output, opts)
+	}
+
+	f, err := os.Create(filepath.Clean(rejectFile))
+	if err != nil {
+		return convert.Stats{}, fmt.Errorf("creating reject file (%s): %w", rejectFile, err)
+	}
+
+	opts.RejectOutput = f
+	stats, err := src.convert(output, opts)
+	if err != nil {
+		f.Close()
+		return stats, err
+	}
+
+	if err := f.Close(); err != nil {
+		return stats, fmt.Errorf("closing reject file (%s): %w", rejectFile, err)
+	}
+	return stats, nil
+}
+
+// writeReport writes `report` to `reportFile` in `format`, text or json.
+func writeReport(reportFile, format string, report convert.Report) error {
+	f, err := os.Create(filepath.Clean(reportFile))
+	if err != nil {
+		return fmt.Errorf("creating report file (%s): %w", reportFile, err)
+	}
+
+	if format == "json" {
+		err = report.WriteJSON(f)
+	} else {
+		err = report.WriteText(f)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing report file (%s): %w", reportFile, err)
+	}
+	return nil
+}
+
+// isFlagSet returns true if the flag `name` was set on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// versionString returns the version set by the linker or, if it was not set,
+// the module version from the build info. The latter is available when the
+// program is installed with `go install`.
+func versionString() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// setASNOptions sets the ASN options in `opts` from the flag values,
+// returning any errors.
+func setASNOptions(opts *convert.Options, format, filter string) []string {
+	var errors []string
+
+	var err error
+	opts.ASNFormat, err = convert.ParseASNFormat(format)
+	if err != nil {
+		errors = append(errors, "-asn-format: "+err.Error())
+	}
+
+	opts.ASNFilter, err = parseASNs(filter)
+	if err != nil {
+		errors = append(errors, "-asn-filter: "+err.Error())
+	}
+
+	return errors
+}
+
+// fileFormat converts `input` to `outputFile` in an output format provided
+// by a separate package.
+type fileFormat func(input io.Reader, outputFile string, opts convert.Options) (convert.Stats, error)
+
+// fileFormats are the output formats provided by separate packages, keyed by
+// the -format name. They are added by the files built with the build tag of
+// the same name, e.g., parquet.go.
+var fileFormats = map[string]fileFormat{}
+
+// optionalFormats are the -format names of the fileFormats, which are only
+// available when built with the corresponding build tag.
+var optionalFormats = []string{"parquet", "sqlite"}
+
+// setFormatOptions sets the output format options in `opts` from the flag
+// values, returning any errors.
+func setFormatOptions(opts *convert.Options, format string) []string {
+	var errors []string
+
+	if _, ok := fileFormats[format]; ok {
+		opts.Format = convert.OutputFormatCSV
+		return nil
+	}
+	if slices.Contains(optionalFormats, format) {
+		return []string{"-format " + format + " requires a binary built with -tags " + format}
+	}
+
+	var err error
+	opts.Format, err = convert.ParseOutputFormat(format)
+	if err != nil {
+		errors = append(errors, "-format: "+err.Error())
+	}
+
+	if opts.Format == convert.OutputFormatIPSet && opts.IPSetName == "" {
+		errors = append(errors, "-ipset-name must not be empty")
+	}
+
+	if opts.Format == convert.OutputFormatIPTables && (opts.IPTablesChain == "" || opts.IPTablesTarget == "") {
+		errors = append(errors, "-iptables-chain and -iptables-target must not be empty")
+	}
+
+	if opts.Format == convert.OutputFormatNginxGeo && opts.ValueColumn == "" {
+		errors = append(errors, "-format nginx-geo requires -value-column")
+	}
+
+	return errors
+}
+
+func parseASNs(list string) ([]uint32, error) {
+	if list == "" {
+		return nil, nil
+	}
+
+	var asns []uint32
+	for _, s := range strings.Split(list, ",") {
+		asn, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(s), "AS"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid autonomous system number %q", s)
+		}
+		asns = append(asns, uint32(asn))
+	}
+	return asns, nil
+}
+
+func printSkipped(stats convert.Stats) {
+	lines := make([]string, 0, len(stats.SkippedLines))
+	for _, line := range stats.SkippedLines {
+		lines = append(lines, strconv.Itoa(line))
+	}
+	if stats.SkippedRecords > len(stats.SkippedLines) {
+		lines = append(lines, "...")
+	}
+
+	//nolint:errcheck // There isn't much to do if we can't print to the output.
+	fmt.Fprintf(
+		flag.CommandLine.Output(),
+		"Skipped %d invalid record(s) on line(s): %s\n",
+		stats.SkippedRecords,
+		strings.Join(lines, ", "),
+	)
 }
 
 func printHelp(errors []string) {
@@ -0,0 +1,19 @@
+//go:build parquet
+
+package main
+
+import (
+	"io"
+
+	"github.com/maxmind/geoip2-csv-converter/convert"
+	"github.com/maxmind/geoip2-csv-converter/parquetoutput"
+)
+
+// Building with `-tags parquet` adds the parquet output format.
+func init() {
+	fileFormats["parquet"] = func(input io.Reader, outputFile string, opts convert.Options) (convert.Stats, error) {
+		return convert.WriteOutputFile(outputFile, opts, func(output io.Writer) (convert.Stats, error) {
+			return parquetoutput.Convert(input, output, opts)
+		})
+	}
+}
@@ -0,0 +1,228 @@
+// Package parquetoutput writes the records converted by the convert package
+// as Apache Parquet, e.g., for loading into a data lake. It is a separate
+// package so that programs that do not write Parquet do not depend on a
+// Parquet library.
+//
+// The schema is derived from the output header. The columns are written in
+// the same order as with CSV. The known numeric columns, such as
+// network_start_ipv4_integer and geoname_id, are optional integers or
+// doubles, with an empty value written as null. The is_* flag columns are
+// optional booleans. All other columns, including the integer range, whose
+// IPv6 values do not fit a Parquet integer or decimal, are strings.
+package parquetoutput
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	pwriter "github.com/xitongsys/parquet-go/writer"
+
+	"github.com/maxmind/geoip2-csv-converter/convert"
+)
+
+// rowGroupSize is the approximate size in bytes of the records buffered in
+// memory before they are written to the output as a row group.
+const rowGroupSize = 64 * 1024 * 1024
+
+// columnType is the Parquet type of an output column.
+type columnType int
+
+const (
+	stringColumn columnType = iota
+	int32Column
+	int64Column
+	float64Column
+	boolColumn
+)
+
+// columnTypes are the types of the output columns that are not strings,
+// keyed by column name. Columns renamed with Options.RenameColumns are
+// strings.
+var columnTypes = map[string]columnType{
+	"row_index":                      int64Column,
+	"network_version":                int32Column,
+	"network_start_ipv4_integer":     int64Column,
+	"network_last_ipv4_integer":      int64Column,
+	"prefix_length":                  int32Column,
+	"octet1":                         int32Column,
+	"octet2":                         int32Column,
+	"octet3":                         int32Column,
+	"octet4":                         int32Column,
+	"geoname_id":                     int64Column,
+	"registered_country_geoname_id":  int64Column,
+	"represented_country_geoname_id": int64Column,
+	"autonomous_system_number":       int64Column,
+	"accuracy_radius":                int32Column,
+	"latitude":                       float64Column,
+	"longitude":                      float64Column,
+	"is_anonymous_proxy":             boolColumn,
+	"is_satellite_provider":          boolColumn,
+	"is_anycast":                     boolColumn,
+}
+
+// Convert converts the MaxMind GeoIP2 or GeoLite2 CSV read from `input` as
+// specified by `opts` and writes it to `output` as Parquet. Options.Format
+// must be convert.OutputFormatCSV. Nothing is written if the input is
+// completely empty.
+func Convert(input io.Reader, output io.Writer, opts convert.Options) (convert.Stats, error) {
+	if opts.Format != convert.OutputFormatCSV {
+		return convert.Stats{}, fmt.Errorf("the %s format may not be used with Parquet", opts.Format)
+	}
+
+	rows, err := convert.NewRowConverter(input, opts)
+	if err != nil {
+		return convert.Stats{}, err
+	}
+
+	err = Write(output, rows)
+	return rows.Stats(), err
+}
+
+// ConvertFile converts `inputFile` to `outputFile` as Parquet. See Convert.
+// The input may be an HTTP(S) URL. See convert.OpenInput.
+func ConvertFile(inputFile, outputFile string, opts convert.Options) (convert.Stats, error) {
+	return convert.WriteOutputFile(outputFile, opts, func(output io.Writer) (convert.Stats, error) {
+		input, err := convert.OpenInput(inputFile)
+		if err != nil {
+			return convert.Stats{}, err
+		}
+
+		stats, err := Convert(input, output, opts)
+		if err != nil {
+			input.Close()
+			return stats, err
+		}
+		if err := input.Close(); err != nil {
+			return stats, fmt.Errorf("closing file (%s): %w", inputFile, err)
+		}
+		return stats, nil
+	})
+}
+
+// Write writes the records returned by `rows` to `output` as Parquet.
+func Write(output io.Writer, rows *convert.RowConverter) error {
+	header := rows.Header()
+	if header == nil {
+		return nil
+	}
+
+	s, err := newSchema(header)
+	if err != nil {
+		return err
+	}
+
+	writer, err := pwriter.NewCSVWriterFromWriter(s.metadata(), output, 1)
+	if err != nil {
+		return fmt.Errorf("writing Parquet: %w", err)
+	}
+	writer.RowGroupSize = rowGroupSize
+
+	for {
+		record, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		// The writer holds on to the values until the row group is written.
+		values := make([]any, len(header))
+		err = s.parse(record, values)
+		if err != nil {
+			return err
+		}
+
+		err = writer.Write(values)
+		if err != nil {
+			return fmt.Errorf("writing Parquet: %w", err)
+		}
+	}
+
+	if err := writer.WriteStop(); err != nil {
+		return fmt.Errorf("writing Parquet: %w", err)
+	}
+	return nil
+}
+
+// schema is the Parquet schema for an output header.
+type schema struct {
+	header []string
+	types  []columnType
+}
+
+func newSchema(header []string) (*schema, error) {
+	s := &schema{header: header, types: make([]columnType, len(header))}
+	for i, name := range header {
+		// These characters have a special meaning in the metadata used to
+		// define the schema.
+		if name == "" || strings.ContainsAny(name, ",=. ") {
+			return nil, fmt.Errorf("column name %q may not be used with Parquet", name)
+		}
+		s.types[i] = columnTypes[name]
+	}
+	return s, nil
+}
+
+// metadata returns the schema in the form used by the CSV writer of
+// github.com/xitongsys/parquet-go.
+func (s *schema) metadata() []string {
+	md := make([]string, len(s.header))
+	for i, name := range s.header {
+		var t string
+		switch s.types[i] {
+		case int32Column:
+			t = "type=INT32, repetitiontype=OPTIONAL"
+		case int64Column:
+			t = "type=INT64, repetitiontype=OPTIONAL"
+		case float64Column:
+			t = "type=DOUBLE, repetitiontype=OPTIONAL"
+		case boolColumn:
+			t = "type=BOOLEAN, repetitiontype=OPTIONAL"
+		default:
+			t = "type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=REQUIRED"
+		}
+		md[i] = "name=" + name + ", " + t
+	}
+	return md
+}
+
+// parse sets `values` to the values of `record` as the Go types expected by
+// the Parquet writer. Empty values of columns other than strings are nil.
+func (s *schema) parse(record []string, values []any) error {
+	for i, t := range s.types {
+		var value string
+		if i < len(record) {
+			value = record[i]
+		}
+
+		if t == stringColumn {
+			values[i] = value
+			continue
+		}
+		if value == "" {
+			values[i] = nil
+			continue
+		}
+
+		var err error
+		switch t {
+		case int32Column:
+			var n int64
+			n, err = strconv.ParseInt(value, 10, 32)
+			values[i] = int32(n)
+		case int64Column:
+			values[i], err = strconv.ParseInt(value, 10, 64)
+		case float64Column:
+			values[i], err = strconv.ParseFloat(value, 64)
+		case boolColumn:
+			values[i], err = strconv.ParseBool(value)
+		}
+		if err != nil {
+			return fmt.Errorf("parsing %s column value %q: %w", s.header[i], value, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package parquetoutput
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+
+	"github.com/maxmind/geoip2-csv-converter/convert"
+)
+
+const input = `network,geoname_id,is_anonymous_proxy,postal_code,latitude
+1.0.0.0/24,2077456,0,3000,-37.8159
+2001:4220::/32,,1,,
+`
+
+type row struct {
+	Network          string   `parquet:"name=network, type=BYTE_ARRAY, convertedtype=UTF8"`
+	StartIPv4Integer *int64   `parquet:"name=network_start_ipv4_integer, type=INT64"`
+	LastIPv4Integer  *int64   `parquet:"name=network_last_ipv4_integer, type=INT64"`
+	GeonameID        *int64   `parquet:"name=geoname_id, type=INT64"`
+	IsAnonymousProxy *bool    `parquet:"name=is_anonymous_proxy, type=BOOLEAN"`
+	PostalCode       string   `parquet:"name=postal_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Latitude         *float64 `parquet:"name=latitude, type=DOUBLE"`
+}
+
+// readParquet returns the column names and rows of the Parquet file `b`.
+func readParquet(t *testing.T, b []byte) ([]string, []row) {
+	file, err := buffer.NewBufferFile(b)
+	require.NoError(t, err)
+
+	r, err := reader.NewParquetReader(file, new(row), 1)
+	require.NoError(t, err)
+	defer r.ReadStop()
+
+	// The reader renames the schema after the struct fields, so the names in
+	// the file are the external names.
+	var columns []string
+	for _, info := range r.SchemaHandler.Infos[1:] {
+		columns = append(columns, info.ExName)
+	}
+
+	rows := make([]row, r.GetNumRows())
+	require.NoError(t, r.Read(&rows))
+	return columns, rows
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestConvert(t *testing.T) {
+	var output bytes.Buffer
+	stats, err := Convert(
+		strings.NewReader(input),
+		&output,
+		convert.Options{CIDR: true, IPv4Integer32: true},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.RecordsProcessed)
+
+	columns, rows := readParquet(t, output.Bytes())
+	// The columns are in the same order as with CSV.
+	assert.Equal(
+		t,
+		[]string{
+			"network",
+			"network_start_ipv4_integer",
+			"network_last_ipv4_integer",
+			"geoname_id",
+			"is_anonymous_proxy",
+			"postal_code",
+			"latitude",
+		},
+		columns,
+	)
+
+	assert.Equal(t, []row{
+		{
+			Network:          "1.0.0.0/24",
+			StartIPv4Integer: ptr(int64(16777216)),
+			LastIPv4Integer:  ptr(int64(16777471)),
+			GeonameID:        ptr(int64(2077456)),
+			IsAnonymousProxy: ptr(false),
+			PostalCode:       "3000",
+			Latitude:         ptr(-37.8159),
+		},
+		{
+			Network:          "2001:4220::/32",
+			IsAnonymousProxy: ptr(true),
+		},
+	}, rows)
+}
+
+func TestConvertFile(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "blocks.csv")
+	require.NoError(t, os.WriteFile(inputFile, []byte(input), 0o600))
+
+	outputFile := filepath.Join(dir, "blocks.parquet")
+	_, err := ConvertFile(inputFile, outputFile, convert.Options{CIDR: true, IPv4Integer32: true})
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	_, rows := readParquet(t, b)
+	assert.Len(t, rows, 2)
+}
+
+func TestConvertErrors(t *testing.T) {
+	_, err := Convert(
+		strings.NewReader("network,geoname_id\n1.0.0.0/24,abc\n"),
+		&bytes.Buffer{},
+		convert.Options{CIDR: true},
+	)
+	assert.EqualError(
+		t,
+		err,
+		`parsing geoname_id column value "abc": strconv.ParseInt: parsing "abc": invalid syntax`,
+	)
+
+	_, err = Convert(
+		strings.NewReader(input),
+		&bytes.Buffer{},
+		convert.Options{CIDR: true, Format: convert.OutputFormatIPSet, IPSetName: "geoip"},
+	)
+	assert.EqualError(t, err, "the ipset format may not be used with Parquet")
+
+	var output bytes.Buffer
+	_, err = Convert(strings.NewReader(""), &output, convert.Options{CIDR: true})
+	require.NoError(t, err)
+	assert.Zero(t, output.Len())
+}
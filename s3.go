@@ -0,0 +1,7 @@
+//go:build s3
+
+package main
+
+// Building with `-tags s3` adds support for s3://bucket/key block files and
+// output files.
+import _ "github.com/maxmind/geoip2-csv-converter/s3storage"
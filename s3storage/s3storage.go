@@ -0,0 +1,149 @@
+// Package s3storage lets the convert package read inputs from and write
+// outputs to Amazon S3 using s3://bucket/key names. Importing the package
+// registers the Storage for the "s3" scheme:
+//
+//	import _ "github.com/maxmind/geoip2-csv-converter/s3storage"
+//
+// Credentials and the region are loaded from the standard AWS chain, i.e.,
+// the environment, the shared configuration files, and the container or
+// instance role.
+package s3storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/maxmind/geoip2-csv-converter/convert"
+)
+
+func init() {
+	convert.RegisterStorage("s3", &Storage{})
+}
+
+var _ convert.Storage = (*Storage)(nil)
+
+// Storage is a convert.Storage for S3. The zero value loads its client from
+// the default AWS configuration the first time it is used.
+type Storage struct {
+	// Client is the S3 client to use. If nil, a client is created from the
+	// default AWS configuration.
+	Client *s3.Client
+
+	once    sync.Once
+	client  *s3.Client
+	initErr error
+}
+
+// Open opens the object named by the s3://bucket/key URL `name` for reading.
+// The object is streamed from S3 as it is read.
+func (s *Storage) Open(name string) (io.ReadCloser, error) {
+	bucket, key, err := parseURL(name)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.s3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting S3 object: %w", err)
+	}
+	return out.Body, nil
+}
+
+// Create creates or replaces the object named by the s3://bucket/key URL
+// `name`. The data written is uploaded as it is written, using a multipart
+// upload for large objects. The object is complete once Close returns
+// without an error. CloseWithError aborts the upload.
+func (s *Storage) Create(name string) (io.WriteCloser, error) {
+	bucket, key, err := parseURL(name)
+	if err != nil {
+		return nil, err
+	}
+	client, err := s.s3Client()
+	if err != nil {
+		return nil, err
+	}
+
+	r, w := io.Pipe()
+	u := &upload{PipeWriter: w, done: make(chan error, 1)}
+	go func() {
+		_, err := manager.NewUploader(client).Upload(context.Background(), &s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   r,
+		})
+		// Unblock any pending writes if the upload failed.
+		r.CloseWithError(err)
+		u.done <- err
+	}()
+	return u, nil
+}
+
+func (s *Storage) s3Client() (*s3.Client, error) {
+	if s.Client != nil {
+		return s.Client, nil
+	}
+
+	s.once.Do(func() {
+		cfg, err := config.LoadDefaultConfig(context.Background())
+		if err != nil {
+			s.initErr = fmt.Errorf("loading AWS configuration: %w", err)
+			return
+		}
+		s.client = s3.NewFromConfig(cfg)
+	})
+	return s.client, s.initErr
+}
+
+// upload is the writer returned by Create.
+type upload struct {
+	*io.PipeWriter
+	done chan error
+}
+
+// Close finishes the upload and waits for it to complete.
+func (u *upload) Close() error {
+	return u.CloseWithError(nil)
+}
+
+// CloseWithError aborts the upload with `err`, or finishes it if `err` is
+// nil, and waits for the upload to return.
+func (u *upload) CloseWithError(err error) error {
+	u.PipeWriter.CloseWithError(err)
+	uploadErr := <-u.done
+	if uploadErr != nil {
+		return fmt.Errorf("uploading S3 object: %w", uploadErr)
+	}
+	return nil
+}
+
+// parseURL returns the bucket and key of the s3://bucket/key URL `name`.
+func parseURL(name string) (bucket, key string, err error) {
+	path, ok := strings.CutPrefix(name, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("%q is not an s3:// URL", name)
+	}
+	bucket, key, _ = strings.Cut(path, "/")
+	if bucket == "" {
+		return "", "", errors.New("no bucket in S3 URL")
+	}
+	if key == "" {
+		return "", "", errors.New("no key in S3 URL")
+	}
+	return bucket, key, nil
+}
@@ -0,0 +1,25 @@
+package s3storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseURL(t *testing.T) {
+	bucket, key, err := parseURL("s3://geoip/GeoLite2-City-CSV/blocks.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "geoip", bucket)
+	assert.Equal(t, "GeoLite2-City-CSV/blocks.csv", key)
+
+	for name, expected := range map[string]string{
+		"http://geoip/blocks.csv": `"http://geoip/blocks.csv" is not an s3:// URL`,
+		"s3:///blocks.csv":        "no bucket in S3 URL",
+		"s3://geoip":              "no key in S3 URL",
+		"s3://geoip/":             "no key in S3 URL",
+	} {
+		_, _, err := parseURL(name)
+		assert.EqualError(t, err, expected, name)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build sqlite
+
+package main
+
+import (
+	"flag"
+	"io"
+
+	"github.com/maxmind/geoip2-csv-converter/convert"
+	"github.com/maxmind/geoip2-csv-converter/sqliteoutput"
+)
+
+var (
+	sqliteTable = flag.String("sqlite-table", sqliteoutput.DefaultTable, "The table written with -format sqlite")
+	sqliteIndex = flag.String(
+		"sqlite-index",
+		"",
+		"The column indexed with -format sqlite, or none (default the first network_start_* column)",
+	)
+)
+
+// Building with `-tags sqlite` adds the sqlite output format.
+func init() {
+	fileFormats["sqlite"] = func(input io.Reader, outputFile string, opts convert.Options) (convert.Stats, error) {
+		return sqliteoutput.Convert(input, outputFile, opts, sqliteoutput.TableOptions{
+			Table: *sqliteTable,
+			Index: *sqliteIndex,
+		})
+	}
+}
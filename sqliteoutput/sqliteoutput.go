@@ -0,0 +1,372 @@
+// Package sqliteoutput writes the records converted by the convert package
+// to a table in a SQLite database, e.g., for range queries without a
+// database server. It is a separate package so that programs that do not
+// write SQLite do not depend on a SQLite driver.
+//
+// The table has the same columns as the CSV output, in the same order. The
+// known numeric columns, such as network_start_ipv4_integer and geoname_id,
+// are INTEGER or REAL columns, with an empty value stored as NULL. The
+// integer range columns have no declared type: IPv4 values are stored as
+// integers, but IPv6 values, which do not fit in SQLite's 64-bit integers,
+// are stored as text. All other columns are TEXT.
+package sqliteoutput
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	// The pure Go driver is used so that building does not require cgo.
+	_ "modernc.org/sqlite"
+
+	"github.com/maxmind/geoip2-csv-converter/convert"
+)
+
+// DefaultTable is the name of the table used when TableOptions.Table is
+// not set.
+const DefaultTable = "networks"
+
+// NoIndex is the TableOptions.Index value for not creating an index.
+const NoIndex = "none"
+
+// TableOptions are the options for the table the records are written to.
+type TableOptions struct {
+	// Table is the name of the table, which must not already exist. It
+	// defaults to DefaultTable.
+	Table string
+	// Index is the column an index is created on, e.g., for range queries.
+	// It defaults to the first column starting with "network_start_", or to
+	// "network" if there is none. No index is created if it is NoIndex.
+	Index string
+}
+
+func (t TableOptions) table() string {
+	if t.Table == "" {
+		return DefaultTable
+	}
+	return t.Table
+}
+
+// columnType is the SQLite type of an output column.
+type columnType int
+
+const (
+	textColumn columnType = iota
+	integerColumn
+	realColumn
+	// integerOrTextColumn is stored as an integer if it fits and as text
+	// otherwise.
+	integerOrTextColumn
+)
+
+// columnTypes are the types of the output columns that are not TEXT, keyed
+// by column name. Columns renamed with Options.RenameColumns are TEXT.
+var columnTypes = map[string]columnType{
+	"row_index":                      integerColumn,
+	"network_version":                integerColumn,
+	"network_start_integer":          integerOrTextColumn,
+	"network_last_integer":           integerOrTextColumn,
+	"network_start_integer_v4":       integerOrTextColumn,
+	"network_last_integer_v4":        integerOrTextColumn,
+	"prefix_length":                  integerColumn,
+	"network_address_count":          integerOrTextColumn,
+	"network_start_ipv4_integer":     integerColumn,
+	"network_last_ipv4_integer":      integerColumn,
+	"network_ipv4_key":               integerOrTextColumn,
+	"octet1":                         integerColumn,
+	"octet2":                         integerColumn,
+	"octet3":                         integerColumn,
+	"octet4":                         integerColumn,
+	"geoname_id":                     integerColumn,
+	"registered_country_geoname_id":  integerColumn,
+	"represented_country_geoname_id": integerColumn,
+	"autonomous_system_number":       integerColumn,
+	"accuracy_radius":                integerColumn,
+	"latitude":                       realColumn,
+	"longitude":                      realColumn,
+	"is_anonymous_proxy":             integerColumn,
+	"is_satellite_provider":          integerColumn,
+	"is_anycast":                     integerColumn,
+}
+
+// Convert converts the MaxMind GeoIP2 or GeoLite2 CSV read from `input` as
+// specified by `opts` and writes it to a new SQLite database at
+// `databaseFile`. Options.Format must be convert.OutputFormatCSV. An
+// existing file is replaced unless Options.NoClobber is set, in which case
+// it is an error. Options.NoSync turns off SQLite's syncing. No database is
+// created if the input is completely empty.
+func Convert(
+	input io.Reader,
+	databaseFile string,
+	opts convert.Options,
+	table TableOptions,
+) (convert.Stats, error) {
+	if opts.Format != convert.OutputFormatCSV {
+		return convert.Stats{}, fmt.Errorf("the %s format may not be used with SQLite", opts.Format)
+	}
+
+	rows, err := convert.NewRowConverter(input, opts)
+	if err != nil {
+		return convert.Stats{}, err
+	}
+
+	err = writeFile(databaseFile, rows, opts, table)
+	return rows.Stats(), err
+}
+
+// ConvertFile converts `inputFile` to a SQLite database at `databaseFile`.
+// See Convert. The input may be an HTTP(S) URL. See convert.OpenInput.
+func ConvertFile(
+	inputFile, databaseFile string,
+	opts convert.Options,
+	table TableOptions,
+) (convert.Stats, error) {
+	input, err := convert.OpenInput(inputFile)
+	if err != nil {
+		return convert.Stats{}, err
+	}
+
+	stats, err := Convert(input, databaseFile, opts, table)
+	if err != nil {
+		input.Close()
+		return stats, err
+	}
+	if err := input.Close(); err != nil {
+		return stats, fmt.Errorf("closing file (%s): %w", inputFile, err)
+	}
+	return stats, nil
+}
+
+func writeFile(
+	databaseFile string,
+	rows *convert.RowConverter,
+	opts convert.Options,
+	table TableOptions,
+) error {
+	// SQLite would otherwise add the table to an existing database.
+	if opts.NoClobber {
+		if _, err := os.Stat(databaseFile); err == nil {
+			return fmt.Errorf("creating output file (%s): %w", databaseFile, os.ErrExist)
+		}
+	} else if err := os.Remove(databaseFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing output file (%s): %w", databaseFile, err)
+	}
+
+	if rows.Header() == nil {
+		return nil
+	}
+
+	db, err := sql.Open("sqlite", databaseFile)
+	if err != nil {
+		return fmt.Errorf("opening SQLite database (%s): %w", databaseFile, err)
+	}
+
+	if opts.NoSync {
+		// The pragma only applies to the connection it is run on.
+		db.SetMaxOpenConns(1)
+		if _, err := db.Exec("PRAGMA synchronous = OFF"); err != nil {
+			db.Close()
+			return fmt.Errorf("opening SQLite database (%s): %w", databaseFile, err)
+		}
+	}
+
+	err = Write(db, rows, table)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("closing SQLite database (%s): %w", databaseFile, err)
+	}
+	return nil
+}
+
+// Write creates a table in `db` and writes the records returned by `rows`
+// to it. The records are inserted in a single transaction, and the index is
+// created after they have been inserted.
+func Write(db *sql.DB, rows *convert.RowConverter, table TableOptions) error {
+	header := rows.Header()
+	if header == nil {
+		return nil
+	}
+
+	s, err := newSchema(header, table)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("writing SQLite: %w", err)
+	}
+
+	err = s.write(tx, rows)
+	if err != nil {
+		//nolint:errcheck // The error writing the records is more useful.
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("writing SQLite: %w", err)
+	}
+	return nil
+}
+
+// schema is the SQLite table for an output header.
+type schema struct {
+	table  string
+	header []string
+	types  []columnType
+	// index is the indexed column, or "" for none.
+	index string
+}
+
+func newSchema(header []string, table TableOptions) (*schema, error) {
+	s := &schema{
+		table:  table.table(),
+		header: header,
+		types:  make([]columnType, len(header)),
+		index:  table.Index,
+	}
+	for i, name := range header {
+		s.types[i] = columnTypes[name]
+	}
+
+	switch s.index {
+	case NoIndex:
+		s.index = ""
+	case "":
+		s.index = defaultIndex(header)
+	default:
+		if !slices.Contains(header, s.index) {
+			return nil, fmt.Errorf("index column %q not found in header", s.index)
+		}
+	}
+	return s, nil
+}
+
+// defaultIndex returns the column indexed when TableOptions.Index is not
+// set.
+func defaultIndex(header []string) string {
+	for _, name := range header {
+		if strings.HasPrefix(name, "network_start_") {
+			return name
+		}
+	}
+	for _, name := range header {
+		if name == "network" {
+			return name
+		}
+	}
+	return ""
+}
+
+func (s *schema) write(tx *sql.Tx, rows *convert.RowConverter) error {
+	columns := make([]string, len(s.header))
+	placeholders := make([]string, len(s.header))
+	for i, name := range s.header {
+		columns[i] = quote(name)
+		switch s.types[i] {
+		case integerColumn:
+			columns[i] += " INTEGER"
+		case realColumn:
+			columns[i] += " REAL"
+		case textColumn:
+			columns[i] += " TEXT"
+		case integerOrTextColumn:
+			// A declared type would convert the text values that are too large
+			// for an integer to REAL, losing precision.
+		}
+		placeholders[i] = "?"
+	}
+
+	_, err := tx.Exec("CREATE TABLE " + quote(s.table) + " (" + strings.Join(columns, ", ") + ")")
+	if err != nil {
+		return fmt.Errorf("creating SQLite table %q: %w", s.table, err)
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO " + quote(s.table) + " VALUES (" + strings.Join(placeholders, ", ") + ")")
+	if err != nil {
+		return fmt.Errorf("writing SQLite: %w", err)
+	}
+	defer stmt.Close()
+
+	values := make([]any, len(s.header))
+	for {
+		record, err := rows.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		err = s.parse(record, values)
+		if err != nil {
+			return err
+		}
+
+		_, err = stmt.Exec(values...)
+		if err != nil {
+			return fmt.Errorf("writing SQLite: %w", err)
+		}
+	}
+
+	if s.index != "" {
+		index := s.table + "_" + s.index + "_idx"
+		_, err = tx.Exec("CREATE INDEX " + quote(index) + " ON " + quote(s.table) + " (" + quote(s.index) + ")")
+		if err != nil {
+			return fmt.Errorf("creating SQLite index %q: %w", index, err)
+		}
+	}
+	return nil
+}
+
+// parse sets `values` to the values of `record` as the Go types bound to
+// the insert statement. Empty values of columns other than TEXT are nil.
+func (s *schema) parse(record []string, values []any) error {
+	for i, t := range s.types {
+		var value string
+		if i < len(record) {
+			value = record[i]
+		}
+
+		if t == textColumn {
+			values[i] = value
+			continue
+		}
+		if value == "" {
+			values[i] = nil
+			continue
+		}
+
+		var err error
+		switch t {
+		case integerColumn:
+			values[i], err = strconv.ParseInt(value, 10, 64)
+		case realColumn:
+			values[i], err = strconv.ParseFloat(value, 64)
+		case integerOrTextColumn:
+			n, parseErr := strconv.ParseInt(value, 10, 64)
+			if parseErr != nil {
+				values[i] = value
+			} else {
+				values[i] = n
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("parsing %s column value %q: %w", s.header[i], value, err)
+		}
+	}
+	return nil
+}
+
+// quote returns `name` quoted as an SQL identifier.
+func quote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,211 @@
+package sqliteoutput
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoip2-csv-converter/convert"
+)
+
+const input = `network,geoname_id,is_anonymous_proxy,postal_code,latitude
+1.0.0.0/24,2077456,0,3000,-37.8159
+2001:4220::/32,,1,,
+`
+
+func openDB(t *testing.T, databaseFile string) *sql.DB {
+	db, err := sql.Open("sqlite", databaseFile)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// query returns the rows returned by `query` with each value formatted as
+// its SQLite type and value, e.g., "integer 1".
+func query(t *testing.T, db *sql.DB, query string) [][]string {
+	rows, err := db.Query(query)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	require.NoError(t, err)
+
+	var result [][]string
+	for rows.Next() {
+		values := make([]string, len(columns))
+		dest := make([]any, len(columns))
+		for i := range dest {
+			dest[i] = &values[i]
+		}
+		require.NoError(t, rows.Scan(dest...))
+		result = append(result, values)
+	}
+	require.NoError(t, rows.Err())
+	return result
+}
+
+func TestConvert(t *testing.T) {
+	databaseFile := filepath.Join(t.TempDir(), "geo.db")
+	stats, err := Convert(
+		strings.NewReader(input),
+		databaseFile,
+		convert.Options{CIDR: true, IntRange: true},
+		TableOptions{},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.RecordsProcessed)
+
+	db := openDB(t, databaseFile)
+
+	assert.Equal(
+		t,
+		[][]string{
+			{"network", "TEXT"},
+			{"network_start_integer", ""},
+			{"network_last_integer", ""},
+			{"geoname_id", "INTEGER"},
+			{"is_anonymous_proxy", "INTEGER"},
+			{"postal_code", "TEXT"},
+			{"latitude", "REAL"},
+		},
+		query(t, db, "SELECT name, type FROM pragma_table_info('networks')"),
+	)
+
+	assert.Equal(
+		t,
+		[][]string{
+			{
+				"1.0.0.0/24",
+				"integer 16777216",
+				"integer 16777471",
+				"integer 2077456",
+				"integer 0",
+				"text 3000",
+				"real -37.8159",
+			},
+			{
+				"2001:4220::/32",
+				"text 42541829336310884227257139937291534336",
+				"text 42541829415539046741521477530835484671",
+				"null ",
+				"integer 1",
+				"text ",
+				"null ",
+			},
+		},
+		query(t, db, `SELECT network,
+			typeof(network_start_integer) || ' ' || network_start_integer,
+			typeof(network_last_integer) || ' ' || network_last_integer,
+			typeof(geoname_id) || ' ' || ifnull(geoname_id, ''),
+			typeof(is_anonymous_proxy) || ' ' || is_anonymous_proxy,
+			typeof(postal_code) || ' ' || postal_code,
+			typeof(latitude) || ' ' || ifnull(latitude, '')
+		FROM networks ORDER BY rowid`),
+	)
+
+	assert.Equal(
+		t,
+		[][]string{{"networks_network_start_integer_idx", "network_start_integer"}},
+		query(t, db, `SELECT il.name, ii.name FROM pragma_index_list('networks') il,
+			pragma_index_info(il.name) ii`),
+	)
+}
+
+func TestConvertTableOptions(t *testing.T) {
+	databaseFile := filepath.Join(t.TempDir(), "geo.db")
+	_, err := Convert(
+		strings.NewReader(input),
+		databaseFile,
+		convert.Options{CIDR: true},
+		TableOptions{Table: `city "blocks"`, Index: "geoname_id"},
+	)
+	require.NoError(t, err)
+
+	db := openDB(t, databaseFile)
+	assert.Equal(
+		t,
+		[][]string{{`city "blocks"_geoname_id_idx`, "geoname_id"}},
+		query(t, db, `SELECT il.name, ii.name FROM pragma_index_list('city "blocks"') il,
+			pragma_index_info(il.name) ii`),
+	)
+
+	// The existing database is replaced.
+	_, err = Convert(
+		strings.NewReader(input),
+		databaseFile,
+		convert.Options{CIDR: true},
+		TableOptions{Index: NoIndex},
+	)
+	require.NoError(t, err)
+
+	db = openDB(t, databaseFile)
+	assert.Equal(
+		t,
+		[][]string{{"networks"}},
+		query(t, db, "SELECT name FROM sqlite_master"),
+	)
+}
+
+func TestConvertFile(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "blocks.csv")
+	require.NoError(t, os.WriteFile(inputFile, []byte(input), 0o600))
+
+	databaseFile := filepath.Join(dir, "geo.db")
+	_, err := ConvertFile(inputFile, databaseFile, convert.Options{CIDR: true, NoSync: true}, TableOptions{})
+	require.NoError(t, err)
+
+	db := openDB(t, databaseFile)
+	assert.Equal(t, [][]string{{"2"}}, query(t, db, "SELECT count(*) FROM networks"))
+}
+
+func TestConvertErrors(t *testing.T) {
+	dir := t.TempDir()
+	databaseFile := filepath.Join(dir, "geo.db")
+
+	_, err := Convert(
+		strings.NewReader("network,geoname_id\n1.0.0.0/24,abc\n"),
+		databaseFile,
+		convert.Options{CIDR: true},
+		TableOptions{},
+	)
+	assert.EqualError(
+		t,
+		err,
+		`parsing geoname_id column value "abc": strconv.ParseInt: parsing "abc": invalid syntax`,
+	)
+
+	_, err = Convert(
+		strings.NewReader(input),
+		databaseFile,
+		convert.Options{CIDR: true, Format: convert.OutputFormatIPSet, IPSetName: "geoip"},
+		TableOptions{},
+	)
+	assert.EqualError(t, err, "the ipset format may not be used with SQLite")
+
+	_, err = Convert(
+		strings.NewReader(input),
+		databaseFile,
+		convert.Options{CIDR: true},
+		TableOptions{Index: "city"},
+	)
+	assert.EqualError(t, err, `index column "city" not found in header`)
+
+	require.NoError(t, os.WriteFile(databaseFile, nil, 0o600))
+	_, err = Convert(
+		strings.NewReader(input),
+		databaseFile,
+		convert.Options{CIDR: true, NoClobber: true},
+		TableOptions{},
+	)
+	require.ErrorIs(t, err, os.ErrExist)
+
+	_, err = Convert(strings.NewReader(""), databaseFile, convert.Options{CIDR: true}, TableOptions{})
+	require.NoError(t, err)
+	assert.NoFileExists(t, databaseFile)
+}
@@ -0,0 +1,20 @@
+//go:build zstd
+
+package main
+
+import (
+	"flag"
+
+	"github.com/maxmind/geoip2-csv-converter/zstdcompress"
+)
+
+// Building with `-tags zstd` adds support for zstd-compressed block files
+// and for compressing output files ending in .zst.
+func init() {
+	flag.IntVar(
+		&zstdcompress.Default.Level,
+		"zstd-level",
+		zstdcompress.DefaultLevel,
+		"The zstd compression level, from 1 to 22, of output files ending in .zst",
+	)
+}
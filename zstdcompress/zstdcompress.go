@@ -0,0 +1,102 @@
+// Package zstdcompress lets the convert package decompress zstd inputs and
+// compress output files with names ending in ".zst". Importing the package
+// registers the Compression:
+//
+//	import _ "github.com/maxmind/geoip2-csv-converter/zstdcompress"
+//
+// It is a separate package so that programs that do not use zstd do not
+// depend on a zstd library.
+package zstdcompress
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/maxmind/geoip2-csv-converter/convert"
+)
+
+// Extension is the file name extension of the output files compressed
+// with zstd.
+const Extension = ".zst"
+
+// DefaultLevel is the compression level used when Compression.Level is not
+// set. It matches the default of the zstd command.
+const DefaultLevel = 3
+
+// Default is the Compression registered for Extension. Its Level may be set
+// before any output is written, e.g., from a flag.
+var Default = &Compression{Level: DefaultLevel}
+
+func init() {
+	convert.RegisterCompression(Extension, Default)
+}
+
+var _ convert.Compression = (*Compression)(nil)
+
+// Compression is a convert.Compression for zstd.
+type Compression struct {
+	// Level is the zstd compression level, from 1 to 22. Higher levels
+	// compress better but more slowly. The encoder supports fewer distinct
+	// levels, so several levels give the same result. Zero uses
+	// DefaultLevel.
+	Level int
+}
+
+var magic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Magic returns the magic number of a zstd frame.
+func (c *Compression) Magic() []byte {
+	return magic
+}
+
+// NewReader returns a reader decompressing the zstd stream `r`.
+func (c *Compression) NewReader(r io.Reader) (io.Reader, error) {
+	decoder, err := zstd.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("creating zstd reader: %w", err)
+	}
+	return &reader{decoder: decoder}, nil
+}
+
+// NewWriter returns a writer compressing to `w` as zstd at Level.
+func (c *Compression) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	level := c.Level
+	if level == 0 {
+		level = DefaultLevel
+	}
+	if level < 1 || level > 22 {
+		return nil, fmt.Errorf("the zstd level must be between 1 and 22, not %d", level)
+	}
+
+	encoder, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	if err != nil {
+		return nil, fmt.Errorf("creating zstd writer: %w", err)
+	}
+	return encoder, nil
+}
+
+// reader is a zstd decoder that is closed once it returns an error, as the
+// convert package does not close the readers of its Compression.
+type reader struct {
+	decoder *zstd.Decoder
+	err     error
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n, err := r.decoder.Read(p)
+	if err != nil {
+		r.decoder.Close()
+		r.err = err
+		if !errors.Is(err, io.EOF) {
+			r.err = fmt.Errorf("decompressing zstd: %w", err)
+		}
+	}
+	return n, r.err
+}
@@ -0,0 +1,81 @@
+package zstdcompress
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maxmind/geoip2-csv-converter/convert"
+)
+
+const input = `network,geoname_id
+1.0.0.0/24,2077456
+2001:4220::/32,357994
+`
+
+func TestRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	var compressed bytes.Buffer
+	w, err := (&Compression{Level: 19}).NewWriter(&compressed)
+	require.NoError(t, err)
+	_, err = w.Write([]byte(input))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	inputFile := filepath.Join(dir, "blocks.csv.zst")
+	require.NoError(t, os.WriteFile(inputFile, compressed.Bytes(), 0o600))
+
+	// The input is detected by its magic number and the output by its name.
+	outputFile := filepath.Join(dir, "output.csv.zst")
+	_, err = convert.ConvertFileWithOptions(
+		inputFile,
+		outputFile,
+		convert.Options{CIDR: true, AutoDecompress: true, NoSync: true},
+	)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(b, magic))
+
+	decoder, err := zstd.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	defer decoder.Close()
+	decompressed, err := io.ReadAll(decoder)
+	require.NoError(t, err)
+	assert.Equal(t, input, string(decompressed))
+
+	// Other outputs are not compressed.
+	outputFile = filepath.Join(dir, "output.csv")
+	_, err = convert.ConvertFileWithOptions(
+		inputFile,
+		outputFile,
+		convert.Options{CIDR: true, AutoDecompress: true, NoSync: true},
+	)
+	require.NoError(t, err)
+	b, err = os.ReadFile(outputFile)
+	require.NoError(t, err)
+	assert.Equal(t, input, string(b))
+}
+
+func TestNewWriterLevel(t *testing.T) {
+	_, err := (&Compression{Level: 23}).NewWriter(io.Discard)
+	assert.EqualError(t, err, "the zstd level must be between 1 and 22, not 23")
+}
+
+func TestNewReaderInvalid(t *testing.T) {
+	r, err := Default.NewReader(strings.NewReader("\x28\xb5\x2f\xfdnot zstd"))
+	require.NoError(t, err)
+
+	_, err = io.ReadAll(r)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "decompressing zstd")
+}